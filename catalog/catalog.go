@@ -0,0 +1,198 @@
+// Package catalog imports books into and exports books out of the system
+// using the file formats librarians already work with.
+package catalog
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/validation"
+)
+
+// ErrMalformed is used when an entry of an import source cannot be decoded.
+var ErrMalformed = errors.New("malformed record")
+
+// Record is a single entry read from an import source.
+type Record struct {
+	// Position identifies the entry in the source, e.g. the CSV line number.
+	Position int
+
+	// Book is the decoded entry, it is meaningful only when Err is nil.
+	Book domain.NewBook
+
+	// Err is set when the entry could not be decoded.
+	Err error
+}
+
+// RecordError describes why an entry of an import source was rejected.
+type RecordError struct {
+	Position int
+	Err      error
+}
+
+// Fields returns the field level details of the error, if any.
+func (e RecordError) Fields() []domain.FieldError {
+	var ferrs validation.FieldErrors
+	if errors.As(e.Err, &ferrs) {
+		fields := make([]domain.FieldError, len(ferrs))
+		for i, ferr := range ferrs {
+			fields[i] = domain.FieldError{Field: ferr.Field, Err: ferr.Err}
+		}
+
+		return fields
+	}
+
+	var verr *domain.ValidationError
+	if errors.As(e.Err, &verr) {
+		return verr.Fields
+	}
+
+	return nil
+}
+
+// Report summarizes the outcome of an import.
+type Report struct {
+	// DryRun is true when the books were only validated and not saved.
+	DryRun bool
+
+	// Total is the number of entries read from the source.
+	Total int
+
+	// Imported contains the saved books or, for a dry run, the books that
+	// would be saved (without an ID).
+	Imported []domain.Book
+
+	// Failed contains the entries that were rejected.
+	Failed []RecordError
+}
+
+// entry is the shape an imported book must have before reaching the domain,
+// the same as a book sent to the API (see web.AppNewBook). Business
+// invariants (e.g. page count, ISBN checksum) are enforced by the domain.
+type entry struct {
+	Title     string `json:"title" validate:"required"`
+	Authors   string `json:"authors" validate:"required"`
+	Publisher string `json:"publisher" validate:"required"`
+	ISBN      string `json:"isbn" validate:"required"`
+}
+
+// Importer saves the records read from an import source.
+type Importer struct {
+	book      *domain.BookCore
+	validator validation.Validator
+}
+
+// NewImporter returns a new Importer.
+func NewImporter(book *domain.BookCore) *Importer {
+	return &Importer{
+		book:      book,
+		validator: validation.New(),
+	}
+}
+
+// Import validates the records and, unless dryRun is set, saves the valid ones.
+func (i *Importer) Import(ctx context.Context, records []Record, dryRun bool) Report {
+	report := Report{
+		DryRun:   dryRun,
+		Total:    len(records),
+		Imported: []domain.Book{},
+		Failed:   []RecordError{},
+	}
+
+	valid := make([]domain.NewBook, 0, len(records))
+	positions := make([]int, 0, len(records))
+
+	for _, record := range records {
+		if err := i.check(record); err != nil {
+			report.Failed = append(report.Failed, RecordError{Position: record.Position, Err: err})
+			continue
+		}
+
+		valid = append(valid, record.Book)
+		positions = append(positions, record.Position)
+	}
+
+	if dryRun {
+		for _, nb := range valid {
+			report.Imported = append(report.Imported, domain.Book{
+				Title:     nb.Title,
+				Authors:   nb.Authors,
+				Publisher: nb.Publisher,
+				Pages:     nb.Pages,
+				ISBN:      nb.ISBN,
+				Tags:      nb.Tags,
+			})
+		}
+
+		return report
+	}
+
+	if len(valid) == 0 {
+		return report
+	}
+
+	for j, ret := range i.book.SaveMany(ctx, valid) {
+		if ret.Err != nil {
+			report.Failed = append(report.Failed, RecordError{Position: positions[j], Err: ret.Err})
+			continue
+		}
+
+		report.Imported = append(report.Imported, ret.Book)
+	}
+
+	sort.SliceStable(report.Failed, func(a, b int) bool {
+		return report.Failed[a].Position < report.Failed[b].Position
+	})
+
+	return report
+}
+
+// check returns the decoding error of a record, the shape errors of its book
+// or the domain invariants it violates, so that a dry run rejects what a save
+// would.
+func (i *Importer) check(record Record) error {
+	if record.Err != nil {
+		return record.Err
+	}
+
+	nb := record.Book
+	if err := i.validator.Check(entry{
+		Title:     nb.Title,
+		Authors:   nb.Authors,
+		Publisher: nb.Publisher,
+		ISBN:      nb.ISBN,
+	}); err != nil {
+		return err
+	}
+
+	return nb.Validate()
+}
+
+// splitAuthors returns the names in the comma separated list of authors of a
+// book.
+func splitAuthors(authors string) []string {
+	var names []string
+
+	for _, name := range strings.Split(authors, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// splitName splits a personal name in direct order into the given names and
+// the family name (the last word), e.g. "Alan A. A. Donovan" becomes
+// "Alan A. A." and "Donovan". Single word names are returned as family name.
+func splitName(name string) (given, family string) {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	return strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1]
+}
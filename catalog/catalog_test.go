@@ -0,0 +1,77 @@
+package catalog_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/database/memory"
+	"github.com/rotiroti/alessandrina/sys/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImporter(t *testing.T) {
+	ctx := context.Background()
+	validBook := domain.NewBook{
+		Title:     "The Go Programming Language",
+		Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher: "Addison-Wesley Professional",
+		Pages:     400,
+		ISBN:      "978-0134190440",
+	}
+	missingPublisher := validBook
+	missingPublisher.Publisher = ""
+	badChecksum := validBook
+	badChecksum.ISBN = "978-0134190441"
+	records := []catalog.Record{
+		{Position: 2, Book: validBook},
+		{Position: 3, Book: missingPublisher},
+		{Position: 4, Err: catalog.ErrMalformed},
+		{Position: 5, Book: badChecksum},
+	}
+
+	// The shape of a row is checked by sys/validation, its invariants by
+	// the domain, whether the import is a dry run or not.
+	assertFailed := func(t *testing.T, report catalog.Report) {
+		t.Helper()
+
+		require.Len(t, report.Failed, 3)
+		assert.Equal(t, 3, report.Failed[0].Position)
+		assert.ErrorAs(t, report.Failed[0].Err, new(validation.FieldErrors))
+		assert.Equal(t, "publisher", report.Failed[0].Fields()[0].Field)
+		assert.ErrorIs(t, report.Failed[1].Err, catalog.ErrMalformed)
+		assert.Equal(t, 5, report.Failed[2].Position)
+		assert.ErrorIs(t, report.Failed[2].Err, domain.ErrInvalid)
+		assert.Equal(t, "isbn", report.Failed[2].Fields()[0].Field)
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		store := memory.NewStore()
+		report := catalog.NewImporter(domain.NewBookCore(store)).Import(ctx, records, true)
+
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 4, report.Total)
+		require.Len(t, report.Imported, 1)
+		assert.Equal(t, validBook.Title, report.Imported[0].Title)
+		assertFailed(t, report)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, books)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		store := memory.NewStore()
+		report := catalog.NewImporter(domain.NewBookCore(store)).Import(ctx, records, false)
+
+		assert.False(t, report.DryRun)
+		require.Len(t, report.Imported, 1)
+		assertFailed(t, report)
+
+		book, err := store.FindOne(ctx, report.Imported[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, validBook.Title, book.Title)
+	})
+}
@@ -0,0 +1,189 @@
+package catalog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// bibtexEscaper escapes the characters with a special meaning in BibTeX and
+// LaTeX. Braces are escaped as well, so a value can never unbalance the
+// braces delimiting a field.
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// bibtexValue returns s escaped and with line breaks folded into spaces.
+func bibtexValue(s string) string {
+	return bibtexEscaper.Replace(strings.Join(strings.Fields(s), " "))
+}
+
+// bibtexName returns a personal name as "Family, Given". A name containing
+// the word "and" is enclosed in braces, otherwise BibTeX would read it as two
+// names.
+func bibtexName(name string) string {
+	if strings.Contains(" "+strings.ToLower(name)+" ", " and ") {
+		return "{" + bibtexValue(name) + "}"
+	}
+
+	given, family := splitName(name)
+	if given == "" {
+		return bibtexValue(family)
+	}
+
+	return bibtexValue(family) + ", " + bibtexValue(given)
+}
+
+// WriteBibTeX encodes books as BibTeX @book entries, using the book ID as
+// citation key.
+func WriteBibTeX(w io.Writer, books []domain.Book) error {
+	bw := bufio.NewWriter(w)
+
+	for i, book := range books {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+
+		fmt.Fprintf(bw, "@book{%s,\n", book.ID)
+		fmt.Fprintf(bw, "  title = {%s},\n", bibtexValue(book.Title))
+
+		if authors := splitAuthors(book.Authors); len(authors) > 0 {
+			names := make([]string, len(authors))
+			for i, author := range authors {
+				names[i] = bibtexName(author)
+			}
+
+			fmt.Fprintf(bw, "  author = {%s},\n", strings.Join(names, " and "))
+		}
+
+		if book.Publisher != "" {
+			fmt.Fprintf(bw, "  publisher = {%s},\n", bibtexValue(book.Publisher))
+		}
+
+		if book.Pages > 0 {
+			fmt.Fprintf(bw, "  pagetotal = {%d},\n", book.Pages)
+		}
+
+		if book.ISBN != "" {
+			fmt.Fprintf(bw, "  isbn = {%s},\n", bibtexValue(book.ISBN))
+		}
+
+		bw.WriteString("}\n")
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("catalog.writebibtex: %w", err)
+	}
+
+	return nil
+}
+
+// risValue returns s on a single line, as RIS tags cannot span lines.
+func risValue(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// WriteRIS encodes books as RIS (Research Information Systems) references of
+// type BOOK, lines are terminated by CRLF as required by the format.
+func WriteRIS(w io.Writer, books []domain.Book) error {
+	bw := bufio.NewWriter(w)
+	tag := func(name, value string) {
+		fmt.Fprintf(bw, "%s  - %s\r\n", name, value)
+	}
+
+	for _, book := range books {
+		tag("TY", "BOOK")
+		tag("ID", book.ID.String())
+		tag("TI", risValue(book.Title))
+
+		for _, author := range splitAuthors(book.Authors) {
+			tag("AU", risValue(invertName(author)))
+		}
+
+		if book.Publisher != "" {
+			tag("PB", risValue(book.Publisher))
+		}
+
+		if book.Pages > 0 {
+			tag("SP", fmt.Sprintf("%d", book.Pages))
+		}
+
+		if book.ISBN != "" {
+			tag("SN", risValue(book.ISBN))
+		}
+
+		tag("ER", "")
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("catalog.writeris: %w", err)
+	}
+
+	return nil
+}
+
+// cslItem is a bibliographic item of the Citation Style Language JSON schema.
+type cslItem struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	Title         string    `json:"title"`
+	Author        []cslName `json:"author,omitempty"`
+	Publisher     string    `json:"publisher,omitempty"`
+	NumberOfPages int       `json:"number-of-pages,omitempty"`
+	ISBN          string    `json:"ISBN,omitempty"`
+}
+
+type cslName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+// WriteCSLJSON encodes books as an array of CSL-JSON items, as understood by
+// reference managers such as Zotero and by citeproc processors.
+func WriteCSLJSON(w io.Writer, books []domain.Book) error {
+	items := make([]cslItem, len(books))
+
+	for i, book := range books {
+		items[i] = cslItem{
+			ID:            book.ID.String(),
+			Type:          "book",
+			Title:         book.Title,
+			Publisher:     book.Publisher,
+			NumberOfPages: book.Pages,
+			ISBN:          book.ISBN,
+		}
+
+		for _, author := range splitAuthors(book.Authors) {
+			given, family := splitName(author)
+			if given == "" {
+				items[i].Author = append(items[i].Author, cslName{Literal: family})
+			} else {
+				items[i].Author = append(items[i].Author, cslName{Family: family, Given: given})
+			}
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(items); err != nil {
+		return fmt.Errorf("catalog.writecsljson: %w", err)
+	}
+
+	return nil
+}
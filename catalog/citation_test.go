@@ -0,0 +1,91 @@
+package catalog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func citationBooks() []domain.Book {
+	return []domain.Book{
+		{
+			ID:        uuid.MustParse("7b2e2c51-5a8d-4a1e-9b36-8f9f6a1f1a01"),
+			Title:     "Go & {Friends}: 100% of C_code",
+			Authors:   "Alan A. A. Donovan, Brian W. Kernighan, Plato",
+			Publisher: "Addison-Wesley",
+			Pages:     400,
+			ISBN:      "978-0134190440",
+		},
+	}
+}
+
+func TestWriteBibTeX(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := catalog.WriteBibTeX(&buf, citationBooks())
+	expected := "@book{7b2e2c51-5a8d-4a1e-9b36-8f9f6a1f1a01,\n" +
+		"  title = {Go \\& \\{Friends\\}: 100\\% of C\\_code},\n" +
+		"  author = {Donovan, Alan A. A. and Kernighan, Brian W. and Plato},\n" +
+		"  publisher = {Addison-Wesley},\n" +
+		"  pagetotal = {400},\n" +
+		"  isbn = {978-0134190440},\n" +
+		"}\n"
+
+	require.NoError(t, err)
+	require.Equal(t, expected, buf.String())
+}
+
+func TestWriteBibTeXCorporateAuthor(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := catalog.WriteBibTeX(&buf, []domain.Book{{Title: "Catalog", Authors: "Barnes and Noble"}})
+
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "  author = {{Barnes and Noble}},\n")
+}
+
+func TestWriteRIS(t *testing.T) {
+	var buf bytes.Buffer
+
+	books := citationBooks()
+	books[0].Title = "Multi\nline title"
+
+	err := catalog.WriteRIS(&buf, books)
+	expected := "TY  - BOOK\r\n" +
+		"ID  - 7b2e2c51-5a8d-4a1e-9b36-8f9f6a1f1a01\r\n" +
+		"TI  - Multi line title\r\n" +
+		"AU  - Donovan, Alan A. A.\r\n" +
+		"AU  - Kernighan, Brian W.\r\n" +
+		"AU  - Plato\r\n" +
+		"PB  - Addison-Wesley\r\n" +
+		"SP  - 400\r\n" +
+		"SN  - 978-0134190440\r\n" +
+		"ER  - \r\n"
+
+	require.NoError(t, err)
+	require.Equal(t, expected, buf.String())
+}
+
+func TestWriteCSLJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := catalog.WriteCSLJSON(&buf, citationBooks())
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), `"title": "Go & {Friends}: 100% of C_code"`)
+
+	var items []map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &items))
+	require.Len(t, items, 1)
+	require.Equal(t, "book", items[0]["type"])
+	require.Equal(t, float64(400), items[0]["number-of-pages"])
+	require.Equal(t, []any{
+		map[string]any{"family": "Donovan", "given": "Alan A. A."},
+		map[string]any{"family": "Kernighan", "given": "Brian W."},
+		map[string]any{"literal": "Plato"},
+	}, items[0]["author"])
+}
@@ -0,0 +1,160 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// ErrMissingColumn is returned when a CSV header lacks a mapped column.
+var ErrMissingColumn = errors.New("missing column")
+
+// Mapping associates each book field with the header of a CSV column.
+type Mapping struct {
+	ID        string
+	Title     string
+	Authors   string
+	Publisher string
+	Pages     string
+	ISBN      string
+}
+
+// DefaultMapping is the Mapping used when no column is customized, it matches
+// the field names used by the API.
+var DefaultMapping = Mapping{
+	ID:        "id",
+	Title:     "title",
+	Authors:   "authors",
+	Publisher: "publisher",
+	Pages:     "pages",
+	ISBN:      "isbn",
+}
+
+// ParseMapping returns DefaultMapping overridden by a comma separated list of
+// field=header pairs, e.g. "title=Book Title,isbn=ISBN13".
+func ParseMapping(s string) (Mapping, error) {
+	mapping := DefaultMapping
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, header, ok := strings.Cut(pair, "=")
+		header = strings.TrimSpace(header)
+
+		if !ok || header == "" {
+			return Mapping{}, fmt.Errorf("catalog.parsemapping: invalid pair %q", pair)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "id":
+			mapping.ID = header
+		case "title":
+			mapping.Title = header
+		case "authors":
+			mapping.Authors = header
+		case "publisher":
+			mapping.Publisher = header
+		case "pages":
+			mapping.Pages = header
+		case "isbn":
+			mapping.ISBN = header
+		default:
+			return Mapping{}, fmt.Errorf("catalog.parsemapping: unknown field %q", field)
+		}
+	}
+
+	return mapping, nil
+}
+
+// ReadCSV decodes the rows of a CSV document into records.
+//
+// The first row must be a header containing the columns of the mapping, the
+// ID column is optional and ignored since imported books always get a new ID.
+// Rows that cannot be decoded are returned as records with an error.
+func ReadCSV(r io.Reader, mapping Mapping) ([]Record, error) {
+	t, err := newTable(r, ',')
+	if err != nil {
+		return nil, fmt.Errorf("catalog.readcsv header: %w", err)
+	}
+
+	if err := t.require(mapping.Title, mapping.Authors, mapping.Publisher, mapping.Pages, mapping.ISBN); err != nil {
+		return nil, fmt.Errorf("catalog.readcsv: %w", err)
+	}
+
+	records, err := t.records(func(line int, value func(string) string) Record {
+		record := Record{
+			Position: line,
+			Book: domain.NewBook{
+				Title:     value(mapping.Title),
+				Authors:   value(mapping.Authors),
+				Publisher: value(mapping.Publisher),
+				ISBN:      value(mapping.ISBN),
+			},
+		}
+
+		record.Book.Pages, record.Err = parsePages(value(mapping.Pages))
+
+		return record
+	})
+	if err != nil {
+		return nil, fmt.Errorf("catalog.readcsv: %w", err)
+	}
+
+	return records, nil
+}
+
+// parsePages returns the page count of a book, zero when s is empty.
+func parsePages(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, &domain.ValidationError{Fields: []domain.FieldError{
+			{Field: "pages", Err: fmt.Sprintf("%q is not a number", s)},
+		}}
+	}
+
+	return n, nil
+}
+
+// WriteCSV encodes books as a CSV document, with a header row named after the
+// mapping.
+func WriteCSV(w io.Writer, books []domain.Book, mapping Mapping) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{mapping.ID, mapping.Title, mapping.Authors, mapping.Publisher, mapping.Pages, mapping.ISBN}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("catalog.writecsv header: %w", err)
+	}
+
+	for _, book := range books {
+		row := []string{
+			book.ID.String(),
+			book.Title,
+			book.Authors,
+			book.Publisher,
+			strconv.Itoa(book.Pages),
+			book.ISBN,
+		}
+
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("catalog.writecsv: %w", err)
+		}
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("catalog.writecsv flush: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package catalog_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMapping(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		mapping, err := catalog.ParseMapping("")
+
+		require.NoError(t, err)
+		assert.Equal(t, catalog.DefaultMapping, mapping)
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		mapping, err := catalog.ParseMapping("title=Book Title, isbn = ISBN13")
+
+		require.NoError(t, err)
+		assert.Equal(t, "Book Title", mapping.Title)
+		assert.Equal(t, "ISBN13", mapping.ISBN)
+		assert.Equal(t, catalog.DefaultMapping.Authors, mapping.Authors)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, s := range []string{"title", "title=", "color=Color"} {
+			_, err := catalog.ParseMapping(s)
+			assert.Error(t, err, s)
+		}
+	})
+}
+
+func TestReadCSV(t *testing.T) {
+	t.Run("Rows", func(t *testing.T) {
+		doc := "Book Title,authors,publisher,pages,isbn\n" +
+			"The Go Programming Language,\"Alan A. A. Donovan, Brian W. Kernighan\",Addison-Wesley,400,978-0134190440\n" +
+			"Bad Pages,Someone,Someone Else,many,978-0134190440\n" +
+			"Short Row,Someone\n"
+		mapping, err := catalog.ParseMapping("title=Book Title")
+		require.NoError(t, err)
+
+		records, err := catalog.ReadCSV(strings.NewReader(doc), mapping)
+
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, catalog.Record{
+			Position: 2,
+			Book: domain.NewBook{
+				Title:     "The Go Programming Language",
+				Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+				Publisher: "Addison-Wesley",
+				Pages:     400,
+				ISBN:      "978-0134190440",
+			},
+		}, records[0])
+		assert.Equal(t, 3, records[1].Position)
+		assert.ErrorIs(t, records[1].Err, domain.ErrInvalid)
+		assert.Equal(t, 4, records[2].Position)
+		assert.NoError(t, records[2].Err)
+		assert.Equal(t, "Short Row", records[2].Book.Title)
+		assert.Empty(t, records[2].Book.ISBN)
+	})
+
+	t.Run("MissingColumn", func(t *testing.T) {
+		_, err := catalog.ReadCSV(strings.NewReader("title,authors\n"), catalog.DefaultMapping)
+
+		assert.ErrorIs(t, err, catalog.ErrMissingColumn)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		doc := "title,authors,publisher,pages,isbn\n\"unterminated,a,b,1,2\n"
+		records, err := catalog.ReadCSV(strings.NewReader(doc), catalog.DefaultMapping)
+
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.ErrorIs(t, records[0].Err, catalog.ErrMalformed)
+	})
+}
+
+func TestWriteCSV(t *testing.T) {
+	books := []domain.Book{
+		{
+			ID:        uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812"),
+			Title:     "The Go Programming Language",
+			Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+			Publisher: "Addison-Wesley",
+			Pages:     400,
+			ISBN:      "978-0134190440",
+		},
+	}
+	expected := "id,title,authors,publisher,pages,isbn\n" +
+		"ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812,The Go Programming Language,\"Alan A. A. Donovan, Brian W. Kernighan\",Addison-Wesley,400,978-0134190440\n"
+
+	var buf bytes.Buffer
+	err := catalog.WriteCSV(&buf, books, catalog.DefaultMapping)
+
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.String())
+
+	// An exported document can be imported back.
+	records, err := catalog.ReadCSV(&buf, catalog.DefaultMapping)
+
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, books[0].Title, records[0].Book.Title)
+}
@@ -0,0 +1,104 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// Namespaces of the OAI-PMH Dublin Core metadata format.
+const (
+	OAIDCNamespace = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+	DCNamespace    = "http://purl.org/dc/elements/1.1/"
+
+	oaidcSchemaLocation = OAIDCNamespace + " http://www.openarchives.org/OAI/2.0/oai_dc.xsd"
+	xsiNamespace        = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// oaidcRecord is an oai_dc record, the namespace prefixes are part of the
+// names since encoding/xml cannot choose them.
+type oaidcRecord struct {
+	XMLName        xml.Name `xml:"oai_dc:dc"`
+	XMLNSOAIDC     string   `xml:"xmlns:oai_dc,attr"`
+	XMLNSDC        string   `xml:"xmlns:dc,attr"`
+	XMLNSXSI       string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Titles         []string `xml:"dc:title"`
+	Creators       []string `xml:"dc:creator"`
+	Subjects       []string `xml:"dc:subject"`
+	Publishers     []string `xml:"dc:publisher"`
+	Types          []string `xml:"dc:type"`
+	Formats        []string `xml:"dc:format"`
+	Identifiers    []string `xml:"dc:identifier"`
+}
+
+type oaidcCollection struct {
+	XMLName xml.Name      `xml:"collection"`
+	Records []oaidcRecord `xml:"oai_dc:dc"`
+}
+
+// WriteDublinCore encodes books as simple Dublin Core records (the oai_dc
+// metadata format of OAI-PMH). A single book is written as a bare oai_dc:dc
+// document, several books are wrapped in a collection element.
+func WriteDublinCore(w io.Writer, books []domain.Book) error {
+	var v any
+
+	if len(books) == 1 {
+		v = bookToOAIDC(books[0])
+	} else {
+		collection := oaidcCollection{Records: make([]oaidcRecord, len(books))}
+		for i, book := range books {
+			collection.Records[i] = bookToOAIDC(book)
+		}
+
+		v = collection
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("catalog.writedublincore: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("catalog.writedublincore: %w", err)
+	}
+
+	return nil
+}
+
+// bookToOAIDC maps a book to Dublin Core elements: authors are creators in
+// inverted order, tags are subjects and the page count is the format extent.
+func bookToOAIDC(book domain.Book) oaidcRecord {
+	record := oaidcRecord{
+		XMLNSOAIDC:     OAIDCNamespace,
+		XMLNSDC:        DCNamespace,
+		XMLNSXSI:       xsiNamespace,
+		SchemaLocation: oaidcSchemaLocation,
+		Titles:         []string{book.Title},
+		Subjects:       book.Tags,
+		Types:          []string{"Text"},
+		Identifiers:    []string{"urn:uuid:" + book.ID.String()},
+	}
+
+	for _, author := range splitAuthors(book.Authors) {
+		record.Creators = append(record.Creators, invertName(author))
+	}
+
+	if book.Publisher != "" {
+		record.Publishers = []string{book.Publisher}
+	}
+
+	if book.Pages > 0 {
+		record.Formats = []string{fmt.Sprintf("%d pages", book.Pages)}
+	}
+
+	if book.ISBN != "" {
+		record.Identifiers = append(record.Identifiers, "urn:isbn:"+domain.NormalizeISBN(book.ISBN))
+	}
+
+	return record
+}
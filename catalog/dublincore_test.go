@@ -0,0 +1,50 @@
+package catalog_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDublinCore(t *testing.T) {
+	book := domain.Book{
+		ID:        uuid.MustParse("7b2e2c51-5a8d-4a1e-9b36-8f9f6a1f1a01"),
+		Title:     "The Go Programming Language",
+		Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher: "Addison-Wesley",
+		Pages:     380,
+		ISBN:      "978-0134190440",
+		Tags:      []string{"go"},
+	}
+
+	var buf bytes.Buffer
+
+	err := catalog.WriteDublinCore(&buf, []domain.Book{book})
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<oai_dc:dc xmlns:oai_dc="http://www.openarchives.org/OAI/2.0/oai_dc/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.openarchives.org/OAI/2.0/oai_dc/ http://www.openarchives.org/OAI/2.0/oai_dc.xsd">
+  <dc:title>The Go Programming Language</dc:title>
+  <dc:creator>Donovan, Alan A. A.</dc:creator>
+  <dc:creator>Kernighan, Brian W.</dc:creator>
+  <dc:subject>go</dc:subject>
+  <dc:publisher>Addison-Wesley</dc:publisher>
+  <dc:type>Text</dc:type>
+  <dc:format>380 pages</dc:format>
+  <dc:identifier>urn:uuid:7b2e2c51-5a8d-4a1e-9b36-8f9f6a1f1a01</dc:identifier>
+  <dc:identifier>urn:isbn:9780134190440</dc:identifier>
+</oai_dc:dc>`
+
+	require.NoError(t, err)
+	require.Equal(t, expected, buf.String())
+
+	buf.Reset()
+
+	err = catalog.WriteDublinCore(&buf, []domain.Book{book, book})
+
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "<collection>\n  <oai_dc:dc ")
+	require.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("</oai_dc:dc>")))
+}
@@ -0,0 +1,153 @@
+package catalog
+
+import (
+	"io"
+	"strings"
+
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// Options are the settings of the formats that can be customized.
+type Options struct {
+	// Mapping is used by the CSV format, the zero value means DefaultMapping.
+	Mapping Mapping
+}
+
+func (o Options) mapping() Mapping {
+	if o.Mapping == (Mapping{}) {
+		return DefaultMapping
+	}
+
+	return o.Mapping
+}
+
+// Format describes a file format the catalog can read books from or write
+// books to.
+type Format struct {
+	// Name is the short name of the format, e.g. "csv".
+	Name string
+
+	// MediaType is the media type of the format, e.g. "text/csv".
+	MediaType string
+
+	// Binary is true when the encoded documents are not plain text.
+	Binary bool
+
+	// Read decodes an import source, it is nil when the format cannot be imported.
+	Read func(r io.Reader, opts Options) ([]Record, error)
+
+	// Write encodes books, it is nil when the format cannot be exported.
+	Write func(w io.Writer, books []domain.Book, opts Options) error
+}
+
+var formats = []Format{
+	{
+		Name:      "csv",
+		MediaType: "text/csv",
+		Read: func(r io.Reader, opts Options) ([]Record, error) {
+			return ReadCSV(r, opts.mapping())
+		},
+		Write: func(w io.Writer, books []domain.Book, opts Options) error {
+			return WriteCSV(w, books, opts.mapping())
+		},
+	},
+	{
+		Name:      "marc",
+		MediaType: "application/marc",
+		Binary:    true,
+		Read: func(r io.Reader, _ Options) ([]Record, error) {
+			return ReadMARC(r)
+		},
+		Write: func(w io.Writer, books []domain.Book, _ Options) error {
+			return WriteMARC(w, books)
+		},
+	},
+	{
+		Name:      "marcxml",
+		MediaType: "application/marcxml+xml",
+		Read: func(r io.Reader, _ Options) ([]Record, error) {
+			return ReadMARCXML(r)
+		},
+		Write: func(w io.Writer, books []domain.Book, _ Options) error {
+			return WriteMARCXML(w, books)
+		},
+	},
+	{
+		Name:      "onix",
+		MediaType: "application/onix+xml",
+		Read: func(r io.Reader, _ Options) ([]Record, error) {
+			return ReadONIX(r)
+		},
+	},
+	{
+		Name:      "oai_dc",
+		MediaType: "application/oai_dc+xml",
+		Write: func(w io.Writer, books []domain.Book, _ Options) error {
+			return WriteDublinCore(w, books)
+		},
+	},
+	{
+		Name:      "goodreads",
+		MediaType: "text/csv",
+		Read: func(r io.Reader, _ Options) ([]Record, error) {
+			return ReadGoodreads(r)
+		},
+	},
+	{
+		Name:      "librarything",
+		MediaType: "text/tab-separated-values",
+		Read: func(r io.Reader, _ Options) ([]Record, error) {
+			return ReadLibraryThing(r)
+		},
+	},
+	{
+		Name:      "bibtex",
+		MediaType: "application/x-bibtex",
+		Write: func(w io.Writer, books []domain.Book, _ Options) error {
+			return WriteBibTeX(w, books)
+		},
+	},
+	{
+		Name:      "ris",
+		MediaType: "application/x-research-info-systems",
+		Write: func(w io.Writer, books []domain.Book, _ Options) error {
+			return WriteRIS(w, books)
+		},
+	},
+	{
+		Name:      "csljson",
+		MediaType: "application/vnd.citationstyles.csl+json",
+		Write: func(w io.Writer, books []domain.Book, _ Options) error {
+			return WriteCSLJSON(w, books)
+		},
+	},
+}
+
+// Formats returns all the supported formats.
+func Formats() []Format {
+	return append([]Format(nil), formats...)
+}
+
+// FormatByName returns the format with the given short name.
+func FormatByName(name string) (Format, bool) {
+	for _, f := range formats {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+
+	return Format{}, false
+}
+
+// FormatByMediaType returns the format with the given media type, the first
+// registered one when several formats share it (e.g. "text/csv" is the plain
+// CSV format, not the Goodreads one).
+func FormatByMediaType(mediaType string) (Format, bool) {
+	for _, f := range formats {
+		if strings.EqualFold(f.MediaType, mediaType) {
+			return f, true
+		}
+	}
+
+	return Format{}, false
+}
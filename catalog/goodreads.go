@@ -0,0 +1,103 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// goodreadsSeriesRe matches the series Goodreads appends to titles, e.g.
+// "The Hobbit (Middle-earth Universe, #0)".
+var goodreadsSeriesRe = regexp.MustCompile(`\s*\([^()]*#\d+(?:\.\d+)?\)$`)
+
+// ReadGoodreads decodes the books of a Goodreads library export (the CSV
+// file of "My Books > Import and export").
+//
+// The ISBN-13 is preferred over the ISBN-10, the additional authors are
+// appended to the author and the shelves, exclusive shelf included, become
+// the tags of the book. Rows without enough details (e.g. e-books without an
+// ISBN) are returned as records that fail validation.
+func ReadGoodreads(r io.Reader) ([]Record, error) {
+	t, err := newTable(r, ',')
+	if err != nil {
+		return nil, fmt.Errorf("catalog.readgoodreads header: %w", err)
+	}
+
+	if err := t.require("Title", "Author", "ISBN", "ISBN13"); err != nil {
+		return nil, fmt.Errorf("catalog.readgoodreads: %w", err)
+	}
+
+	records, err := t.records(func(line int, value func(string) string) Record {
+		isbn := goodreadsISBN(value("ISBN13"))
+		if isbn == "" {
+			isbn = goodreadsISBN(value("ISBN"))
+		}
+
+		record := Record{
+			Position: line,
+			Book: domain.NewBook{
+				Title:     goodreadsSeriesRe.ReplaceAllString(value("Title"), ""),
+				Authors:   joinAuthors(append([]string{value("Author")}, splitAuthors(value("Additional Authors"))...)),
+				Publisher: value("Publisher"),
+				ISBN:      isbn,
+				Tags:      splitTags(",", value("Bookshelves"), value("Exclusive Shelf")),
+			},
+		}
+
+		record.Book.Pages, record.Err = parsePages(value("Number of Pages"))
+
+		return record
+	})
+	if err != nil {
+		return nil, fmt.Errorf("catalog.readgoodreads: %w", err)
+	}
+
+	return records, nil
+}
+
+// goodreadsISBN removes the spreadsheet formula quoting Goodreads uses to keep
+// leading zeros, e.g. `="0134190440"`.
+func goodreadsISBN(s string) string {
+	return strings.Trim(strings.TrimPrefix(s, "="), `"`)
+}
+
+// joinAuthors returns the non-blank names as a comma separated list of
+// authors.
+func joinAuthors(names []string) string {
+	var authors []string
+
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			authors = append(authors, name)
+		}
+	}
+
+	return strings.Join(authors, ", ")
+}
+
+// splitTags returns the tags found in the lists separated by sep, without
+// duplicates (ignoring case) and in order of appearance.
+func splitTags(sep string, lists ...string) []string {
+	var tags []string
+
+	seen := make(map[string]bool)
+
+	for _, list := range lists {
+		for _, tag := range strings.Split(list, sep) {
+			tag = strings.TrimSpace(tag)
+			key := strings.ToLower(tag)
+
+			if tag == "" || seen[key] {
+				continue
+			}
+
+			seen[key] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
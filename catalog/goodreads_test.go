@@ -0,0 +1,83 @@
+package catalog_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/database/memory"
+	"github.com/rotiroti/alessandrina/sys/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadGoodreads(t *testing.T) {
+	f, err := os.Open("testdata/goodreads_library_export.csv")
+	require.NoError(t, err)
+	defer f.Close()
+
+	records, err := catalog.ReadGoodreads(f)
+
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, catalog.Record{
+		Position: 2,
+		Book: domain.NewBook{
+			Title:     "The Go Programming Language",
+			Authors:   "Alan A.A. Donovan, Brian W. Kernighan",
+			Publisher: "Addison-Wesley Professional",
+			Pages:     380,
+			ISBN:      "9780134190440",
+			Tags:      []string{"programming", "go", "read"},
+		},
+	}, records[0])
+	assert.Equal(t, catalog.Record{
+		Position: 3,
+		Book: domain.NewBook{
+			Title:     "The Hobbit, or There and Back Again",
+			Authors:   "J.R.R. Tolkien",
+			Publisher: "Houghton Mifflin",
+			Pages:     366,
+			ISBN:      "9780618260300",
+			Tags:      []string{"Favorites", "to-read"},
+		},
+	}, records[1])
+	assert.Equal(t, 4, records[2].Position)
+	assert.Empty(t, records[2].Book.ISBN)
+}
+
+func TestReadGoodreadsMissingColumn(t *testing.T) {
+	_, err := catalog.ReadGoodreads(strings.NewReader("Title,Author\nThe Hobbit,J.R.R. Tolkien\n"))
+
+	require.ErrorIs(t, err, catalog.ErrMissingColumn)
+}
+
+func TestImportGoodreadsSkippedRows(t *testing.T) {
+	f, err := os.Open("testdata/goodreads_library_export.csv")
+	require.NoError(t, err)
+	defer f.Close()
+
+	records, err := catalog.ReadGoodreads(f)
+	require.NoError(t, err)
+
+	report := catalog.NewImporter(domain.NewBookCore(memory.NewStore())).Import(context.Background(), records, false)
+
+	require.Len(t, report.Imported, 2)
+	require.Len(t, report.Failed, 1)
+	assert.Equal(t, 4, report.Failed[0].Position)
+
+	// The e-book has neither a publisher nor an ISBN: the row is skipped by
+	// the shape checks of validation.Config, before reaching the domain.
+	var ferrs validation.FieldErrors
+	require.ErrorAs(t, report.Failed[0].Err, &ferrs)
+
+	fields := make([]string, 0, len(report.Failed[0].Fields()))
+	for _, field := range report.Failed[0].Fields() {
+		fields = append(fields, field.Field)
+	}
+
+	assert.ElementsMatch(t, []string{"publisher", "isbn"}, fields)
+}
@@ -0,0 +1,130 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// librarythingDefaultCollection is the collection every LibraryThing book
+// belongs to, it is not a meaningful tag.
+const librarythingDefaultCollection = "Your library"
+
+// librarythingPagesRe matches the page count in the publication details, e.g.
+// "Addison-Wesley Professional (2015), Edition: 1, 380 pages".
+var librarythingPagesRe = regexp.MustCompile(`(\d+)\s+(?:p\.|pages)`)
+
+// ReadLibraryThing decodes the books of a LibraryThing export, either the
+// tab-delimited or the comma separated one.
+//
+// Authors are listed last name first by LibraryThing, they are turned into
+// the direct order used by the catalog. The publisher and, when the "Page
+// Count" column is blank, the pages are taken from the publication details.
+// Tags and collections (other than "Your library") become the tags of the
+// book.
+func ReadLibraryThing(r io.Reader) ([]Record, error) {
+	r, comma := sniffComma(r)
+
+	t, err := newTable(r, comma)
+	if err != nil {
+		return nil, fmt.Errorf("catalog.readlibrarything header: %w", err)
+	}
+
+	if err := t.require("Title", "Primary Author", "ISBN"); err != nil {
+		return nil, fmt.Errorf("catalog.readlibrarything: %w", err)
+	}
+
+	records, err := t.records(func(line int, value func(string) string) Record {
+		authors := []string{directName(value("Primary Author"))}
+		for _, name := range strings.Split(value("Secondary Author"), "|") {
+			authors = append(authors, directName(name))
+		}
+
+		publication := value("Publication")
+
+		record := Record{
+			Position: line,
+			Book: domain.NewBook{
+				Title:     value("Title"),
+				Authors:   joinAuthors(authors),
+				Publisher: librarythingPublisher(publication),
+				ISBN:      librarythingISBN(value("ISBN"), value("ISBNs")),
+				Tags:      librarythingTags(value("Tags"), value("Collections")),
+			},
+		}
+
+		pages := value("Page Count")
+		if pages == "" {
+			if m := librarythingPagesRe.FindStringSubmatch(publication); m != nil {
+				pages = m[1]
+			}
+		}
+
+		record.Book.Pages, record.Err = parsePages(pages)
+
+		return record
+	})
+	if err != nil {
+		return nil, fmt.Errorf("catalog.readlibrarything: %w", err)
+	}
+
+	return records, nil
+}
+
+// directName returns a personal name listed last name first in direct
+// order: "Donovan, Alan A. A." becomes "Alan A. A. Donovan".
+func directName(name string) string {
+	family, given, ok := strings.Cut(name, ",")
+	if !ok {
+		return strings.TrimSpace(name)
+	}
+
+	return strings.TrimSpace(strings.TrimSpace(given) + " " + strings.TrimSpace(family))
+}
+
+// librarythingPublisher returns the publisher from the publication details,
+// which are followed by the year or the edition.
+func librarythingPublisher(publication string) string {
+	if i := strings.IndexAny(publication, "(,"); i >= 0 {
+		publication = publication[:i]
+	}
+
+	return strings.TrimSpace(publication)
+}
+
+// librarythingISBN returns the first valid ISBN, LibraryThing encloses them in
+// brackets (e.g. "[0134190440]") and lists the other editions in "ISBNs".
+func librarythingISBN(values ...string) string {
+	var first string
+
+	for _, value := range values {
+		for _, isbn := range strings.Split(strings.Trim(value, "[]"), ",") {
+			isbn = strings.TrimSpace(isbn)
+
+			if domain.ValidISBN(isbn) {
+				return isbn
+			}
+
+			if first == "" {
+				first = isbn
+			}
+		}
+	}
+
+	return first
+}
+
+func librarythingTags(tags, collections string) []string {
+	var ret []string
+
+	for _, tag := range splitTags(",", tags, collections) {
+		if !strings.EqualFold(tag, librarythingDefaultCollection) {
+			ret = append(ret, tag)
+		}
+	}
+
+	return ret
+}
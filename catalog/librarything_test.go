@@ -0,0 +1,59 @@
+package catalog_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLibraryThing(t *testing.T) {
+	f, err := os.Open("testdata/librarything_library.tsv")
+	require.NoError(t, err)
+	defer f.Close()
+
+	records, err := catalog.ReadLibraryThing(f)
+
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, catalog.Record{
+		Position: 2,
+		Book: domain.NewBook{
+			Title:     "The Go Programming Language",
+			Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+			Publisher: "Addison-Wesley Professional",
+			Pages:     380,
+			ISBN:      "0134190440",
+			Tags:      []string{"go", "programming", "Favorites"},
+		},
+	}, records[0])
+	assert.Equal(t, catalog.Record{
+		Position: 3,
+		Book: domain.NewBook{
+			Title:     "Structure and Interpretation of Computer Programs",
+			Authors:   "Harold Abelson, Gerald Jay Sussman, Julie Sussman",
+			Publisher: "MIT Press",
+			Pages:     657,
+			ISBN:      "0262510871",
+			Tags:      []string{"lisp"},
+		},
+	}, records[1])
+	assert.Equal(t, catalog.Record{Position: 4, Book: domain.NewBook{Title: "A Book Without Details", Authors: "Nobody"}}, records[2])
+}
+
+func TestReadLibraryThingCSV(t *testing.T) {
+	doc := "Title,Primary Author,Secondary Author,Publication,Page Count,Tags,Collections,ISBN\n" +
+		"The Go Programming Language,\"Donovan, Alan A. A.\",,\"Addison-Wesley (2015)\",380,,Your library,[0134190440]\n"
+
+	records, err := catalog.ReadLibraryThing(strings.NewReader(doc))
+
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "Alan A. A. Donovan", records[0].Book.Authors)
+	assert.Equal(t, "Addison-Wesley", records[0].Book.Publisher)
+	assert.Empty(t, records[0].Book.Tags)
+}
@@ -0,0 +1,510 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// Delimiters of the ISO 2709 exchange format.
+const (
+	marcSubfieldDelimiter = 0x1F
+	marcFieldTerminator   = 0x1E
+	marcRecordTerminator  = 0x1D
+)
+
+const (
+	marcLeaderLength         = 24
+	marcDirectoryEntryLength = 12
+)
+
+// marcRecord is a MARC 21 bibliographic record.
+type marcRecord struct {
+	Leader string
+	Fields []marcField
+}
+
+// marcField is either a control field (tags 001-009, Value only) or a data
+// field (indicators and subfields).
+type marcField struct {
+	Tag       string
+	Value     string
+	Ind1      byte
+	Ind2      byte
+	Subfields []marcSubfield
+}
+
+// marcSubfield is a coded element of a data field.
+type marcSubfield struct {
+	Code  byte
+	Value string
+}
+
+func (f marcField) isControl() bool {
+	return strings.HasPrefix(f.Tag, "00")
+}
+
+// subfield returns the value of the first subfield with the given code.
+func (f marcField) subfield(code byte) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return sf.Value
+		}
+	}
+
+	return ""
+}
+
+// fields returns the fields with the given tag.
+func (r marcRecord) fields(tag string) []marcField {
+	var fields []marcField
+
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
+// ReadMARC decodes a stream of MARC 21 records in the ISO 2709 exchange
+// format. Records that cannot be decoded are returned with an error, their
+// position is the index of the record in the stream starting from 1.
+//
+// NOTE: only UTF-8 records (leader position 09 set to "a") are fully
+// supported, MARC-8 records are decoded as long as they only contain ASCII.
+func ReadMARC(r io.Reader) ([]Record, error) {
+	reader := bufio.NewReader(r)
+
+	var records []Record
+
+	for position := 1; ; position++ {
+		raw, err := reader.ReadBytes(marcRecordTerminator)
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(raw)) > 0 {
+				records = append(records, Record{
+					Position: position,
+					Err:      fmt.Errorf("%w: truncated marc record", ErrMalformed),
+				})
+			}
+
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("catalog.readmarc: %w", err)
+		}
+
+		record, err := decodeISO2709(raw)
+		if err != nil {
+			records = append(records, Record{Position: position, Err: fmt.Errorf("%w: %w", ErrMalformed, err)})
+			continue
+		}
+
+		records = append(records, Record{Position: position, Book: marcToNewBook(record)})
+	}
+
+	return records, nil
+}
+
+func decodeISO2709(raw []byte) (marcRecord, error) {
+	// Some files separate records with line breaks.
+	raw = bytes.TrimLeft(raw, "\r\n")
+
+	if len(raw) < marcLeaderLength {
+		return marcRecord{}, errors.New("record shorter than the leader")
+	}
+
+	leader := string(raw[:marcLeaderLength])
+
+	length, ok := marcNumber(leader[0:5])
+	if !ok || length != len(raw) {
+		return marcRecord{}, fmt.Errorf("record length %q does not match %d bytes", leader[0:5], len(raw))
+	}
+
+	base, ok := marcNumber(leader[12:17])
+	if !ok || base <= marcLeaderLength || base > len(raw) {
+		return marcRecord{}, fmt.Errorf("invalid base address of data %q", leader[12:17])
+	}
+
+	if raw[base-1] != marcFieldTerminator {
+		return marcRecord{}, errors.New("directory not terminated")
+	}
+
+	directory := raw[marcLeaderLength : base-1]
+	if len(directory)%marcDirectoryEntryLength != 0 {
+		return marcRecord{}, errors.New("invalid directory length")
+	}
+
+	record := marcRecord{Leader: leader}
+	data := raw[base:]
+
+	for i := 0; i < len(directory); i += marcDirectoryEntryLength {
+		entry := string(directory[i : i+marcDirectoryEntryLength])
+		tag := entry[0:3]
+
+		fieldLength, ok1 := marcNumber(entry[3:7])
+		start, ok2 := marcNumber(entry[7:12])
+
+		if !ok1 || !ok2 || fieldLength < 1 || start+fieldLength > len(data) {
+			return marcRecord{}, fmt.Errorf("invalid directory entry %q", entry)
+		}
+
+		if data[start+fieldLength-1] != marcFieldTerminator {
+			return marcRecord{}, fmt.Errorf("field %s not terminated", tag)
+		}
+
+		field, err := decodeMARCField(tag, data[start:start+fieldLength-1])
+		if err != nil {
+			return marcRecord{}, err
+		}
+
+		record.Fields = append(record.Fields, field)
+	}
+
+	return record, nil
+}
+
+// marcNumber parses a number of the leader or of the directory, made of
+// ASCII digits only: strconv.Atoi would also accept a sign.
+func marcNumber(s string) (int, bool) {
+	n := 0
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+
+		n = n*10 + int(s[i]-'0')
+	}
+
+	return n, s != ""
+}
+
+func decodeMARCField(tag string, data []byte) (marcField, error) {
+	field := marcField{Tag: tag}
+
+	if !utf8.Valid(data) {
+		return marcField{}, fmt.Errorf("field %s is not valid UTF-8", tag)
+	}
+
+	if field.isControl() {
+		field.Value = string(data)
+		return field, nil
+	}
+
+	if len(data) < 2 {
+		return marcField{}, fmt.Errorf("field %s has no indicators", tag)
+	}
+
+	field.Ind1, field.Ind2 = data[0], data[1]
+
+	for _, sf := range bytes.Split(data[2:], []byte{marcSubfieldDelimiter}) {
+		if len(sf) == 0 {
+			continue
+		}
+
+		field.Subfields = append(field.Subfields, marcSubfield{Code: sf[0], Value: string(sf[1:])})
+	}
+
+	return field, nil
+}
+
+var (
+	// marcPagesRe matches the page count of a physical description, e.g.
+	// "xvii, 380 pages" or "380 p.".
+	marcPagesRe = regexp.MustCompile(`(\d+)\s*(?:pages|page|pp\.?|p\.?)(?:\W|$)`)
+	marcDigitRe = regexp.MustCompile(`\d+`)
+
+	// marcInitialsRe matches a string ending with initials, e.g. "J.R.R.".
+	marcInitialsRe = regexp.MustCompile(`(?:^|\s)(?:\p{L}\.)+$`)
+)
+
+// marcToNewBook maps a bibliographic record to a new book:
+//
+//   - 020 $a: ISBN, the first valid one
+//   - 100 $a (and 700 $a): authors
+//   - 245 $a and $b: title and subtitle
+//   - 264 $b (or 260 $b): publisher
+//   - 300 $a: pages
+//   - 650, 651, 655 and 653 $a: tags, from the subject headings, genres and
+//     uncontrolled index terms
+func marcToNewBook(record marcRecord) domain.NewBook {
+	var nb domain.NewBook
+
+	for _, f := range record.fields("020") {
+		isbn := strings.Fields(f.subfield('a'))
+		if len(isbn) == 0 {
+			continue
+		}
+
+		if nb.ISBN == "" || domain.ValidISBN(isbn[0]) && !domain.ValidISBN(nb.ISBN) {
+			nb.ISBN = isbn[0]
+		}
+	}
+
+	var authors []string
+
+	for _, tag := range []string{"100", "700"} {
+		for _, f := range record.fields(tag) {
+			if name := marcName(f); name != "" {
+				authors = append(authors, name)
+			}
+		}
+	}
+
+	nb.Authors = strings.Join(authors, ", ")
+
+	if fields := record.fields("245"); len(fields) > 0 {
+		title := trimISBD(fields[0].subfield('a'))
+		if subtitle := trimISBD(fields[0].subfield('b')); subtitle != "" {
+			title = title + ": " + subtitle
+		}
+
+		nb.Title = title
+	}
+
+	for _, f := range record.fields("264") {
+		// Second indicator 1 identifies the publication statement.
+		if f.Ind2 == '1' && nb.Publisher == "" {
+			nb.Publisher = trimISBD(f.subfield('b'))
+		}
+	}
+
+	if fields := record.fields("260"); nb.Publisher == "" && len(fields) > 0 {
+		nb.Publisher = trimISBD(fields[0].subfield('b'))
+	}
+
+	if fields := record.fields("300"); len(fields) > 0 {
+		nb.Pages = marcPages(fields[0].subfield('a'))
+	}
+
+	var headings []string
+
+	for _, tag := range marcTagFields {
+		for _, f := range record.fields(tag) {
+			headings = append(headings, trimISBD(f.subfield('a')))
+		}
+	}
+
+	// Every heading is a tag of its own: split on the subfield delimiter,
+	// which no subfield value contains.
+	nb.Tags = splitTags(string(rune(marcSubfieldDelimiter)), headings...)
+
+	return nb
+}
+
+// marcTagFields are the fields whose $a becomes a tag of the book: topical
+// terms, geographic names, genres and uncontrolled index terms.
+var marcTagFields = []string{"650", "651", "655", "653"}
+
+// marcName returns a personal name in direct order: "Donovan, Alan A. A.,"
+// becomes "Alan A. A. Donovan" when the first indicator marks a surname.
+func marcName(f marcField) string {
+	name := trimISBD(f.subfield('a'))
+
+	if f.Ind1 == '1' {
+		if surname, forename, ok := strings.Cut(name, ","); ok {
+			name = strings.TrimSpace(forename) + " " + strings.TrimSpace(surname)
+		}
+	}
+
+	return strings.TrimSpace(name)
+}
+
+func marcPages(extent string) int {
+	match := marcPagesRe.FindStringSubmatch(extent)
+	if match == nil {
+		digits := marcDigitRe.FindAllString(extent, -1)
+		if len(digits) == 0 {
+			return 0
+		}
+
+		match = []string{"", digits[len(digits)-1]}
+	}
+
+	pages, _ := strconv.Atoi(match[1])
+
+	return pages
+}
+
+// trimISBD removes the ISBD punctuation that closes MARC subfields, e.g. the
+// " /" at the end of a title or the "," at the end of a publisher name.
+func trimISBD(s string) string {
+	s = strings.TrimSpace(s)
+
+	for {
+		trimmed := strings.TrimSpace(strings.TrimRight(s, "/:;,="))
+		if strings.HasSuffix(trimmed, ".") && !strings.HasSuffix(trimmed, "..") && !isInitial(trimmed) {
+			trimmed = strings.TrimSuffix(trimmed, ".")
+		}
+
+		if trimmed == s {
+			return s
+		}
+
+		s = trimmed
+	}
+}
+
+// isInitial reports whether s ends with initials such as "A." or "J.R.R.",
+// in which case the final period belongs to the name.
+func isInitial(s string) bool {
+	return marcInitialsRe.MatchString(s)
+}
+
+// ErrRecordTooLong is returned when a book does not fit in an ISO 2709 record.
+var ErrRecordTooLong = errors.New("marc record too long")
+
+// WriteMARC encodes books as a stream of MARC 21 records in the ISO 2709
+// exchange format, using UTF-8 as character coding scheme.
+func WriteMARC(w io.Writer, books []domain.Book) error {
+	for _, book := range books {
+		raw, err := encodeISO2709(bookToMARC(book))
+		if err != nil {
+			return fmt.Errorf("catalog.writemarc %s: %w", book.ID, err)
+		}
+
+		if _, err := w.Write(raw); err != nil {
+			return fmt.Errorf("catalog.writemarc: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func encodeISO2709(record marcRecord) ([]byte, error) {
+	var directory, data bytes.Buffer
+
+	for _, f := range record.Fields {
+		start := data.Len()
+
+		if f.isControl() {
+			data.WriteString(f.Value)
+		} else {
+			data.WriteByte(f.Ind1)
+			data.WriteByte(f.Ind2)
+
+			for _, sf := range f.Subfields {
+				data.WriteByte(marcSubfieldDelimiter)
+				data.WriteByte(sf.Code)
+				data.WriteString(sf.Value)
+			}
+		}
+
+		data.WriteByte(marcFieldTerminator)
+
+		length := data.Len() - start
+		if length > 9999 || start > 99999 {
+			return nil, ErrRecordTooLong
+		}
+
+		fmt.Fprintf(&directory, "%s%04d%05d", f.Tag, length, start)
+	}
+
+	directory.WriteByte(marcFieldTerminator)
+
+	base := marcLeaderLength + directory.Len()
+	length := base + data.Len() + 1
+
+	if length > 99999 {
+		return nil, ErrRecordTooLong
+	}
+
+	// Positions 05-11 and 17-23 are taken from the record leader, the record
+	// length (00-04) and the base address of data (12-16) are computed.
+	leader := []byte(record.Leader)
+	copy(leader[0:5], fmt.Sprintf("%05d", length))
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+
+	raw := make([]byte, 0, length)
+	raw = append(raw, leader...)
+	raw = append(raw, directory.Bytes()...)
+	raw = append(raw, data.Bytes()...)
+	raw = append(raw, marcRecordTerminator)
+
+	return raw, nil
+}
+
+// marcLeader is the leader of the records created from books: a new (n)
+// monograph (m) of language material (a), UTF-8 encoded (a) and cataloged
+// following ISBD punctuation (i).
+const marcLeader = "00000nam a2200000 i 4500"
+
+// bookToMARC maps a book to a bibliographic record, it is the inverse of
+// marcToNewBook.
+func bookToMARC(book domain.Book) marcRecord {
+	record := marcRecord{Leader: marcLeader}
+	record.Fields = append(record.Fields, marcField{Tag: "001", Value: book.ID.String()})
+
+	if book.ISBN != "" {
+		record.Fields = append(record.Fields, marcDataField("020", ' ', ' ', 'a', domain.NormalizeISBN(book.ISBN)))
+	}
+
+	authors := splitAuthors(book.Authors)
+	if len(authors) > 0 {
+		record.Fields = append(record.Fields, marcDataField("100", '1', ' ', 'a', invertName(authors[0])))
+	}
+
+	title := marcField{Tag: "245", Ind1: '1', Ind2: '0'}
+	if len(authors) == 0 {
+		// No main entry, the title is the main entry.
+		title.Ind1 = '0'
+	}
+
+	if main, sub, ok := strings.Cut(book.Title, ": "); ok {
+		title.Subfields = []marcSubfield{{Code: 'a', Value: main + " :"}, {Code: 'b', Value: sub}}
+	} else {
+		title.Subfields = []marcSubfield{{Code: 'a', Value: book.Title}}
+	}
+
+	record.Fields = append(record.Fields, title)
+
+	if book.Publisher != "" {
+		record.Fields = append(record.Fields, marcDataField("264", ' ', '1', 'b', book.Publisher))
+	}
+
+	if book.Pages > 0 {
+		record.Fields = append(record.Fields, marcDataField("300", ' ', ' ', 'a', fmt.Sprintf("%d pages", book.Pages)))
+	}
+
+	// The tags are not controlled headings, they are index terms.
+	for _, tag := range book.Tags {
+		record.Fields = append(record.Fields, marcDataField("653", ' ', ' ', 'a', tag))
+	}
+
+	for _, author := range authors[min(1, len(authors)):] {
+		record.Fields = append(record.Fields, marcDataField("700", '1', ' ', 'a', invertName(author)))
+	}
+
+	return record
+}
+
+func marcDataField(tag string, ind1, ind2, code byte, value string) marcField {
+	return marcField{
+		Tag:       tag,
+		Ind1:      ind1,
+		Ind2:      ind2,
+		Subfields: []marcSubfield{{Code: code, Value: value}},
+	}
+}
+
+// invertName returns a personal name in inverted order: "Alan A. A. Donovan"
+// becomes "Donovan, Alan A. A.".
+func invertName(name string) string {
+	given, family := splitName(name)
+	if given == "" {
+		return family
+	}
+
+	return family + ", " + given
+}
@@ -0,0 +1,172 @@
+package catalog_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var goplNewBook = domain.NewBook{
+	Title:     "The Go programming language",
+	Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+	Publisher: "Addison-Wesley",
+	Pages:     380,
+	ISBN:      "9780134190440",
+}
+
+func TestReadMARC(t *testing.T) {
+	f, err := os.Open("testdata/books.mrc")
+	require.NoError(t, err)
+	defer f.Close()
+
+	records, err := catalog.ReadMARC(f)
+
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, catalog.Record{Position: 1, Book: goplNewBook}, records[0])
+	assert.Equal(t, catalog.Record{
+		Position: 2,
+		Book: domain.NewBook{
+			Title:     "The hobbit: or, There and back again",
+			Authors:   "J. R. R. Tolkien",
+			Publisher: "Houghton Mifflin Harcourt",
+			Pages:     300,
+			ISBN:      "9780547928227",
+		},
+	}, records[1])
+	assert.Equal(t, 3, records[2].Position)
+	assert.ErrorIs(t, records[2].Err, catalog.ErrMalformed)
+}
+
+func TestReadMARCMalformedDirectory(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, catalog.WriteMARC(&buf, []domain.Book{goplBook}))
+
+	// The first directory entry, 001 of 37 bytes at 0, starts at byte 24.
+	for _, entry := range []string{"001-00100000", "001+03700000", "0010037-0001", "001000000000", "001 03700000"} {
+		t.Run(entry, func(t *testing.T) {
+			raw := bytes.Clone(buf.Bytes())
+			copy(raw[24:36], entry)
+
+			records, err := catalog.ReadMARC(bytes.NewReader(raw))
+
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+			assert.ErrorIs(t, records[0].Err, catalog.ErrMalformed)
+		})
+	}
+
+	t.Run("Unterminated", func(t *testing.T) {
+		// The directory terminator precedes the base address of data, the
+		// 001 field of 37 bytes is the first one after it.
+		base, err := strconv.Atoi(string(buf.Bytes()[12:17]))
+		require.NoError(t, err)
+
+		for _, at := range []int{base - 1, base + 36} {
+			raw := bytes.Clone(buf.Bytes())
+			raw[at] = ' '
+
+			records, err := catalog.ReadMARC(bytes.NewReader(raw))
+
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+			assert.ErrorIs(t, records[0].Err, catalog.ErrMalformed)
+		}
+	})
+}
+
+func TestReadMARCXML(t *testing.T) {
+	f, err := os.Open("testdata/books.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	records, err := catalog.ReadMARCXML(f)
+
+	// The subject headings and genres become tags, without duplicates.
+	book := goplNewBook
+	book.Tags = []string{"Go (Computer program language)", "Handbooks and manuals"}
+
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, catalog.Record{Position: 1, Book: book}, records[0])
+	assert.Equal(t, 2, records[1].Position)
+	assert.ErrorIs(t, records[1].Err, catalog.ErrMalformed)
+}
+
+var goplBook = domain.Book{
+	ID:        uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812"),
+	Title:     "The Go Programming Language",
+	Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+	Publisher: "Addison-Wesley Professional",
+	Pages:     400,
+	ISBN:      "978-0134190440",
+}
+
+func TestWriteMARC(t *testing.T) {
+	hobbit := domain.Book{
+		ID:        uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c813"),
+		Title:     "The Hobbit: There and Back Again",
+		Authors:   "J.R.R. Tolkien",
+		Publisher: "Houghton Mifflin Harcourt",
+		Pages:     300,
+		ISBN:      "978-0547928227",
+		Tags:      []string{"fantasy", "classics"},
+	}
+
+	var buf bytes.Buffer
+	err := catalog.WriteMARC(&buf, []domain.Book{goplBook, hobbit})
+
+	require.NoError(t, err)
+
+	raw := buf.Bytes()
+	end := bytes.IndexByte(raw, 0x1D)
+	first := raw[:end+1]
+
+	assert.Equal(t, fmt.Sprintf("%05d", len(first)), string(first[0:5]))
+	assert.Equal(t, "nam a22", string(first[5:12]))
+	assert.Equal(t, " i 4500", string(first[17:24]))
+	assert.Equal(t, "001003700000", string(first[24:36]))
+
+	// The encoded records are decoded back to the same books.
+	records, err := catalog.ReadMARC(&buf)
+
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, domain.NewBook{
+		Title:     goplBook.Title,
+		Authors:   goplBook.Authors,
+		Publisher: goplBook.Publisher,
+		Pages:     goplBook.Pages,
+		ISBN:      "9780134190440",
+	}, records[0].Book)
+	assert.Equal(t, hobbit.Title, records[1].Book.Title)
+	assert.Equal(t, hobbit.Authors, records[1].Book.Authors)
+	assert.Equal(t, hobbit.Tags, records[1].Book.Tags)
+}
+
+func TestWriteMARCXML(t *testing.T) {
+	var buf bytes.Buffer
+	err := catalog.WriteMARCXML(&buf, []domain.Book{goplBook})
+
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `<collection xmlns="http://www.loc.gov/MARC21/slim">`)
+	assert.Contains(t, buf.String(), `<controlfield tag="001">ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812</controlfield>`)
+	assert.Contains(t, buf.String(), `<datafield tag="100" ind1="1" ind2=" ">`)
+	assert.Contains(t, buf.String(), `<subfield code="a">Donovan, Alan A. A.</subfield>`)
+
+	records, err := catalog.ReadMARCXML(&buf)
+
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, goplBook.Title, records[0].Book.Title)
+	assert.Equal(t, goplBook.Authors, records[0].Book.Authors)
+	assert.Equal(t, goplBook.Pages, records[0].Book.Pages)
+}
@@ -0,0 +1,166 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// MARCXMLNamespace is the XML namespace of the MARC 21 XML schema.
+const MARCXMLNamespace = "http://www.loc.gov/MARC21/slim"
+
+type marcxmlCollection struct {
+	XMLName xml.Name        `xml:"collection"`
+	XMLNS   string          `xml:"xmlns,attr"`
+	Records []marcxmlRecord `xml:"record"`
+}
+
+type marcxmlRecord struct {
+	XMLName       xml.Name              `xml:"record"`
+	Leader        string                `xml:"leader"`
+	ControlFields []marcxmlControlField `xml:"controlfield,omitempty"`
+	DataFields    []marcxmlDataField    `xml:"datafield"`
+}
+
+type marcxmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcxmlDataField struct {
+	Tag       string            `xml:"tag,attr"`
+	Ind1      string            `xml:"ind1,attr"`
+	Ind2      string            `xml:"ind2,attr"`
+	Subfields []marcxmlSubfield `xml:"subfield"`
+}
+
+type marcxmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ReadMARCXML decodes the records of a MARCXML document, either a single
+// record or a collection. Records that cannot be decoded are returned with
+// an error, their position is the index of the record in the document
+// starting from 1.
+func ReadMARCXML(r io.Reader) ([]Record, error) {
+	decoder := xml.NewDecoder(r)
+
+	var records []Record
+
+	for position := 1; ; {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("catalog.readmarcxml: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var xr marcxmlRecord
+		if err := decoder.DecodeElement(&xr, &start); err != nil {
+			return nil, fmt.Errorf("catalog.readmarcxml: %w", err)
+		}
+
+		record, err := xr.toMARC()
+		if err != nil {
+			records = append(records, Record{Position: position, Err: fmt.Errorf("%w: %w", ErrMalformed, err)})
+		} else {
+			records = append(records, Record{Position: position, Book: marcToNewBook(record)})
+		}
+
+		position++
+	}
+
+	return records, nil
+}
+
+func (xr marcxmlRecord) toMARC() (marcRecord, error) {
+	record := marcRecord{Leader: xr.Leader}
+
+	for _, cf := range xr.ControlFields {
+		record.Fields = append(record.Fields, marcField{Tag: cf.Tag, Value: cf.Value})
+	}
+
+	for _, df := range xr.DataFields {
+		if len(df.Tag) != 3 {
+			return marcRecord{}, fmt.Errorf("invalid tag %q", df.Tag)
+		}
+
+		field := marcField{Tag: df.Tag, Ind1: indicator(df.Ind1), Ind2: indicator(df.Ind2)}
+
+		for _, sf := range df.Subfields {
+			if len(sf.Code) != 1 {
+				return marcRecord{}, fmt.Errorf("field %s: invalid subfield code %q", df.Tag, sf.Code)
+			}
+
+			field.Subfields = append(field.Subfields, marcSubfield{Code: sf.Code[0], Value: sf.Value})
+		}
+
+		record.Fields = append(record.Fields, field)
+	}
+
+	return record, nil
+}
+
+func indicator(s string) byte {
+	if s == "" {
+		return ' '
+	}
+
+	return s[0]
+}
+
+// WriteMARCXML encodes books as a MARCXML collection.
+func WriteMARCXML(w io.Writer, books []domain.Book) error {
+	collection := marcxmlCollection{
+		XMLNS:   MARCXMLNamespace,
+		Records: make([]marcxmlRecord, len(books)),
+	}
+
+	for i, book := range books {
+		collection.Records[i] = toMARCXML(bookToMARC(book))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("catalog.writemarcxml: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(collection); err != nil {
+		return fmt.Errorf("catalog.writemarcxml: %w", err)
+	}
+
+	return nil
+}
+
+func toMARCXML(record marcRecord) marcxmlRecord {
+	xr := marcxmlRecord{Leader: record.Leader}
+
+	for _, f := range record.Fields {
+		if f.isControl() {
+			xr.ControlFields = append(xr.ControlFields, marcxmlControlField{Tag: f.Tag, Value: f.Value})
+			continue
+		}
+
+		df := marcxmlDataField{Tag: f.Tag, Ind1: string(f.Ind1), Ind2: string(f.Ind2)}
+		for _, sf := range f.Subfields {
+			df.Subfields = append(df.Subfields, marcxmlSubfield{Code: string(sf.Code), Value: sf.Value})
+		}
+
+		xr.DataFields = append(xr.DataFields, df)
+	}
+
+	return xr
+}
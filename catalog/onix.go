@@ -0,0 +1,242 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// ONIX 3.0 code list values used to map a product to a book.
+const (
+	onixNotificationDelete = "05"  // List 1: delete
+	onixIDTypeISBN10       = "02"  // List 5: ISBN-10
+	onixIDTypeISBN13       = "15"  // List 5: ISBN-13
+	onixTitleTypeDistinct  = "01"  // List 15: distinctive title
+	onixTitleLevelProduct  = "01"  // List 149: product level
+	onixRoleAuthor         = "A01" // List 17: by (author)
+	onixExtentMainContent  = "00"  // List 23: main content page count
+	onixExtentContent      = "11"  // List 23: content page count
+	onixExtentUnitPages    = "03"  // List 24: pages
+	onixPublishingRole     = "01"  // List 45: publisher
+	onixSubjectKeywords    = "20"  // List 27: keywords
+)
+
+type onixProduct struct {
+	RecordReference    string                  `xml:"RecordReference"`
+	NotificationType   string                  `xml:"NotificationType"`
+	ProductIdentifiers []onixProductIdentifier `xml:"ProductIdentifier"`
+	TitleDetails       []onixTitleDetail       `xml:"DescriptiveDetail>TitleDetail"`
+	Contributors       []onixContributor       `xml:"DescriptiveDetail>Contributor"`
+	Extents            []onixExtent            `xml:"DescriptiveDetail>Extent"`
+	Subjects           []onixSubject           `xml:"DescriptiveDetail>Subject"`
+	Publishers         []onixPublisher         `xml:"PublishingDetail>Publisher"`
+}
+
+type onixProductIdentifier struct {
+	ProductIDType string `xml:"ProductIDType"`
+	IDValue       string `xml:"IDValue"`
+}
+
+type onixTitleDetail struct {
+	TitleType     string             `xml:"TitleType"`
+	TitleElements []onixTitleElement `xml:"TitleElement"`
+}
+
+type onixTitleElement struct {
+	TitleElementLevel  string `xml:"TitleElementLevel"`
+	TitleText          string `xml:"TitleText"`
+	TitlePrefix        string `xml:"TitlePrefix"`
+	TitleWithoutPrefix string `xml:"TitleWithoutPrefix"`
+	Subtitle           string `xml:"Subtitle"`
+}
+
+type onixContributor struct {
+	ContributorRoles []string `xml:"ContributorRole"`
+	PersonName       string   `xml:"PersonName"`
+	NamesBeforeKey   string   `xml:"NamesBeforeKey"`
+	KeyNames         string   `xml:"KeyNames"`
+	CorporateName    string   `xml:"CorporateName"`
+}
+
+type onixExtent struct {
+	ExtentType  string `xml:"ExtentType"`
+	ExtentValue string `xml:"ExtentValue"`
+	ExtentUnit  string `xml:"ExtentUnit"`
+}
+
+type onixSubject struct {
+	SubjectSchemeIdentifier string `xml:"SubjectSchemeIdentifier"`
+	SubjectHeadingText      string `xml:"SubjectHeadingText"`
+}
+
+type onixPublisher struct {
+	PublishingRole string `xml:"PublishingRole"`
+	PublisherName  string `xml:"PublisherName"`
+}
+
+// ReadONIX decodes the products of an ONIX for Books 3.0 message using the
+// reference tag names. The position of a record is the index of the product
+// in the message starting from 1, delete notifications are returned as
+// malformed records since there is nothing to import.
+//
+// The ISBN-13 is preferred over the ISBN-10, authors are the contributors with
+// the A01 role and keywords (subject scheme 20) become the tags of the book.
+func ReadONIX(r io.Reader) ([]Record, error) {
+	decoder := xml.NewDecoder(r)
+
+	var records []Record
+
+	for position := 1; ; {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("catalog.readonix: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Product" {
+			continue
+		}
+
+		var product onixProduct
+		if err := decoder.DecodeElement(&product, &start); err != nil {
+			return nil, fmt.Errorf("catalog.readonix: %w", err)
+		}
+
+		record := Record{Position: position}
+		if product.NotificationType == onixNotificationDelete {
+			record.Err = fmt.Errorf("%w: product %q is a delete notification", ErrMalformed, product.RecordReference)
+		} else {
+			record.Book, record.Err = product.toNewBook()
+		}
+
+		records = append(records, record)
+		position++
+	}
+
+	return records, nil
+}
+
+func (p onixProduct) toNewBook() (domain.NewBook, error) {
+	nb := domain.NewBook{
+		Title:   p.title(),
+		Authors: joinAuthors(p.authors()),
+		ISBN:    p.isbn(),
+	}
+
+	for _, publisher := range p.Publishers {
+		if publisher.PublishingRole == onixPublishingRole || nb.Publisher == "" {
+			nb.Publisher = strings.TrimSpace(publisher.PublisherName)
+		}
+	}
+
+	for _, subject := range p.Subjects {
+		if subject.SubjectSchemeIdentifier == onixSubjectKeywords {
+			nb.Tags = splitTags(";", subject.SubjectHeadingText)
+		}
+	}
+
+	var err error
+
+	nb.Pages, err = parsePages(p.pages())
+
+	return nb, err
+}
+
+func (p onixProduct) isbn() string {
+	var isbn string
+
+	for _, id := range p.ProductIdentifiers {
+		switch id.ProductIDType {
+		case onixIDTypeISBN13:
+			return strings.TrimSpace(id.IDValue)
+		case onixIDTypeISBN10:
+			isbn = strings.TrimSpace(id.IDValue)
+		}
+	}
+
+	return isbn
+}
+
+func (p onixProduct) title() string {
+	for _, detail := range p.TitleDetails {
+		if detail.TitleType != onixTitleTypeDistinct {
+			continue
+		}
+
+		for _, element := range detail.TitleElements {
+			if element.TitleElementLevel != onixTitleLevelProduct {
+				continue
+			}
+
+			title := element.TitleText
+			if title == "" {
+				title = strings.TrimSpace(element.TitlePrefix + " " + element.TitleWithoutPrefix)
+			}
+
+			if element.Subtitle != "" {
+				title += ": " + element.Subtitle
+			}
+
+			return strings.TrimSpace(title)
+		}
+	}
+
+	return ""
+}
+
+// authors returns the names of the authors or, when no contributor has the
+// author role, of all the contributors.
+func (p onixProduct) authors() []string {
+	var authors, others []string
+
+	for _, c := range p.Contributors {
+		name := c.PersonName
+		if name == "" {
+			name = strings.TrimSpace(c.NamesBeforeKey + " " + c.KeyNames)
+		}
+
+		if name == "" {
+			name = c.CorporateName
+		}
+
+		if slices.Contains(c.ContributorRoles, onixRoleAuthor) {
+			authors = append(authors, name)
+		} else {
+			others = append(others, name)
+		}
+	}
+
+	if len(authors) == 0 {
+		return others
+	}
+
+	return authors
+}
+
+func (p onixProduct) pages() string {
+	var pages string
+
+	for _, extent := range p.Extents {
+		if extent.ExtentUnit != onixExtentUnitPages {
+			continue
+		}
+
+		switch extent.ExtentType {
+		case onixExtentMainContent:
+			return strings.TrimSpace(extent.ExtentValue)
+		case onixExtentContent:
+			pages = strings.TrimSpace(extent.ExtentValue)
+		}
+	}
+
+	return pages
+}
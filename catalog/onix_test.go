@@ -0,0 +1,35 @@
+package catalog_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadONIX(t *testing.T) {
+	f, err := os.Open("testdata/onix.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	records, err := catalog.ReadONIX(f)
+
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, catalog.Record{
+		Position: 1,
+		Book: domain.NewBook{
+			Title:     "The Go Programming Language",
+			Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+			Publisher: "Addison-Wesley",
+			Pages:     380,
+			ISBN:      "9780134190440",
+			Tags:      []string{"go", "programming"},
+		},
+	}, records[0])
+	assert.Equal(t, 2, records[1].Position)
+	assert.ErrorIs(t, records[1].Err, catalog.ErrMalformed)
+}
@@ -0,0 +1,111 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// table is a delimited document (CSV or TSV) whose first row is a header.
+type table struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// newTable reads the header of a document delimited by comma. A UTF-8 byte
+// order mark, as written by spreadsheet applications, is ignored.
+func newTable(r io.Reader, comma rune) (*table, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	// Leading white space would include the empty fields of a TSV document.
+	reader.TrimLeadingSpace = comma != '\t'
+	reader.LazyQuotes = comma == '\t'
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	return &table{reader: reader, columns: columns}, nil
+}
+
+// require returns ErrMissingColumn when the header lacks one of the columns.
+func (t *table) require(names ...string) error {
+	for _, name := range names {
+		if _, ok := t.columns[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("%w %q", ErrMissingColumn, name)
+		}
+	}
+
+	return nil
+}
+
+// records decodes the rows following the header. decode is called with the
+// line of the row and a function returning the trimmed value of a column
+// (empty when the column or the value is missing), rows that cannot be parsed
+// are returned as malformed records.
+func (t *table) records(decode func(line int, value func(name string) string) Record) ([]Record, error) {
+	var records []Record
+
+	for {
+		row, err := t.reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return nil, err
+			}
+
+			records = append(records, Record{Position: perr.StartLine, Err: fmt.Errorf("%w: %w", ErrMalformed, err)})
+
+			continue
+		}
+
+		line, _ := t.reader.FieldPos(0)
+		value := func(name string) string {
+			if i, ok := t.columns[strings.ToLower(name)]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+
+			return ""
+		}
+
+		records = append(records, decode(line, value))
+	}
+
+	return records, nil
+}
+
+// sniffComma returns a reader equivalent to r and the delimiter of the
+// document, a tab when the header row contains one, a comma otherwise.
+func sniffComma(r io.Reader) (io.Reader, rune) {
+	br := bufio.NewReader(r)
+
+	header, _ := br.Peek(br.Size())
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	if bytes.IndexByte(header, '\t') >= 0 {
+		return br, '\t'
+	}
+
+	return br, ','
+}
@@ -0,0 +1,418 @@
+// Command catalog imports books into and exports books out of the catalog.
+//
+// Usage:
+//
+//	catalog import [-dry-run] [-format FORMAT] [-columns MAPPING] FILE
+//	catalog export [-format FORMAT] [-columns MAPPING] [-o FILE]
+//	catalog repair [-fix] [-quarantine TABLE]
+//	catalog migrate [-from TABLE] [-state FILE]
+//
+// The repair command lists the items of the table that cannot be decoded into
+// books and, with -fix, rewrites the fixable ones and moves the others into
+// the quarantine table, if given.
+//
+// The migrate command upgrades all the items of the table to the current
+// schema version, printing its progress after every page of the scan. With
+// -from, the items are copied from another table instead. With -state, the
+// position reached is saved into FILE, so that an interrupted migration
+// started again with the same FILE resumes from there.
+//
+// The storage is configured with the same environment variables used by the
+// AWS Lambda functions (DB_TABLE, ID_STRATEGY and the ones read by
+// ddb.OptionsFromEnv, e.g. DB_ENDPOINT to use DynamoDB Local). With
+// DB_DRIVER=sqlite or DB_DRIVER=bolt, import and export use the SQLite or
+// bbolt database at DB_PATH instead, with DB_DRIVER=postgres the PostgreSQL
+// database at DB_URL. With DB_DRIVER=memory, the books are kept in the
+// snapshot file at DB_PATH and its log (DB_PATH.log), seeded from the
+// fixtures at DB_SEED when empty.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/database/bolt"
+	"github.com/rotiroti/alessandrina/sys/database/ddb"
+	"github.com/rotiroti/alessandrina/sys/database/memory"
+	"github.com/rotiroti/alessandrina/sys/database/postgres"
+	"github.com/rotiroti/alessandrina/sys/database/sqlite"
+)
+
+const usage = `usage:
+  catalog import [-dry-run] [-format FORMAT] [-columns MAPPING] FILE
+  catalog export [-format FORMAT] [-columns MAPPING] [-o FILE]
+  catalog repair [-fix] [-quarantine TABLE]
+  catalog migrate [-from TABLE] [-state FILE]`
+
+// errUsage is returned when the command line arguments are invalid.
+var errUsage = errors.New(usage)
+
+func main() {
+	ctx := context.Background()
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		log.Fatalf("catalog: %v\n", err)
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "import":
+		return runImport(ctx, args[1:], stdout)
+	case "export":
+		return runExport(ctx, args[1:], stdout)
+	case "repair":
+		return runRepair(ctx, args[1:], stdout)
+	case "migrate":
+		return runMigrate(ctx, args[1:], stdout)
+	default:
+		return errUsage
+	}
+}
+
+func newStore(ctx context.Context, opts ...ddb.Option) (*ddb.Store, error) {
+	dbTable := getEnv("DB_TABLE", "")
+
+	envOpts, err := ddb.OptionsFromEnv(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	return ddb.NewStore(ctx, dbTable, append(envOpts, opts...)...)
+}
+
+// newStorer returns the storage selected by DB_DRIVER (dynamodb, sqlite,
+// postgres, bolt or memory, default: dynamodb) together with the function releasing
+// it.
+func newStorer(ctx context.Context) (domain.Storer, func(), error) {
+	switch driver := getEnv("DB_DRIVER", "dynamodb"); driver {
+	case "dynamodb":
+		store, err := newStore(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return store, func() {}, nil
+	case "sqlite":
+		store, err := sqlite.NewStore(ctx, getEnv("DB_PATH", ""))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return store, func() { store.Close() }, nil
+	case "postgres":
+		store, err := postgres.NewStore(ctx, getEnv("DB_URL", ""))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return store, store.Close, nil
+	case "bolt":
+		store, err := bolt.NewStore(getEnv("DB_PATH", ""))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return store, func() { store.Close() }, nil
+	case "memory":
+		path := getEnv("DB_PATH", "")
+		opts := []memory.Option{memory.WithSnapshot(path), memory.WithLog(path + ".log")}
+
+		if seed := getEnv("DB_SEED", ""); seed != "" {
+			opts = append(opts, memory.WithSeed(seed))
+		}
+
+		store, err := memory.Open(opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return store, func() { store.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid DB_DRIVER %q", driver)
+	}
+}
+
+func newBookCore(ctx context.Context) (*domain.BookCore, func(), error) {
+	idStrategy := getEnv("ID_STRATEGY", domain.IDStrategyRandom)
+
+	generator, err := domain.NewIDGenerator(idStrategy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, closeStore, err := newStorer(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return domain.NewBookCoreWithIDGenerator(store, generator), closeStore, nil
+}
+
+func runImport(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "validate the file without saving the books")
+	formatName := fs.String("format", "csv", "format of the file ("+formatNames(func(f catalog.Format) bool { return f.Read != nil })+")")
+	columns := fs.String("columns", "", "comma separated field=header pairs overriding the default CSV headers")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	format, ok := catalog.FormatByName(*formatName)
+	if !ok || format.Read == nil {
+		return fmt.Errorf("format %q cannot be imported", *formatName)
+	}
+
+	mapping, err := catalog.ParseMapping(*columns)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records, err := format.Read(f, catalog.Options{Mapping: mapping})
+	if err != nil {
+		return err
+	}
+
+	bookCore, closeStore, err := newBookCore(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	report := catalog.NewImporter(bookCore).Import(ctx, records, *dryRun)
+
+	return printReport(stdout, report)
+}
+
+func runExport(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := fs.String("format", "csv", "format of the file ("+formatNames(func(f catalog.Format) bool { return f.Write != nil })+")")
+	columns := fs.String("columns", "", "comma separated field=header pairs overriding the default CSV headers")
+	output := fs.String("o", "", "write the exported file instead of the standard output")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, ok := catalog.FormatByName(*formatName)
+	if !ok || format.Write == nil {
+		return fmt.Errorf("format %q cannot be exported", *formatName)
+	}
+
+	mapping, err := catalog.ParseMapping(*columns)
+	if err != nil {
+		return err
+	}
+
+	bookCore, closeStore, err := newBookCore(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	books, err := bookCore.FindEvery(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := stdout
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	return format.Write(w, books, catalog.Options{Mapping: mapping})
+}
+
+func runRepair(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("repair", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "rewrite the fixable items instead of only listing them")
+	quarantine := fs.String("quarantine", "", "with -fix, move the items that cannot be fixed into this table")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+
+	var opts []ddb.Option
+	if *quarantine != "" {
+		opts = append(opts, ddb.WithQuarantine(*quarantine))
+	}
+
+	store, err := newStore(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	malformed, err := store.FindMalformed(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(stdout, "found %d malformed items\n", len(malformed)); err != nil {
+		return err
+	}
+
+	for _, item := range malformed {
+		if !*fix {
+			if _, err := fmt.Fprintf(stdout, "item %q: %v\n", item.ID, item.Err); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		action, err := store.Repair(ctx, item)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(stdout, "item %q: %s: %v\n", item.ID, action, item.Err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runMigrate(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := fs.String("from", "", "copy the books from this table, e.g. a table with the layout used before the single-table design")
+	stateFile := fs.String("state", "", "file keeping the position reached, to resume an interrupted migration")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+
+	var startAfter map[string]string
+
+	if *stateFile != "" {
+		state, err := os.ReadFile(*stateFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if len(state) > 0 {
+			if err := json.Unmarshal(state, &startAfter); err != nil {
+				return fmt.Errorf("state file %s: %w", *stateFile, err)
+			}
+
+			if _, err := fmt.Fprintf(stdout, "resuming after %v\n", startAfter); err != nil {
+				return err
+			}
+		}
+	}
+
+	store, err := newStore(ctx)
+	if err != nil {
+		return err
+	}
+
+	progress, err := store.Migrate(ctx, *from, startAfter, func(p ddb.MigrationProgress) error {
+		if *stateFile != "" {
+			state, err := json.Marshal(p.LastKey)
+			if err != nil {
+				return err
+			}
+
+			if err := os.WriteFile(*stateFile, state, 0o600); err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(stdout, "scanned %d items, upgraded %d, failed %d\n", p.Scanned, p.Upgraded, p.Failed)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if *stateFile != "" {
+		if err := os.Remove(*stateFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if progress.Failed > 0 {
+		_, err = fmt.Fprintf(stdout, "%d items could not be upgraded, see catalog repair\n", progress.Failed)
+	}
+
+	return err
+}
+
+// formatNames returns the comma separated names of the catalog formats
+// matching the filter.
+func formatNames(filter func(catalog.Format) bool) string {
+	var names []string
+
+	for _, f := range catalog.Formats() {
+		if filter(f) {
+			names = append(names, f.Name)
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func printReport(w io.Writer, report catalog.Report) error {
+	verb := "imported"
+	if report.DryRun {
+		verb = "would import"
+	}
+
+	if _, err := fmt.Fprintf(w, "%s %d of %d books\n", verb, len(report.Imported), report.Total); err != nil {
+		return err
+	}
+
+	for _, rerr := range report.Failed {
+		if _, err := fmt.Fprintf(w, "record %d: %v\n", rerr.Position, rerr.Err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
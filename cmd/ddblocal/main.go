@@ -0,0 +1,41 @@
+// Command ddblocal serves the DynamoDB API from memory, a stand-in for
+// DynamoDB Local or LocalStack that needs neither Docker nor Java.
+//
+// Usage:
+//
+//	ddblocal [-addr ADDR] [-table NAME]
+//
+// The tables are lost when the command stops. With -table (DB_TABLE by
+// default), the table of the catalog is created at startup, otherwise it can
+// be created with scripts/create-table.sh. The command-line tools and the
+// functions use it with DB_ENDPOINT=http://ADDR and any credentials.
+//
+// The server is the ddbtest.Client, it supports the operations used by the
+// ddb package, see its documentation for the parts of the API left out.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/rotiroti/alessandrina/sys/database/ddb/ddbtest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8000", "listen on `address`")
+	table := flag.String("table", os.Getenv("DB_TABLE"), "create the catalog table `name` at startup")
+	flag.Parse()
+
+	var tables []ddbtest.Table
+	if *table != "" {
+		tables = append(tables, ddbtest.CatalogTable(*table))
+	}
+
+	log.Printf("ddblocal: serving DynamoDB on http://%s\n", *addr)
+
+	if err := http.ListenAndServe(*addr, ddbtest.NewClient(tables...)); err != nil {
+		log.Fatalf("ddblocal: %v\n", err)
+	}
+}
@@ -8,38 +8,48 @@ import (
 	"github.com/google/uuid"
 )
 
-var (
-	// ErrNotFound is used when a specific Book is requested but does not exist.
-	ErrNotFound = errors.New("book not found")
-
-	// ErrAlreadyExists is used when a specific Book is created but already exists.
-	ErrAlreadyExists = errors.New("book already exists")
-)
-
 // UUIDGenerator is a function that returns a UUID.
 type UUIDGenerator func() uuid.UUID
 
 // Storer is the interface used to interact with a storage.
 type Storer interface {
 	Save(ctx context.Context, book Book) error
+	SaveMany(ctx context.Context, books []Book) error
 	FindAll(ctx context.Context) ([]Book, error)
+	FindMany(ctx context.Context, bookIDs []uuid.UUID) ([]Book, error)
+	FindPage(ctx context.Context, after uuid.UUID, limit int) ([]Book, error)
 	FindOne(ctx context.Context, bookID uuid.UUID) (Book, error)
+	Update(ctx context.Context, book Book) error
 	Delete(ctx context.Context, bookID uuid.UUID) error
+
+	// CompareAndSwap replaces each book of previous with the book of books at
+	// the same index, which has the same ID, all at once: provided none of
+	// them changed since it was read (see Book.Equal), otherwise nothing is
+	// written and ErrPreconditionFailed is returned.
+	CompareAndSwap(ctx context.Context, previous, books []Book) error
 }
 
 // BookCore manages the set of APIs for book access.
 type BookCore struct {
 	storer    Storer
-	generator UUIDGenerator
+	generator IDGenerator
 }
 
 // NewBookCore constructs a core for book API access.
 func NewBookCore(storer Storer) *BookCore {
-	return NewBookCoreWithGenerator(storer, uuid.New)
+	return NewBookCoreWithIDGenerator(storer, RandomID)
 }
 
 // NewBookCore constructs a core for book API access with a custom UUIDGenerator.
 func NewBookCoreWithGenerator(storer Storer, generator UUIDGenerator) *BookCore {
+	return NewBookCoreWithIDGenerator(storer, func(NewBook) uuid.UUID {
+		return generator()
+	})
+}
+
+// NewBookCoreWithIDGenerator constructs a core for book API access with an
+// IDGenerator, e.g. one of the built-in strategies returned by NewIDGenerator.
+func NewBookCoreWithIDGenerator(storer Storer, generator IDGenerator) *BookCore {
 	return &BookCore{
 		storer:    storer,
 		generator: generator,
@@ -48,13 +58,18 @@ func NewBookCoreWithGenerator(storer Storer, generator UUIDGenerator) *BookCore
 
 // Save inserts a new book into a storage.
 func (c *BookCore) Save(ctx context.Context, nb NewBook) (Book, error) {
+	if err := nb.Validate(); err != nil {
+		return Book{}, fmt.Errorf("domain.save failed: %w", err)
+	}
+
 	book := Book{
-		ID:        c.generator(),
+		ID:        c.generator(nb),
 		Title:     nb.Title,
 		Authors:   nb.Authors,
 		Publisher: nb.Publisher,
 		Pages:     nb.Pages,
 		ISBN:      nb.ISBN,
+		Tags:      nb.Tags,
 	}
 
 	if err := c.storer.Save(ctx, book); err != nil {
@@ -64,6 +79,131 @@ func (c *BookCore) Save(ctx context.Context, nb NewBook) (Book, error) {
 	return book, nil
 }
 
+// Put saves a new book with a caller supplied ID: the book is created when
+// no book has that ID, otherwise the existing one is replaced. created reports
+// which of the two happened. A book merged into another one is not replaced,
+// a *MergedError is returned instead.
+//
+// Both writes are conditional, so a book concurrently created, changed or
+// deleted between them is detected and the other write is attempted once
+// more; ErrPreconditionFailed is returned if the book keeps changing.
+func (c *BookCore) Put(ctx context.Context, bookID uuid.UUID, nb NewBook) (Book, bool, error) {
+	if err := nb.Validate(); err != nil {
+		return Book{}, false, fmt.Errorf("domain.put failed: %w", err)
+	}
+
+	book := Book{
+		ID:        bookID,
+		Title:     nb.Title,
+		Authors:   nb.Authors,
+		Publisher: nb.Publisher,
+		Pages:     nb.Pages,
+		ISBN:      nb.ISBN,
+		Tags:      nb.Tags,
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		err := c.storer.Save(ctx, book)
+		if err == nil {
+			return book, true, nil
+		}
+
+		if !errors.Is(err, ErrAlreadyExists) {
+			return Book{}, false, fmt.Errorf("domain.put failed: %w", err)
+		}
+
+		existing, err := c.storer.FindOne(ctx, bookID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return Book{}, false, fmt.Errorf("domain.put failed: %w", err)
+		}
+
+		if existing.MergedInto != uuid.Nil {
+			return Book{}, false, fmt.Errorf("domain.put failed: %w", &MergedError{ID: existing.ID, Into: existing.MergedInto})
+		}
+
+		err = c.storer.CompareAndSwap(ctx, []Book{existing}, []Book{book})
+		if err == nil {
+			return book, false, nil
+		}
+
+		if !errors.Is(err, ErrPreconditionFailed) {
+			return Book{}, false, fmt.Errorf("domain.put failed: %w", err)
+		}
+	}
+
+	return Book{}, false, fmt.Errorf("domain.put failed: %w", ErrPreconditionFailed)
+}
+
+// SaveMany inserts a batch of new books into a storage.
+//
+// Every entry is validated and saved independently, so the returned results
+// (one for each NewBook, in the same order) report which books were created
+// and why the others were rejected.
+func (c *BookCore) SaveMany(ctx context.Context, nbs []NewBook) []SaveResult {
+	results := make([]SaveResult, len(nbs))
+	books := make([]Book, 0, len(nbs))
+	positions := make(map[uuid.UUID]int, len(nbs))
+
+	for i, nb := range nbs {
+		if err := nb.Validate(); err != nil {
+			results[i].Err = fmt.Errorf("domain.savemany failed: %w", err)
+			continue
+		}
+
+		book := Book{
+			ID:        c.generator(nb),
+			Title:     nb.Title,
+			Authors:   nb.Authors,
+			Publisher: nb.Publisher,
+			Pages:     nb.Pages,
+			ISBN:      nb.ISBN,
+			Tags:      nb.Tags,
+		}
+
+		// Deterministic IDs (e.g. derived from the ISBN) may repeat within
+		// a batch, only the first book with a given ID is saved.
+		if _, exists := positions[book.ID]; exists {
+			results[i].Err = fmt.Errorf("domain.savemany failed: %w", ErrAlreadyExists)
+			continue
+		}
+
+		books = append(books, book)
+		positions[book.ID] = i
+		results[i].Book = book
+	}
+
+	if len(books) == 0 {
+		return results
+	}
+
+	err := c.storer.SaveMany(ctx, books)
+	if err == nil {
+		return results
+	}
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		// The storage failed as a whole, none of the books can be considered saved.
+		for _, book := range books {
+			results[positions[book.ID]] = SaveResult{Err: fmt.Errorf("domain.savemany failed: %w", err)}
+		}
+
+		return results
+	}
+
+	for bookID, bookErr := range batchErr.Failed {
+		if i, ok := positions[bookID]; ok {
+			results[i] = SaveResult{Err: fmt.Errorf("domain.savemany failed: %w", bookErr)}
+		}
+	}
+
+	return results
+}
+
 // FindAll returns all books from a storage.
 func (c *BookCore) FindAll(ctx context.Context) ([]Book, error) {
 	books, err := c.storer.FindAll(ctx)
@@ -71,16 +211,116 @@ func (c *BookCore) FindAll(ctx context.Context) ([]Book, error) {
 		return []Book{}, fmt.Errorf("domain.findall failed: %w", err)
 	}
 
-	return books, nil
+	return withoutMerged(books), nil
+}
+
+// FindMany returns the books matching the given IDs, in the same order, and
+// the IDs that do not match any book. Duplicated IDs are looked up once and
+// merged books are reported as missing.
+func (c *BookCore) FindMany(ctx context.Context, bookIDs []uuid.UUID) ([]Book, []uuid.UUID, error) {
+	unique := make([]uuid.UUID, 0, len(bookIDs))
+	seen := make(map[uuid.UUID]bool, len(bookIDs))
+
+	for _, bookID := range bookIDs {
+		if !seen[bookID] {
+			seen[bookID] = true
+			unique = append(unique, bookID)
+		}
+	}
+
+	found, err := c.storer.FindMany(ctx, unique)
+	if err != nil {
+		return []Book{}, []uuid.UUID{}, fmt.Errorf("domain.findmany failed: %w", err)
+	}
+
+	byID := make(map[uuid.UUID]Book, len(found))
+	for _, book := range withoutMerged(found) {
+		byID[book.ID] = book
+	}
+
+	books := make([]Book, 0, len(found))
+	missing := []uuid.UUID{}
+
+	for _, bookID := range unique {
+		if book, ok := byID[bookID]; ok {
+			books = append(books, book)
+		} else {
+			missing = append(missing, bookID)
+		}
+	}
+
+	return books, missing, nil
+}
+
+// FindPage returns up to limit books ordered by ID, starting after the book
+// identified by after (uuid.Nil for the first page). With time-ordered IDs
+// (see IDStrategyTime) books are listed in order of creation.
+//
+// Page.Next is the cursor of the following page, uuid.Nil on the last one.
+// Merged books are left out, so a page can hold fewer than limit books
+// without being the last one.
+func (c *BookCore) FindPage(ctx context.Context, after uuid.UUID, limit int) (Page, error) {
+	if limit < 1 {
+		return Page{}, fmt.Errorf("domain.findpage failed: %w", &ValidationError{Fields: []FieldError{
+			{Field: "limit", Err: "limit must be 1 or greater"},
+		}})
+	}
+
+	books, err := c.storer.FindPage(ctx, after, limit)
+	if err != nil {
+		return Page{}, fmt.Errorf("domain.findpage failed: %w", err)
+	}
+
+	page := Page{Books: withoutMerged(books)}
+	if len(books) == limit {
+		page.Next = books[len(books)-1].ID
+	}
+
+	return page, nil
+}
+
+// FindEveryPageSize is the number of books read at a time by FindEvery.
+const FindEveryPageSize = 100
+
+// FindEvery returns every book ordered by ID, reading the storage one page
+// at a time (see FindPage). Unlike FindAll, whose result some storages cap,
+// it covers the whole catalog, e.g. for an export.
+func (c *BookCore) FindEvery(ctx context.Context) ([]Book, error) {
+	var (
+		books []Book
+		after uuid.UUID
+	)
+
+	for {
+		page, err := c.FindPage(ctx, after, FindEveryPageSize)
+		if err != nil {
+			return []Book{}, fmt.Errorf("domain.findevery failed: %w", err)
+		}
+
+		books = append(books, page.Books...)
+
+		if page.Next == uuid.Nil {
+			return books, nil
+		}
+
+		after = page.Next
+	}
 }
 
 // FindOne returns a book from a storage by using bookID as primary key.
+//
+// A *MergedError, carrying the ID of the surviving book, is returned when
+// the book was merged into another one.
 func (c *BookCore) FindOne(ctx context.Context, bookID uuid.UUID) (Book, error) {
 	book, err := c.storer.FindOne(ctx, bookID)
 	if err != nil {
 		return Book{}, fmt.Errorf("domain.findone failed: %w", err)
 	}
 
+	if book.MergedInto != uuid.Nil {
+		return Book{}, fmt.Errorf("domain.findone failed: %w", &MergedError{ID: book.ID, Into: book.MergedInto})
+	}
+
 	return book, nil
 }
 
@@ -92,3 +332,16 @@ func (c *BookCore) Delete(ctx context.Context, bookID uuid.UUID) error {
 
 	return nil
 }
+
+// withoutMerged filters out the books that were merged into another one.
+func withoutMerged(books []Book) []Book {
+	ret := make([]Book, 0, len(books))
+
+	for _, book := range books {
+		if book.MergedInto == uuid.Nil {
+			ret = append(ret, book)
+		}
+	}
+
+	return ret
+}
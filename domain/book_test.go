@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/rotiroti/alessandrina/domain"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func setup(t *testing.T) (*domain.MockStorer, uuid.UUID, func() uuid.UUID) {
@@ -28,7 +29,7 @@ func TestBookCore(t *testing.T) {
 		Authors:   "Test Author",
 		Publisher: "Test Publisher",
 		Pages:     100,
-		ISBN:      "Test ISBN",
+		ISBN:      "978-0134190440",
 	}
 	expectedBook := domain.Book{
 		ID:        expectedID,
@@ -57,6 +58,54 @@ func TestBookCore(t *testing.T) {
 		storer.AssertExpectations(t)
 	})
 
+	t.Run("SaveInvalid", func(t *testing.T) {
+		coreWithGenerator := domain.NewBookCoreWithGenerator(storer, generator)
+		invalidBook := newBook
+		invalidBook.Pages = 0
+		createdBook, err := coreWithGenerator.Save(ctx, invalidBook)
+
+		assert.ErrorIs(t, err, domain.ErrInvalid)
+		assert.Equal(t, domain.Book{}, createdBook)
+		storer.AssertExpectations(t)
+	})
+
+	t.Run("SaveMany", func(t *testing.T) {
+		ids := []uuid.UUID{
+			uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812"),
+			uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c813"),
+		}
+		next := 0
+		coreWithGenerator := domain.NewBookCoreWithGenerator(storer, func() uuid.UUID {
+			id := ids[next]
+			next++
+			return id
+		})
+		invalidBook := newBook
+		invalidBook.ISBN = "isbn"
+		storer.EXPECT().SaveMany(ctx, mock.Anything).Return(&domain.BatchError{
+			Failed: map[uuid.UUID]error{ids[1]: domain.ErrAlreadyExists},
+		}).Once()
+		results := coreWithGenerator.SaveMany(ctx, []domain.NewBook{newBook, invalidBook, newBook})
+
+		assert.Len(t, results, 3)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, ids[0], results[0].Book.ID)
+		assert.ErrorIs(t, results[1].Err, domain.ErrInvalid)
+		assert.ErrorIs(t, results[2].Err, domain.ErrAlreadyExists)
+		assert.Equal(t, domain.Book{}, results[2].Book)
+		storer.AssertExpectations(t)
+	})
+
+	t.Run("SaveManyFail", func(t *testing.T) {
+		coreWithGenerator := domain.NewBookCoreWithGenerator(storer, generator)
+		storer.EXPECT().SaveMany(ctx, []domain.Book{expectedBook}).Return(assert.AnError).Once()
+		results := coreWithGenerator.SaveMany(ctx, []domain.NewBook{newBook})
+
+		assert.Len(t, results, 1)
+		assert.ErrorIs(t, results[0].Err, assert.AnError)
+		storer.AssertExpectations(t)
+	})
+
 	t.Run("FindAll", func(t *testing.T) {
 		expectedBooks := []domain.Book{
 			{
@@ -87,6 +136,25 @@ func TestBookCore(t *testing.T) {
 		storer.AssertExpectations(t)
 	})
 
+	t.Run("FindMany", func(t *testing.T) {
+		missingID := uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c813")
+		storer.EXPECT().FindMany(ctx, []uuid.UUID{missingID, expectedID}).Return([]domain.Book{expectedBook}, nil).Once()
+		books, missing, err := core.FindMany(ctx, []uuid.UUID{missingID, expectedID, missingID})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Book{expectedBook}, books)
+		assert.Equal(t, []uuid.UUID{missingID}, missing)
+		storer.AssertExpectations(t)
+	})
+
+	t.Run("FindManyFail", func(t *testing.T) {
+		storer.EXPECT().FindMany(ctx, []uuid.UUID{expectedID}).Return(nil, assert.AnError).Once()
+		_, _, err := core.FindMany(ctx, []uuid.UUID{expectedID})
+
+		assert.Error(t, err)
+		storer.AssertExpectations(t)
+	})
+
 	t.Run("FindOne", func(t *testing.T) {
 		storer.EXPECT().FindOne(ctx, expectedID).Return(expectedBook, nil).Once()
 		foundBook, err := core.FindOne(ctx, expectedID)
@@ -118,3 +186,98 @@ func TestBookCore(t *testing.T) {
 		storer.AssertExpectations(t)
 	})
 }
+
+func TestPut(t *testing.T) {
+	ctx := context.Background()
+	bookID := uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812")
+	newBook := domain.NewBook{
+		Title:     "Test Book",
+		Authors:   "Test Author",
+		Publisher: "Test Publisher",
+		Pages:     100,
+		ISBN:      "978-0134190440",
+	}
+	expectedBook := domain.Book{
+		ID:        bookID,
+		Title:     newBook.Title,
+		Authors:   newBook.Authors,
+		Publisher: newBook.Publisher,
+		Pages:     newBook.Pages,
+		ISBN:      newBook.ISBN,
+	}
+
+	existingBook := expectedBook
+	existingBook.Title = "The Go Programming Language (Draft)"
+
+	t.Run("Create", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		storer.EXPECT().Save(ctx, expectedBook).Return(nil).Once()
+
+		book, created, err := domain.NewBookCore(storer).Put(ctx, bookID, newBook)
+
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, expectedBook, book)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		storer.EXPECT().Save(ctx, expectedBook).Return(domain.ErrAlreadyExists).Once()
+		storer.EXPECT().FindOne(ctx, bookID).Return(existingBook, nil).Once()
+		storer.EXPECT().CompareAndSwap(ctx, []domain.Book{existingBook}, []domain.Book{expectedBook}).Return(nil).Once()
+
+		book, created, err := domain.NewBookCore(storer).Put(ctx, bookID, newBook)
+
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, expectedBook, book)
+	})
+
+	t.Run("DeletedConcurrently", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		storer.EXPECT().Save(ctx, expectedBook).Return(domain.ErrAlreadyExists).Once()
+		storer.EXPECT().FindOne(ctx, bookID).Return(domain.Book{}, domain.ErrNotFound).Once()
+		storer.EXPECT().Save(ctx, expectedBook).Return(nil).Once()
+
+		_, created, err := domain.NewBookCore(storer).Put(ctx, bookID, newBook)
+
+		assert.NoError(t, err)
+		assert.True(t, created)
+	})
+
+	t.Run("KeepsChanging", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		storer.EXPECT().Save(ctx, expectedBook).Return(domain.ErrAlreadyExists).Times(2)
+		storer.EXPECT().FindOne(ctx, bookID).Return(existingBook, nil).Times(2)
+		storer.EXPECT().CompareAndSwap(ctx, []domain.Book{existingBook}, []domain.Book{expectedBook}).Return(domain.ErrPreconditionFailed).Times(2)
+
+		_, _, err := domain.NewBookCore(storer).Put(ctx, bookID, newBook)
+
+		assert.ErrorIs(t, err, domain.ErrPreconditionFailed)
+	})
+
+	t.Run("Merged", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		mergedBook := existingBook
+		mergedBook.MergedInto = uuid.New()
+		storer.EXPECT().Save(ctx, expectedBook).Return(domain.ErrAlreadyExists).Once()
+		storer.EXPECT().FindOne(ctx, bookID).Return(mergedBook, nil).Once()
+
+		_, _, err := domain.NewBookCore(storer).Put(ctx, bookID, newBook)
+
+		var merr *domain.MergedError
+		if assert.ErrorAs(t, err, &merr) {
+			assert.Equal(t, mergedBook.MergedInto, merr.Into)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		invalidBook := newBook
+		invalidBook.ISBN = "1234"
+
+		_, _, err := domain.NewBookCore(storer).Put(ctx, bookID, invalidBook)
+
+		assert.ErrorIs(t, err, domain.ErrInvalid)
+	})
+}
@@ -0,0 +1,279 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// DefaultDuplicateThreshold is the minimum score of a duplicate candidate
+// when no threshold is given.
+const DefaultDuplicateThreshold = 0.8
+
+// Weights of the fields compared by Similarity, fields blank in both books
+// are left out of the score.
+const (
+	titleWeight     = 0.5
+	authorsWeight   = 0.35
+	publisherWeight = 0.15
+)
+
+// Duplicate is a book that may describe the same edition as another one.
+type Duplicate struct {
+	Book  Book
+	Score float64
+}
+
+// Similarity returns a score between 0 and 1 of how likely two books describe
+// the same edition. Books with the same ISBN always score 1, otherwise the
+// score is the weighted similarity of their normalized title, authors and
+// publisher, so that typos, punctuation, letter case and the order of the
+// authors do not prevent a match.
+func Similarity(a, b Book) float64 {
+	if isbnA, isbnB := ISBN13(a.ISBN), ISBN13(b.ISBN); isbnA != "" && isbnA == isbnB {
+		return 1
+	}
+
+	var score, weight float64
+
+	fields := []struct {
+		a, b   string
+		weight float64
+	}{
+		{normalizeTitle(a.Title), normalizeTitle(b.Title), titleWeight},
+		{normalizeAuthors(a.Authors), normalizeAuthors(b.Authors), authorsWeight},
+		{normalizePublisher(a.Publisher), normalizePublisher(b.Publisher), publisherWeight},
+	}
+
+	for _, f := range fields {
+		if f.a == "" && f.b == "" {
+			continue
+		}
+
+		score += f.weight * stringSimilarity(f.a, f.b)
+		weight += f.weight
+	}
+
+	if weight == 0 {
+		return 0
+	}
+
+	return score / weight
+}
+
+// FindDuplicates returns the books whose similarity with the book identified
+// by bookID is at least threshold, most similar first.
+//
+// Every book of the catalog is a candidate: the catalog is read with
+// FindEvery, FindEveryPageSize books at a time, and compared in memory, so
+// the reads, the time and the memory taken grow with the size of the
+// catalog. It is meant for catalogs of up to about 10,000 books, i.e. 100
+// pages, which a request reads in a few seconds; larger ones need the
+// candidates narrowed first, e.g. by ISBN or title.
+func (c *BookCore) FindDuplicates(ctx context.Context, bookID uuid.UUID, threshold float64) ([]Duplicate, error) {
+	book, err := c.FindOne(ctx, bookID)
+	if err != nil {
+		return []Duplicate{}, fmt.Errorf("domain.findduplicates failed: %w", err)
+	}
+
+	books, err := c.FindEvery(ctx)
+	if err != nil {
+		return []Duplicate{}, fmt.Errorf("domain.findduplicates failed: %w", err)
+	}
+
+	duplicates := []Duplicate{}
+
+	for _, other := range books {
+		if other.ID == book.ID {
+			continue
+		}
+
+		if score := Similarity(book, other); score >= threshold {
+			duplicates = append(duplicates, Duplicate{Book: other, Score: score})
+		}
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Score > duplicates[j].Score
+	})
+
+	return duplicates, nil
+}
+
+// Merge folds the book identified by duplicateID into the one identified by
+// survivorID and returns the updated survivor.
+//
+// The survivor keeps its ID and its details, blank ones are taken from the
+// duplicate and the tags of both books are joined. The duplicate is kept as
+// a merged book, so that looking it up returns a *MergedError pointing to
+// the survivor.
+//
+// Both books are written at once, provided neither changed since it was read
+// (see Storer.CompareAndSwap): a concurrent change is detected and the merge
+// is attempted once more, ErrPreconditionFailed is returned if the books keep
+// changing. Merging again a duplicate already merged into the survivor
+// returns the survivor as it is, so a merge can be retried safely.
+func (c *BookCore) Merge(ctx context.Context, survivorID, duplicateID uuid.UUID) (Book, error) {
+	if survivorID == duplicateID {
+		return Book{}, fmt.Errorf("domain.merge failed: %w", &ValidationError{Fields: []FieldError{
+			{Field: "duplicateId", Err: "a book cannot be merged into itself"},
+		}})
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		survivor, err := c.FindOne(ctx, survivorID)
+		if err != nil {
+			return Book{}, fmt.Errorf("domain.merge failed: %w", err)
+		}
+
+		duplicate, err := c.storer.FindOne(ctx, duplicateID)
+		if err != nil {
+			return Book{}, fmt.Errorf("domain.merge failed: %w", err)
+		}
+
+		switch duplicate.MergedInto {
+		case uuid.Nil:
+		case survivor.ID:
+			return survivor, nil
+		default:
+			return Book{}, fmt.Errorf("domain.merge failed: %w", &MergedError{ID: duplicate.ID, Into: duplicate.MergedInto})
+		}
+
+		merged := mergeBooks(survivor, duplicate)
+		marked := duplicate
+		marked.MergedInto = survivor.ID
+
+		err = c.storer.CompareAndSwap(ctx, []Book{survivor, duplicate}, []Book{merged, marked})
+		if err == nil {
+			return merged, nil
+		}
+
+		if !errors.Is(err, ErrPreconditionFailed) {
+			return Book{}, fmt.Errorf("domain.merge failed: %w", err)
+		}
+	}
+
+	return Book{}, fmt.Errorf("domain.merge failed: %w", ErrPreconditionFailed)
+}
+
+func mergeBooks(survivor, duplicate Book) Book {
+	fill := func(dst *string, src string) {
+		if strings.TrimSpace(*dst) == "" {
+			*dst = src
+		}
+	}
+
+	fill(&survivor.Title, duplicate.Title)
+	fill(&survivor.Authors, duplicate.Authors)
+	fill(&survivor.Publisher, duplicate.Publisher)
+
+	if survivor.Pages < 1 {
+		survivor.Pages = duplicate.Pages
+	}
+
+	if !ValidISBN(survivor.ISBN) && ValidISBN(duplicate.ISBN) {
+		survivor.ISBN = duplicate.ISBN
+	}
+
+	tags := append([]string(nil), survivor.Tags...)
+	for _, tag := range duplicate.Tags {
+		if !containsFold(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	survivor.Tags = tags
+
+	return survivor
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeWords returns the lower case words of s, punctuation and symbols
+// are treated as separators.
+func normalizeWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalizeTitle drops the leading article of a title.
+func normalizeTitle(title string) string {
+	words := normalizeWords(title)
+	if len(words) > 1 && (words[0] == "the" || words[0] == "a" || words[0] == "an") {
+		words = words[1:]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// normalizeAuthors sorts the words of the authors, making the comparison
+// independent of the order of the authors and of the name parts.
+func normalizeAuthors(authors string) string {
+	words := normalizeWords(authors)
+	sort.Strings(words)
+
+	return strings.Join(words, " ")
+}
+
+// normalizePublisher drops the legal suffixes of a publisher name.
+func normalizePublisher(publisher string) string {
+	var words []string
+
+	for _, word := range normalizeWords(publisher) {
+		switch word {
+		case "inc", "ltd", "llc", "co", "corp", "company", "gmbh", "srl", "spa":
+			continue
+		}
+
+		words = append(words, word)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// stringSimilarity returns 1 minus the Levenshtein distance of a and b
+// relative to the length of the longest one.
+func stringSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
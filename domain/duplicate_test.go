@@ -0,0 +1,186 @@
+package domain_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var goplBook = domain.Book{
+	ID:        uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812"),
+	Title:     "The Go Programming Language",
+	Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+	Publisher: "Addison-Wesley",
+	Pages:     380,
+	ISBN:      "978-0134190440",
+	Tags:      []string{"go"},
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		book     domain.Book
+		min, max float64
+	}{
+		{
+			name: "SameISBNAsISBN10",
+			book: domain.Book{Title: "Another Title", ISBN: "0-13-419044-0"},
+			min:  1,
+			max:  1,
+		},
+		{
+			name: "TyposAndAuthorsOrder",
+			book: domain.Book{Title: "Go programing language", Authors: "Kernighan, Brian W.; Donovan, Alan A.A.", Publisher: "Addison Wesley Inc."},
+			min:  domain.DefaultDuplicateThreshold,
+			max:  1,
+		},
+		{
+			name: "DifferentBook",
+			book: domain.Book{Title: "The C Programming Language", Authors: "Brian W. Kernighan, Dennis M. Ritchie", Publisher: "Prentice Hall"},
+			min:  0,
+			max:  domain.DefaultDuplicateThreshold,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := domain.Similarity(goplBook, tt.book)
+
+			assert.GreaterOrEqual(t, score, tt.min)
+			assert.LessOrEqual(t, score, tt.max)
+		})
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	storer, _, _ := setup(t)
+	ctx := context.Background()
+	core := domain.NewBookCore(storer)
+
+	typo := goplBook
+	typo.ID = uuid.New()
+	typo.Title = "Go Programing Language"
+	typo.ISBN = ""
+
+	merged := goplBook
+	merged.ID = uuid.New()
+	merged.MergedInto = goplBook.ID
+
+	other := domain.Book{ID: uuid.New(), Title: "Structure and Interpretation of Computer Programs", Authors: "Harold Abelson"}
+
+	// The duplicate is on the second page of the catalog.
+	firstPage := []domain.Book{goplBook, other, merged}
+	for len(firstPage) < domain.FindEveryPageSize {
+		firstPage = append(firstPage, domain.Book{ID: uuid.New(), Title: fmt.Sprintf("Volume %d", len(firstPage))})
+	}
+
+	last := firstPage[len(firstPage)-1].ID
+
+	storer.EXPECT().FindOne(ctx, goplBook.ID).Return(goplBook, nil).Once()
+	storer.EXPECT().FindPage(ctx, uuid.Nil, domain.FindEveryPageSize).Return(firstPage, nil).Once()
+	storer.EXPECT().FindPage(ctx, last, domain.FindEveryPageSize).Return([]domain.Book{typo}, nil).Once()
+
+	duplicates, err := core.FindDuplicates(ctx, goplBook.ID, domain.DefaultDuplicateThreshold)
+
+	require.NoError(t, err)
+	require.Len(t, duplicates, 1)
+	assert.Equal(t, typo, duplicates[0].Book)
+	storer.AssertExpectations(t)
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+
+	survivor := goplBook
+	survivor.Pages = 0
+	survivor.ISBN = ""
+
+	duplicate := goplBook
+	duplicate.ID = uuid.New()
+	duplicate.Tags = []string{"Go", "programming"}
+
+	t.Run("Merge", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		core := domain.NewBookCore(storer)
+
+		expectedSurvivor := survivor
+		expectedSurvivor.Pages = duplicate.Pages
+		expectedSurvivor.ISBN = duplicate.ISBN
+		expectedSurvivor.Tags = []string{"go", "programming"}
+
+		expectedDuplicate := duplicate
+		expectedDuplicate.MergedInto = survivor.ID
+
+		storer.EXPECT().FindOne(ctx, survivor.ID).Return(survivor, nil).Once()
+		storer.EXPECT().FindOne(ctx, duplicate.ID).Return(duplicate, nil).Once()
+		storer.EXPECT().CompareAndSwap(ctx, []domain.Book{survivor, duplicate}, []domain.Book{expectedSurvivor, expectedDuplicate}).Return(nil).Once()
+
+		ret, err := core.Merge(ctx, survivor.ID, duplicate.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, expectedSurvivor, ret)
+		storer.AssertExpectations(t)
+	})
+
+	t.Run("MergeAgain", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		core := domain.NewBookCore(storer)
+		merged := duplicate
+		merged.MergedInto = survivor.ID
+
+		storer.EXPECT().FindOne(ctx, survivor.ID).Return(survivor, nil).Once()
+		storer.EXPECT().FindOne(ctx, duplicate.ID).Return(merged, nil).Once()
+
+		ret, err := core.Merge(ctx, survivor.ID, duplicate.ID)
+
+		require.NoError(t, err)
+		assert.Equal(t, survivor, ret)
+		storer.AssertExpectations(t)
+	})
+
+	t.Run("KeepsChanging", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		core := domain.NewBookCore(storer)
+
+		storer.EXPECT().FindOne(ctx, survivor.ID).Return(survivor, nil).Times(2)
+		storer.EXPECT().FindOne(ctx, duplicate.ID).Return(duplicate, nil).Times(2)
+		storer.EXPECT().CompareAndSwap(ctx, mock.Anything, mock.Anything).Return(domain.ErrPreconditionFailed).Times(2)
+
+		_, err := core.Merge(ctx, survivor.ID, duplicate.ID)
+
+		require.ErrorIs(t, err, domain.ErrPreconditionFailed)
+		storer.AssertExpectations(t)
+	})
+
+	t.Run("MergeIntoItself", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		core := domain.NewBookCore(storer)
+
+		_, err := core.Merge(ctx, survivor.ID, survivor.ID)
+
+		require.ErrorIs(t, err, domain.ErrInvalid)
+	})
+
+	t.Run("MergeAlreadyMerged", func(t *testing.T) {
+		storer, _, _ := setup(t)
+		core := domain.NewBookCore(storer)
+		merged := duplicate
+		merged.MergedInto = uuid.New()
+
+		storer.EXPECT().FindOne(ctx, survivor.ID).Return(survivor, nil).Once()
+		storer.EXPECT().FindOne(ctx, duplicate.ID).Return(merged, nil).Once()
+
+		_, err := core.Merge(ctx, survivor.ID, duplicate.ID)
+
+		var merr *domain.MergedError
+		require.ErrorAs(t, err, &merr)
+		assert.Equal(t, merged.MergedInto, merr.Into)
+		storer.AssertExpectations(t)
+	})
+}
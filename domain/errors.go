@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Set of errors returned by the domain and the storage adapters. Adapters
+// wrap their own errors with one of these so that callers can rely on
+// errors.Is regardless of the underlying technology.
+var (
+	// ErrNotFound is used when a specific Book is requested but does not exist.
+	ErrNotFound = errors.New("book not found")
+
+	// ErrAlreadyExists is used when a specific Book is created but already exists.
+	ErrAlreadyExists = errors.New("book already exists")
+
+	// ErrInvalid is used when a Book does not satisfy the domain invariants.
+	ErrInvalid = errors.New("book is invalid")
+
+	// ErrPreconditionFailed is used when a conditional operation is rejected
+	// because the stored Book is not in the expected state.
+	ErrPreconditionFailed = errors.New("book precondition failed")
+
+	// ErrMerged is used when a specific Book is requested but it was merged
+	// into another one, see MergedError.
+	ErrMerged = errors.New("book was merged")
+
+	// ErrThrottled is used when the storage rejects a request because of
+	// capacity limits. The operation can be retried later.
+	ErrThrottled = errors.New("storage is throttling requests")
+
+	// ErrUnavailable is used when the storage cannot be reached or is not
+	// ready to serve requests.
+	ErrUnavailable = errors.New("storage is unavailable")
+)
+
+// MergedError is returned when looking up a book that was merged into
+// another one. It matches ErrMerged when used with errors.Is.
+type MergedError struct {
+	ID   uuid.UUID
+	Into uuid.UUID
+}
+
+// Error implements the error interface.
+func (e *MergedError) Error() string {
+	return fmt.Sprintf("%s: %s into %s", ErrMerged, e.ID, e.Into)
+}
+
+// Is reports whether target is ErrMerged.
+func (e *MergedError) Is(target error) bool {
+	return target == ErrMerged
+}
+
+// BatchError is returned by a Storer when only some of the books of a batch
+// operation could not be processed. Failed maps each book ID to its error.
+type BatchError struct {
+	Failed map[uuid.UUID]error
+}
+
+// Error implements the error interface.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d books of the batch failed", len(e.Failed))
+}
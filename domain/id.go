@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Names of the built-in ID strategies, see NewIDGenerator.
+const (
+	// IDStrategyRandom generates random UUIDs (version 4).
+	IDStrategyRandom = "uuidv4"
+
+	// IDStrategyTime generates time-ordered UUIDs (version 7), so that
+	// sorting books by ID sorts them by creation time.
+	IDStrategyTime = "uuidv7"
+
+	// IDStrategyISBN derives name-based UUIDs (version 5) from the canonical
+	// ISBN-13, so that saving the same edition twice yields the same ID.
+	IDStrategyISBN = "isbn"
+)
+
+// IDGenerator returns the ID of a book about to be saved.
+type IDGenerator func(nb NewBook) uuid.UUID
+
+// NewIDGenerator returns the IDGenerator of a built-in ID strategy.
+func NewIDGenerator(strategy string) (IDGenerator, error) {
+	switch strings.ToLower(strings.TrimSpace(strategy)) {
+	case IDStrategyRandom, "":
+		return RandomID, nil
+	case IDStrategyTime:
+		return TimeOrderedID, nil
+	case IDStrategyISBN:
+		return ISBNID, nil
+	default:
+		return nil, fmt.Errorf("domain.newidgenerator: unknown strategy %q", strategy)
+	}
+}
+
+// RandomID returns a random UUID (version 4).
+func RandomID(NewBook) uuid.UUID {
+	return uuid.New()
+}
+
+// TimeOrderedID returns a time-ordered UUID (version 7).
+func TimeOrderedID(NewBook) uuid.UUID {
+	return uuid.Must(uuid.NewV7())
+}
+
+// ISBNNamespace is the namespace of the UUIDs derived from ISBNs by ISBNID.
+// It was generated once for this project and must never change: the IDs of
+// the books saved with IDStrategyISBN are derived from it.
+var ISBNNamespace = uuid.MustParse("4cf8b71b-06c1-4d43-9a74-a75ec64cd82a")
+
+// ISBNID returns a UUID (version 5) derived from the canonical ISBN-13 of
+// the book, e.g. "9780134190440", in the ISBNNamespace. A book without a
+// valid ISBN gets a time-ordered UUID instead.
+func ISBNID(nb NewBook) uuid.UUID {
+	isbn := ISBN13(nb.ISBN)
+	if isbn == "" {
+		return TimeOrderedID(nb)
+	}
+
+	return uuid.NewSHA1(ISBNNamespace, []byte(isbn))
+}
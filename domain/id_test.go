@@ -0,0 +1,140 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIDGenerator(t *testing.T) {
+	for _, strategy := range []string{domain.IDStrategyRandom, domain.IDStrategyTime, domain.IDStrategyISBN, ""} {
+		generator, err := domain.NewIDGenerator(strategy)
+		require.NoError(t, err, strategy)
+		require.NotEqual(t, uuid.Nil, generator(domain.NewBook{ISBN: "978-0134190440"}), strategy)
+	}
+
+	_, err := domain.NewIDGenerator("snowflake")
+	require.Error(t, err)
+}
+
+func TestTimeOrderedID(t *testing.T) {
+	first := domain.TimeOrderedID(domain.NewBook{})
+	second := domain.TimeOrderedID(domain.NewBook{})
+
+	assert.Equal(t, uuid.Version(7), first.Version())
+	assert.Less(t, first.String(), second.String())
+}
+
+func TestISBNID(t *testing.T) {
+	id := domain.ISBNID(domain.NewBook{ISBN: "978-0134190440"})
+
+	// The derived IDs are permanent, a change of namespace or name would
+	// give the same edition another ID.
+	assert.Equal(t, uuid.MustParse("40290c65-3940-56bc-aef6-a0f8d9829380"), id)
+	assert.Equal(t, uuid.Version(5), id.Version())
+	assert.Equal(t, id, domain.ISBNID(domain.NewBook{ISBN: "0134190440"}))
+	assert.NotEqual(t, id, domain.ISBNID(domain.NewBook{ISBN: "9780321601919"}))
+	assert.Equal(t, uuid.Version(7), domain.ISBNID(domain.NewBook{}).Version())
+}
+
+func TestSaveManyISBNID(t *testing.T) {
+	storer, _, _ := setup(t)
+	ctx := context.Background()
+	core := domain.NewBookCoreWithIDGenerator(storer, domain.ISBNID)
+	nb := domain.NewBook{
+		Title:     "The Go Programming Language",
+		Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher: "Addison-Wesley",
+		Pages:     380,
+		ISBN:      "978-0134190440",
+	}
+
+	expected := domain.Book{
+		ID:        domain.ISBNID(nb),
+		Title:     nb.Title,
+		Authors:   nb.Authors,
+		Publisher: nb.Publisher,
+		Pages:     nb.Pages,
+		ISBN:      nb.ISBN,
+	}
+	storer.EXPECT().SaveMany(ctx, []domain.Book{expected}).Return(nil).Once()
+
+	results := core.SaveMany(ctx, []domain.NewBook{nb, nb})
+
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, domain.ErrAlreadyExists)
+	storer.AssertExpectations(t)
+}
+
+func TestFindPage(t *testing.T) {
+	storer, _, _ := setup(t)
+	ctx := context.Background()
+	core := domain.NewBookCore(storer)
+	first, second := uuid.New(), uuid.New()
+
+	t.Run("FullPage", func(t *testing.T) {
+		storer.EXPECT().FindPage(ctx, uuid.Nil, 2).Return([]domain.Book{
+			{ID: first},
+			{ID: second, MergedInto: first},
+		}, nil).Once()
+
+		page, err := core.FindPage(ctx, uuid.Nil, 2)
+
+		require.NoError(t, err)
+		assert.Equal(t, []domain.Book{{ID: first}}, page.Books)
+		assert.Equal(t, second, page.Next)
+	})
+
+	t.Run("LastPage", func(t *testing.T) {
+		storer.EXPECT().FindPage(ctx, second, 2).Return([]domain.Book{}, nil).Once()
+
+		page, err := core.FindPage(ctx, second, 2)
+
+		require.NoError(t, err)
+		assert.Empty(t, page.Books)
+		assert.Equal(t, uuid.Nil, page.Next)
+	})
+
+	t.Run("InvalidLimit", func(t *testing.T) {
+		_, err := core.FindPage(ctx, uuid.Nil, 0)
+
+		require.ErrorIs(t, err, domain.ErrInvalid)
+	})
+
+	storer.AssertExpectations(t)
+}
+
+func TestFindEvery(t *testing.T) {
+	storer, _, _ := setup(t)
+	ctx := context.Background()
+	core := domain.NewBookCore(storer)
+
+	firstPage := make([]domain.Book, domain.FindEveryPageSize)
+	for i := range firstPage {
+		firstPage[i] = domain.Book{ID: uuid.New()}
+	}
+
+	last := firstPage[len(firstPage)-1].ID
+	merged := domain.Book{ID: uuid.New(), MergedInto: last}
+	lastBook := domain.Book{ID: uuid.New()}
+
+	storer.EXPECT().FindPage(ctx, uuid.Nil, domain.FindEveryPageSize).Return(firstPage, nil).Once()
+	storer.EXPECT().FindPage(ctx, last, domain.FindEveryPageSize).Return([]domain.Book{merged, lastBook}, nil).Once()
+
+	books, err := core.FindEvery(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, append(firstPage, lastBook), books)
+
+	storer.EXPECT().FindPage(ctx, uuid.Nil, domain.FindEveryPageSize).Return(nil, domain.ErrThrottled).Once()
+
+	_, err = core.FindEvery(ctx)
+
+	require.ErrorIs(t, err, domain.ErrThrottled)
+	storer.AssertExpectations(t)
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizeISBN returns the ISBN without separators (hyphens and spaces) and
+// with an upper case check digit, e.g. "0-8044-2957-x" becomes "080442957X".
+func NormalizeISBN(isbn string) string {
+	var b strings.Builder
+
+	for _, r := range strings.TrimSpace(isbn) {
+		switch {
+		case r == '-' || r == ' ':
+			continue
+		case r == 'x':
+			b.WriteRune('X')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// ValidISBN reports whether isbn is a well-formed ISBN-10 or ISBN-13 with a
+// correct check digit. Separators are ignored.
+func ValidISBN(isbn string) bool {
+	isbn = NormalizeISBN(isbn)
+
+	switch len(isbn) {
+	case 10:
+		return validISBN10(isbn)
+	case 13:
+		return validISBN13(isbn)
+	default:
+		return false
+	}
+}
+
+func validISBN10(isbn string) bool {
+	sum := 0
+
+	for i, r := range isbn {
+		var digit int
+
+		switch {
+		case r >= '0' && r <= '9':
+			digit = int(r - '0')
+		case r == 'X' && i == 9:
+			digit = 10
+		default:
+			return false
+		}
+
+		sum += (10 - i) * digit
+	}
+
+	return sum%11 == 0
+}
+
+func validISBN13(isbn string) bool {
+	if !strings.HasPrefix(isbn, "978") && !strings.HasPrefix(isbn, "979") {
+		return false
+	}
+
+	sum := 0
+
+	for i, r := range isbn {
+		if r < '0' || r > '9' {
+			return false
+		}
+
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+
+		sum += weight * int(r-'0')
+	}
+
+	return sum%10 == 0
+}
+
+// ISBN13 returns a valid ISBN as ISBN-13 without separators, so that the two
+// forms of the same ISBN compare equal, or an empty string.
+func ISBN13(isbn string) string {
+	if !ValidISBN(isbn) {
+		return ""
+	}
+
+	isbn = NormalizeISBN(isbn)
+	if len(isbn) == 13 {
+		return isbn
+	}
+
+	isbn = "978" + isbn[:9]
+	sum := 0
+
+	for i, r := range isbn {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+
+		sum += weight * int(r-'0')
+	}
+
+	return fmt.Sprintf("%s%d", isbn, (10-sum%10)%10)
+}
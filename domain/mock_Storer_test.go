@@ -22,6 +22,50 @@ func (_m *MockStorer) EXPECT() *MockStorer_Expecter {
 	return &MockStorer_Expecter{mock: &_m.Mock}
 }
 
+// CompareAndSwap provides a mock function with given fields: ctx, previous, books
+func (_m *MockStorer) CompareAndSwap(ctx context.Context, previous []Book, books []Book) error {
+	ret := _m.Called(ctx, previous, books)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []Book, []Book) error); ok {
+		r0 = rf(ctx, previous, books)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockStorer_CompareAndSwap_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompareAndSwap'
+type MockStorer_CompareAndSwap_Call struct {
+	*mock.Call
+}
+
+// CompareAndSwap is a helper method to define mock.On call
+//   - ctx context.Context
+//   - previous []Book
+//   - books []Book
+func (_e *MockStorer_Expecter) CompareAndSwap(ctx interface{}, previous interface{}, books interface{}) *MockStorer_CompareAndSwap_Call {
+	return &MockStorer_CompareAndSwap_Call{Call: _e.mock.On("CompareAndSwap", ctx, previous, books)}
+}
+
+func (_c *MockStorer_CompareAndSwap_Call) Run(run func(ctx context.Context, previous []Book, books []Book)) *MockStorer_CompareAndSwap_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]Book), args[2].([]Book))
+	})
+	return _c
+}
+
+func (_c *MockStorer_CompareAndSwap_Call) Return(_a0 error) *MockStorer_CompareAndSwap_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockStorer_CompareAndSwap_Call) RunAndReturn(run func(context.Context, []Book, []Book) error) *MockStorer_CompareAndSwap_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Delete provides a mock function with given fields: ctx, bookID
 func (_m *MockStorer) Delete(ctx context.Context, bookID uuid.UUID) error {
 	ret := _m.Called(ctx, bookID)
@@ -119,6 +163,61 @@ func (_c *MockStorer_FindAll_Call) RunAndReturn(run func(context.Context) ([]Boo
 	return _c
 }
 
+// FindMany provides a mock function with given fields: ctx, bookIDs
+func (_m *MockStorer) FindMany(ctx context.Context, bookIDs []uuid.UUID) ([]Book, error) {
+	ret := _m.Called(ctx, bookIDs)
+
+	var r0 []Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []uuid.UUID) ([]Book, error)); ok {
+		return rf(ctx, bookIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []uuid.UUID) []Book); ok {
+		r0 = rf(ctx, bookIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]Book)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []uuid.UUID) error); ok {
+		r1 = rf(ctx, bookIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorer_FindMany_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FindMany'
+type MockStorer_FindMany_Call struct {
+	*mock.Call
+}
+
+// FindMany is a helper method to define mock.On call
+//   - ctx context.Context
+//   - bookIDs []uuid.UUID
+func (_e *MockStorer_Expecter) FindMany(ctx interface{}, bookIDs interface{}) *MockStorer_FindMany_Call {
+	return &MockStorer_FindMany_Call{Call: _e.mock.On("FindMany", ctx, bookIDs)}
+}
+
+func (_c *MockStorer_FindMany_Call) Run(run func(ctx context.Context, bookIDs []uuid.UUID)) *MockStorer_FindMany_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]uuid.UUID))
+	})
+	return _c
+}
+
+func (_c *MockStorer_FindMany_Call) Return(_a0 []Book, _a1 error) *MockStorer_FindMany_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorer_FindMany_Call) RunAndReturn(run func(context.Context, []uuid.UUID) ([]Book, error)) *MockStorer_FindMany_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // FindOne provides a mock function with given fields: ctx, bookID
 func (_m *MockStorer) FindOne(ctx context.Context, bookID uuid.UUID) (Book, error) {
 	ret := _m.Called(ctx, bookID)
@@ -172,6 +271,62 @@ func (_c *MockStorer_FindOne_Call) RunAndReturn(run func(context.Context, uuid.U
 	return _c
 }
 
+// FindPage provides a mock function with given fields: ctx, after, limit
+func (_m *MockStorer) FindPage(ctx context.Context, after uuid.UUID, limit int) ([]Book, error) {
+	ret := _m.Called(ctx, after, limit)
+
+	var r0 []Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int) ([]Book, error)); ok {
+		return rf(ctx, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int) []Book); ok {
+		r0 = rf(ctx, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]Book)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, int) error); ok {
+		r1 = rf(ctx, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockStorer_FindPage_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FindPage'
+type MockStorer_FindPage_Call struct {
+	*mock.Call
+}
+
+// FindPage is a helper method to define mock.On call
+//   - ctx context.Context
+//   - after uuid.UUID
+//   - limit int
+func (_e *MockStorer_Expecter) FindPage(ctx interface{}, after interface{}, limit interface{}) *MockStorer_FindPage_Call {
+	return &MockStorer_FindPage_Call{Call: _e.mock.On("FindPage", ctx, after, limit)}
+}
+
+func (_c *MockStorer_FindPage_Call) Run(run func(ctx context.Context, after uuid.UUID, limit int)) *MockStorer_FindPage_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(uuid.UUID), args[2].(int))
+	})
+	return _c
+}
+
+func (_c *MockStorer_FindPage_Call) Return(_a0 []Book, _a1 error) *MockStorer_FindPage_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockStorer_FindPage_Call) RunAndReturn(run func(context.Context, uuid.UUID, int) ([]Book, error)) *MockStorer_FindPage_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Save provides a mock function with given fields: ctx, book
 func (_m *MockStorer) Save(ctx context.Context, book Book) error {
 	ret := _m.Called(ctx, book)
@@ -215,6 +370,92 @@ func (_c *MockStorer_Save_Call) RunAndReturn(run func(context.Context, Book) err
 	return _c
 }
 
+// SaveMany provides a mock function with given fields: ctx, books
+func (_m *MockStorer) SaveMany(ctx context.Context, books []Book) error {
+	ret := _m.Called(ctx, books)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []Book) error); ok {
+		r0 = rf(ctx, books)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockStorer_SaveMany_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveMany'
+type MockStorer_SaveMany_Call struct {
+	*mock.Call
+}
+
+// SaveMany is a helper method to define mock.On call
+//   - ctx context.Context
+//   - books []Book
+func (_e *MockStorer_Expecter) SaveMany(ctx interface{}, books interface{}) *MockStorer_SaveMany_Call {
+	return &MockStorer_SaveMany_Call{Call: _e.mock.On("SaveMany", ctx, books)}
+}
+
+func (_c *MockStorer_SaveMany_Call) Run(run func(ctx context.Context, books []Book)) *MockStorer_SaveMany_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]Book))
+	})
+	return _c
+}
+
+func (_c *MockStorer_SaveMany_Call) Return(_a0 error) *MockStorer_SaveMany_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockStorer_SaveMany_Call) RunAndReturn(run func(context.Context, []Book) error) *MockStorer_SaveMany_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Update provides a mock function with given fields: ctx, book
+func (_m *MockStorer) Update(ctx context.Context, book Book) error {
+	ret := _m.Called(ctx, book)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, Book) error); ok {
+		r0 = rf(ctx, book)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockStorer_Update_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Update'
+type MockStorer_Update_Call struct {
+	*mock.Call
+}
+
+// Update is a helper method to define mock.On call
+//   - ctx context.Context
+//   - book Book
+func (_e *MockStorer_Expecter) Update(ctx interface{}, book interface{}) *MockStorer_Update_Call {
+	return &MockStorer_Update_Call{Call: _e.mock.On("Update", ctx, book)}
+}
+
+func (_c *MockStorer_Update_Call) Run(run func(ctx context.Context, book Book)) *MockStorer_Update_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(Book))
+	})
+	return _c
+}
+
+func (_c *MockStorer_Update_Call) Return(_a0 error) *MockStorer_Update_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockStorer_Update_Call) RunAndReturn(run func(context.Context, Book) error) *MockStorer_Update_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockStorer creates a new instance of MockStorer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockStorer(t interface {
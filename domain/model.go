@@ -1,6 +1,10 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"slices"
+
+	"github.com/google/uuid"
+)
 
 // Book represents information about an individual book.
 type Book struct {
@@ -10,6 +14,19 @@ type Book struct {
 	Publisher string
 	Pages     int
 	ISBN      string
+	Tags      []string
+
+	// MergedInto is the ID of the book this one was merged into, a merged
+	// book is only kept to redirect lookups to the surviving one.
+	MergedInto uuid.UUID
+}
+
+// Equal reports whether b and other hold the same details, a nil and an
+// empty Tags are equal.
+func (b Book) Equal(other Book) bool {
+	return b.ID == other.ID && b.Title == other.Title && b.Authors == other.Authors &&
+		b.Publisher == other.Publisher && b.Pages == other.Pages && b.ISBN == other.ISBN &&
+		slices.Equal(b.Tags, other.Tags) && b.MergedInto == other.MergedInto
 }
 
 // NewBook contains information needed to create a new book.
@@ -19,4 +36,17 @@ type NewBook struct {
 	Publisher string
 	Pages     int
 	ISBN      string
+	Tags      []string
+}
+
+// Page is a slice of the books ordered by ID.
+type Page struct {
+	Books []Book
+	Next  uuid.UUID
+}
+
+// SaveResult is the outcome of saving a single NewBook of a batch.
+type SaveResult struct {
+	Book Book
+	Err  error
 }
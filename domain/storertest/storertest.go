@@ -0,0 +1,499 @@
+// Package storertest implements a conformance suite for the implementations
+// of domain.Storer, so that every storage backend behaves the same way on
+// duplicates, missing books, concurrent writes and pagination.
+//
+// A backend runs the suite from its tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storertest.Run(t, func(t *testing.T) domain.Storer {
+//			return memory.NewStore()
+//		})
+//	}
+package storertest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// NewStorer returns an empty Storer, it is called once by each test of the
+// suite, which may run in parallel. Resources held by the Storer are
+// released with t.Cleanup.
+type NewStorer func(t *testing.T) domain.Storer
+
+// Run runs the conformance suite against the Storers returned by newStorer.
+//
+// FindAll is only checked on catalogs of a few books, as some backends cap
+// the number of books it returns.
+func Run(t *testing.T, newStorer NewStorer) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, store domain.Storer)
+	}{
+		{"should save and return a book", testSave},
+		{"should not save an existing book", testSaveExisting},
+		{"should save a batch of books", testSaveMany},
+		{"should report the existing books of a batch", testSaveManyExisting},
+		{"should return not found for a missing book", testFindOneMissing},
+		{"should return the books matching the given IDs", testFindMany},
+		{"should return all books", testFindAll},
+		{"should update an existing book", testUpdate},
+		{"should not update a missing book", testUpdateMissing},
+		{"should swap unchanged books", testCompareAndSwap},
+		{"should not swap books when one of them changed", testCompareAndSwapChanged},
+		{"should delete an existing book", testDelete},
+		{"should not fail deleting a missing book", testDeleteMissing},
+		{"should return the books ordered by ID one page at a time", testFindPage},
+		{"should return an empty page after the last book", testFindPageEnd},
+		{"should save different books concurrently", testConcurrentSave},
+		{"should save a book only once when saved concurrently", testConcurrentSaveSame},
+		{"should save a book only once when saved in concurrent batches", testConcurrentSaveManySame},
+		{"should update and read a book concurrently", testConcurrentUpdate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.test(t, newStorer(t))
+		})
+	}
+}
+
+// newBook returns a book with every field set.
+func newBook(title string) domain.Book {
+	return domain.Book{
+		ID:        uuid.New(),
+		Title:     title,
+		Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher: "Addison-Wesley Professional",
+		Pages:     400,
+		ISBN:      "978-0134190440",
+		Tags:      []string{"go", "programming"},
+	}
+}
+
+// newBooks returns n books with every field set.
+func newBooks(n int) []domain.Book {
+	books := make([]domain.Book, n)
+	for i := range books {
+		books[i] = newBook(fmt.Sprintf("Book %d", i))
+	}
+
+	return books
+}
+
+// sortByID sorts books in the order of FindPage.
+func sortByID(books []domain.Book) {
+	sort.Slice(books, func(i, j int) bool {
+		return bytes.Compare(books[i].ID[:], books[j].ID[:]) < 0
+	})
+}
+
+func testSave(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	book := newBook("The Go Programming Language")
+	require.NoError(t, store.Save(ctx, book))
+
+	ret, err := store.FindOne(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, book, ret)
+}
+
+func testSaveExisting(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	book := newBook("The Go Programming Language")
+	require.NoError(t, store.Save(ctx, book))
+
+	duplicate := book
+	duplicate.Title = "Learning Go"
+	require.ErrorIs(t, store.Save(ctx, duplicate), domain.ErrAlreadyExists)
+
+	ret, err := store.FindOne(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, book, ret, "the existing book must not be overwritten")
+}
+
+func testSaveMany(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+
+	// Enough books to need more than one request on the batching backends.
+	books := newBooks(60)
+	require.NoError(t, store.SaveMany(ctx, books))
+
+	sortByID(books)
+	ret, err := store.FindPage(ctx, uuid.Nil, len(books)+1)
+	require.NoError(t, err)
+	require.Equal(t, books, ret)
+}
+
+func testSaveManyExisting(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	existing := newBook("The Go Programming Language")
+	require.NoError(t, store.Save(ctx, existing))
+
+	duplicate := existing
+	duplicate.Title = "Learning Go"
+	book := newBook("Concurrency in Go")
+	err := store.SaveMany(ctx, []domain.Book{book, duplicate})
+
+	var batchErr *domain.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	require.Len(t, batchErr.Failed, 1)
+	require.ErrorIs(t, batchErr.Failed[existing.ID], domain.ErrAlreadyExists)
+
+	ret, err := store.FindMany(ctx, []uuid.UUID{book.ID, existing.ID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []domain.Book{book, existing}, ret, "the existing book must not be overwritten")
+}
+
+func testFindOneMissing(t *testing.T, store domain.Storer) {
+	_, err := store.FindOne(context.Background(), uuid.New())
+	require.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testFindMany(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	books := newBooks(3)
+	require.NoError(t, store.SaveMany(ctx, books))
+
+	ret, err := store.FindMany(ctx, []uuid.UUID{uuid.New(), books[0].ID, books[2].ID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []domain.Book{books[0], books[2]}, ret)
+
+	ret, err = store.FindMany(ctx, []uuid.UUID{uuid.New()})
+	require.NoError(t, err)
+	require.Empty(t, ret)
+}
+
+func testFindAll(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+
+	ret, err := store.FindAll(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ret)
+
+	books := newBooks(10)
+	require.NoError(t, store.SaveMany(ctx, books))
+
+	ret, err = store.FindAll(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, books, ret)
+}
+
+func testUpdate(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	book := newBook("The Go Programming Language")
+	require.NoError(t, store.Save(ctx, book))
+
+	updated := book
+	updated.Title = "The Go Programming Language, 2nd Edition"
+	updated.ISBN = ""
+	updated.Tags = nil
+	updated.MergedInto = uuid.New()
+	require.NoError(t, store.Update(ctx, updated))
+
+	ret, err := store.FindOne(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, updated, ret)
+}
+
+func testUpdateMissing(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	book := newBook("The Go Programming Language")
+	require.ErrorIs(t, store.Update(ctx, book), domain.ErrNotFound)
+
+	_, err := store.FindOne(ctx, book.ID)
+	require.ErrorIs(t, err, domain.ErrNotFound, "a missing book must not be created")
+}
+
+func testCompareAndSwap(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	books := newBooks(2)
+	books[1].ISBN = ""
+	books[1].Tags = nil
+	require.NoError(t, store.SaveMany(ctx, books))
+
+	previous := make([]domain.Book, len(books))
+	for i, book := range books {
+		ret, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+		previous[i] = ret
+	}
+
+	swapped := make([]domain.Book, len(books))
+	for i, book := range previous {
+		book.Title += ", 2nd Edition"
+		book.MergedInto = uuid.New()
+		swapped[i] = book
+	}
+	require.NoError(t, store.CompareAndSwap(ctx, previous, swapped))
+
+	for _, book := range swapped {
+		ret, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+		require.Equal(t, book, ret)
+	}
+}
+
+func testCompareAndSwapChanged(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	books := newBooks(2)
+	require.NoError(t, store.SaveMany(ctx, books))
+
+	changed := books[1]
+	changed.Pages++
+	require.NoError(t, store.Update(ctx, changed))
+
+	swapped := make([]domain.Book, len(books))
+	for i, book := range books {
+		book.Title += ", 2nd Edition"
+		swapped[i] = book
+	}
+	require.ErrorIs(t, store.CompareAndSwap(ctx, books, swapped), domain.ErrPreconditionFailed)
+
+	ret, err := store.FindOne(ctx, books[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, books[0], ret, "no book must be written when one of them changed")
+
+	require.NoError(t, store.Delete(ctx, books[1].ID))
+	require.ErrorIs(t, store.CompareAndSwap(ctx, []domain.Book{changed}, swapped[1:]), domain.ErrPreconditionFailed)
+
+	_, err = store.FindOne(ctx, books[1].ID)
+	require.ErrorIs(t, err, domain.ErrNotFound, "a deleted book must not be created")
+}
+
+func testDelete(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	books := newBooks(2)
+	require.NoError(t, store.SaveMany(ctx, books))
+	require.NoError(t, store.Delete(ctx, books[0].ID))
+
+	_, err := store.FindOne(ctx, books[0].ID)
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	ret, err := store.FindOne(ctx, books[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, books[1], ret)
+}
+
+func testDeleteMissing(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	book := newBook("The Go Programming Language")
+	require.NoError(t, store.Delete(ctx, book.ID))
+
+	require.NoError(t, store.Save(ctx, book))
+	require.NoError(t, store.Delete(ctx, book.ID))
+	require.NoError(t, store.Delete(ctx, book.ID))
+}
+
+func testFindPage(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+
+	// Random IDs are saved in an order unrelated to the one of the pages.
+	books := newBooks(11)
+	for _, book := range books {
+		require.NoError(t, store.Save(ctx, book))
+	}
+
+	sortByID(books)
+
+	var (
+		pages [][]domain.Book
+		after uuid.UUID
+	)
+
+	for {
+		page, err := store.FindPage(ctx, after, 4)
+		require.NoError(t, err)
+
+		if len(page) == 0 {
+			break
+		}
+
+		require.LessOrEqual(t, len(page), 4)
+		require.Less(t, len(pages), len(books), "FindPage does not advance")
+		pages = append(pages, page)
+		after = page[len(page)-1].ID
+	}
+
+	require.Equal(t, [][]domain.Book{books[0:4], books[4:8], books[8:11]}, pages)
+
+	// A page may start after a book that is not in the catalog.
+	missing := books[5].ID
+	require.NoError(t, store.Delete(ctx, missing))
+
+	page, err := store.FindPage(ctx, missing, 2)
+	require.NoError(t, err)
+	require.Equal(t, books[6:8], page)
+}
+
+func testFindPageEnd(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+
+	page, err := store.FindPage(ctx, uuid.Nil, 10)
+	require.NoError(t, err)
+	require.Empty(t, page)
+
+	book := newBook("The Go Programming Language")
+	require.NoError(t, store.Save(ctx, book))
+
+	page, err = store.FindPage(ctx, book.ID, 10)
+	require.NoError(t, err)
+	require.Empty(t, page)
+}
+
+// concurrency is the number of goroutines of the concurrency tests.
+const concurrency = 16
+
+func testConcurrentSave(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	books := newBooks(concurrency)
+	errs := make([]error, len(books))
+
+	var wg sync.WaitGroup
+	for i, book := range books {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = store.Save(ctx, book)
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, errors.Join(errs...))
+
+	sortByID(books)
+	ret, err := store.FindPage(ctx, uuid.Nil, len(books))
+	require.NoError(t, err)
+	require.Equal(t, books, ret)
+}
+
+func testConcurrentSaveSame(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	book := newBook("The Go Programming Language")
+	errs := make([]error, concurrency)
+
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = store.Save(ctx, book)
+		}()
+	}
+	wg.Wait()
+
+	saved := 0
+	for _, err := range errs {
+		if err == nil {
+			saved++
+			continue
+		}
+
+		require.ErrorIs(t, err, domain.ErrAlreadyExists)
+	}
+
+	require.Equal(t, 1, saved)
+}
+
+func testConcurrentSaveManySame(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	shared := newBook("The Go Programming Language")
+	batches := make([][]domain.Book, concurrency)
+	errs := make([]error, concurrency)
+
+	for i := range batches {
+		version := shared
+		version.Title = fmt.Sprintf("Edition %d", i)
+		batches[i] = []domain.Book{newBook(fmt.Sprintf("Book %d", i)), version}
+	}
+
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = store.SaveMany(ctx, batch)
+		}()
+	}
+	wg.Wait()
+
+	var winner domain.Book
+
+	for i, err := range errs {
+		if err == nil {
+			require.Equal(t, uuid.Nil, winner.ID, "the shared book must be saved only once")
+			winner = batches[i][1]
+			continue
+		}
+
+		var batchErr *domain.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		require.Len(t, batchErr.Failed, 1)
+		require.ErrorIs(t, batchErr.Failed[shared.ID], domain.ErrAlreadyExists)
+	}
+
+	ret, err := store.FindOne(ctx, shared.ID)
+	require.NoError(t, err)
+	require.Equal(t, winner, ret, "the saved book must not be overwritten by a later batch")
+
+	for _, batch := range batches {
+		_, err := store.FindOne(ctx, batch[0].ID)
+		require.NoError(t, err)
+	}
+}
+
+func testConcurrentUpdate(t *testing.T, store domain.Storer) {
+	ctx := context.Background()
+	book := newBook("The Go Programming Language")
+	require.NoError(t, store.Save(ctx, book))
+
+	versions := make(map[string]domain.Book, concurrency)
+	for i := range concurrency {
+		version := book
+		version.Title = fmt.Sprintf("Version %d", i)
+		versions[version.Title] = version
+	}
+
+	errs := make(chan error, 2*concurrency)
+
+	var wg sync.WaitGroup
+	for _, version := range versions {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- store.Update(ctx, version)
+		}()
+		go func() {
+			defer wg.Done()
+			ret, err := store.FindOne(ctx, book.ID)
+			if err == nil && ret.Title != book.Title && !reflect.DeepEqual(ret, versions[ret.Title]) {
+				err = fmt.Errorf("read a torn book: %v", ret)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	ret, err := store.FindOne(ctx, book.ID)
+	require.NoError(t, err)
+	require.Contains(t, versions, ret.Title)
+	require.Equal(t, versions[ret.Title], ret)
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes a domain invariant violated by a specific field.
+type FieldError struct {
+	Field string `json:"field"`
+	Err   string `json:"error"`
+}
+
+// ValidationError is returned when a NewBook does not satisfy the domain
+// invariants. It matches ErrInvalid when used with errors.Is.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = fmt.Sprintf("%s: %s", f.Field, f.Err)
+	}
+
+	return fmt.Sprintf("%s: %s", ErrInvalid, strings.Join(msgs, "; "))
+}
+
+// Is reports whether target is ErrInvalid.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// Validate checks the business invariants of a new book, regardless of the
+// entry point it comes from.
+func (nb NewBook) Validate() error {
+	var fields []FieldError
+
+	if strings.TrimSpace(nb.Title) == "" {
+		fields = append(fields, FieldError{Field: "title", Err: "title is required"})
+	}
+
+	if strings.TrimSpace(nb.Authors) == "" {
+		fields = append(fields, FieldError{Field: "authors", Err: "at least one author is required"})
+	}
+
+	if strings.TrimSpace(nb.Publisher) == "" {
+		fields = append(fields, FieldError{Field: "publisher", Err: "publisher is required"})
+	}
+
+	if nb.Pages < 1 {
+		fields = append(fields, FieldError{Field: "pages", Err: "pages must be 1 or greater"})
+	}
+
+	if !ValidISBN(nb.ISBN) {
+		fields = append(fields, FieldError{Field: "isbn", Err: "isbn must be a valid ISBN-10 or ISBN-13"})
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+
+	return nil
+}
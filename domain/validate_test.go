@@ -0,0 +1,65 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidISBN(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want bool
+	}{
+		{isbn: "978-0134190440", want: true},
+		{isbn: "9780134190440", want: true},
+		{isbn: "0-8044-2957-X", want: true},
+		{isbn: "0-8044-2957-x", want: true},
+		{isbn: "0134190440", want: true},
+		{isbn: "978-0134190441", want: false},
+		{isbn: "0-8044-2957-1", want: false},
+		{isbn: "123-0134190440", want: false},
+		{isbn: "isbn", want: false},
+		{isbn: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.isbn, func(t *testing.T) {
+			assert.Equal(t, tt.want, domain.ValidISBN(tt.isbn))
+		})
+	}
+}
+
+func TestNewBookValidate(t *testing.T) {
+	valid := domain.NewBook{
+		Title:     "The Hobbit",
+		Authors:   "J.R.R. Tolkien",
+		Publisher: "George Allen & Unwin",
+		Pages:     310,
+		ISBN:      "978-0547928227",
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		require.NoError(t, valid.Validate())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		nb := domain.NewBook{Title: "  ", Pages: 0, ISBN: "isbn"}
+		err := nb.Validate()
+
+		require.ErrorIs(t, err, domain.ErrInvalid)
+
+		var verr *domain.ValidationError
+		require.True(t, errors.As(err, &verr))
+
+		fields := make([]string, len(verr.Fields))
+		for i, f := range verr.Fields {
+			fields[i] = f.Field
+		}
+
+		assert.Equal(t, []string{"title", "authors", "publisher", "pages", "isbn"}, fields)
+	})
+}
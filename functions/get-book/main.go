@@ -4,13 +4,20 @@ import (
 	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/database/cache"
 	"github.com/rotiroti/alessandrina/sys/database/ddb"
 	"github.com/rotiroti/alessandrina/web"
 )
 
+// cacheTTL is how long a book is cached by default: the cache does not see
+// the writes of the other functions, so an updated or deleted book is served
+// as it was for up to this long.
+const cacheTTL = 5 * time.Second
+
 func main() {
 	ctx := context.Background()
 	if err := run(ctx); err != nil {
@@ -27,30 +34,44 @@ func getEnv(key, fallback string) string {
 
 func run(ctx context.Context) error {
 	dbTable := getEnv("DB_TABLE", "")
-	dbConn := getEnv("DB_CONNECTION", "aws")
-	dbLog := getEnv("DB_LOG", "false")
-
-	var (
-		store *ddb.Store
-		err   error
-	)
-
-	switch dbConn {
-	case "localstack":
-		store, err = ddb.NewStore(ctx, dbTable, ddb.WithLocalStack())
-	default:
-		if dbLog == "true" {
-			store, err = ddb.NewStore(ctx, dbTable, ddb.WithClientLog())
-		} else {
-			store, err = ddb.NewStore(ctx, dbTable)
+	idStrategy := getEnv("ID_STRATEGY", domain.IDStrategyRandom)
+
+	opts, err := ddb.OptionsFromEnv(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	store, err := ddb.NewStore(ctx, dbTable, opts...)
+	if err != nil {
+		return err
+	}
+
+	var storer domain.Storer = store
+
+	// The books read by the warm instances are cached when CACHE_SIZE is set.
+	cacheOpts, cached, err := cache.OptionsFromEnv(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	if cached {
+		// The books are written by the other functions, whose writes this
+		// cache does not see: the books are cached for cacheTTL unless
+		// CACHE_TTL says otherwise, and a book not found is only cached when
+		// CACHE_NEGATIVE_TTL asks for it.
+		cacheOpts = append([]cache.Option{cache.WithTTL(cacheTTL), cache.WithNegativeTTL(0)}, cacheOpts...)
+
+		if storer, err = cache.NewStore(store, cacheOpts...); err != nil {
+			return err
 		}
 	}
 
+	generator, err := domain.NewIDGenerator(idStrategy)
 	if err != nil {
 		return err
 	}
 
-	bookCore := domain.NewBookCore(store)
+	bookCore := domain.NewBookCoreWithIDGenerator(storer, generator)
 	handler := web.NewAPIGatewayV2Handler(bookCore)
 
 	lambda.Start(handler.GetBook)
@@ -27,30 +27,24 @@ func getEnv(key, fallback string) string {
 
 func run(ctx context.Context) error {
 	dbTable := getEnv("DB_TABLE", "")
-	dbConn := getEnv("DB_CONNECTION", "aws")
-	dbLog := getEnv("DB_LOG", "false")
-
-	var (
-		store *ddb.Store
-		err   error
-	)
-
-	switch dbConn {
-	case "localstack":
-		store, err = ddb.NewStore(ctx, dbTable, ddb.WithLocalStack())
-	default:
-		if dbLog == "true" {
-			store, err = ddb.NewStore(ctx, dbTable, ddb.WithClientLog())
-		} else {
-			store, err = ddb.NewStore(ctx, dbTable)
-		}
+	idStrategy := getEnv("ID_STRATEGY", domain.IDStrategyRandom)
+
+	opts, err := ddb.OptionsFromEnv(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	store, err := ddb.NewStore(ctx, dbTable, opts...)
+	if err != nil {
+		return err
 	}
 
+	generator, err := domain.NewIDGenerator(idStrategy)
 	if err != nil {
 		return err
 	}
 
-	bookCore := domain.NewBookCore(store)
+	bookCore := domain.NewBookCoreWithIDGenerator(store, generator)
 	handler := web.NewAPIGatewayV2Handler(bookCore)
 
 	lambda.Start(handler.GetBooks)
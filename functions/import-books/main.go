@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/database/ddb"
+	"github.com/rotiroti/alessandrina/web"
+)
+
+func main() {
+	ctx := context.Background()
+	if err := run(ctx); err != nil {
+		log.Fatalf("startup: %v\n", err)
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func run(ctx context.Context) error {
+	dbTable := getEnv("DB_TABLE", "")
+	idStrategy := getEnv("ID_STRATEGY", domain.IDStrategyRandom)
+
+	opts, err := ddb.OptionsFromEnv(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	store, err := ddb.NewStore(ctx, dbTable, opts...)
+	if err != nil {
+		return err
+	}
+
+	generator, err := domain.NewIDGenerator(idStrategy)
+	if err != nil {
+		return err
+	}
+
+	bookCore := domain.NewBookCoreWithIDGenerator(store, generator)
+	handler := web.NewAPIGatewayV2Handler(bookCore)
+
+	lambda.Start(handler.ImportBooks)
+
+	return nil
+}
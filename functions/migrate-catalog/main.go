@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rotiroti/alessandrina/sys/database/ddb"
+)
+
+func main() {
+	ctx := context.Background()
+	if err := run(ctx); err != nil {
+		log.Fatalf("startup: %v\n", err)
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func run(ctx context.Context) error {
+	dbTable := getEnv("DB_TABLE", "")
+
+	opts, err := ddb.OptionsFromEnv(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	store, err := ddb.NewStore(ctx, dbTable, opts...)
+	if err != nil {
+		return err
+	}
+
+	lambda.Start(cfn.LambdaWrap(migrate(store)))
+
+	return nil
+}
+
+// migrate returns the handler of the custom resource copying the books of the
+// Source table into the table of the store, on its creation and on every
+// update. Copied books are skipped, so running it again only copies the ones
+// written to Source in the meantime.
+func migrate(store *ddb.Store) cfn.CustomResourceFunction {
+	return func(ctx context.Context, event cfn.Event) (string, map[string]interface{}, error) {
+		// Deleting the resource leaves both tables as they are.
+		if event.RequestType == cfn.RequestDelete {
+			return event.PhysicalResourceID, nil, nil
+		}
+
+		source, _ := event.ResourceProperties["Source"].(string)
+		if source == "" {
+			return "", nil, errors.New("migrate-catalog: missing Source property")
+		}
+
+		physicalID := event.LogicalResourceID + "-" + source
+
+		progress, err := store.Migrate(ctx, source, nil, nil)
+		if err != nil {
+			return physicalID, nil, err
+		}
+
+		log.Printf("migrate-catalog: scanned %d items, copied %d, failed %d", progress.Scanned, progress.Upgraded, progress.Failed)
+
+		return physicalID, map[string]interface{}{
+			"Scanned": progress.Scanned,
+			"Copied":  progress.Upgraded,
+			"Failed":  progress.Failed,
+		}, nil
+	}
+}
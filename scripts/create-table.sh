@@ -1,7 +1,7 @@
 #!/usr/bin/env bash
 
-# Create a DynamoDB table using the AWS CLI and the localstack endpoint
-# Usage: ./create-table.sh <table_name>
+# Create a DynamoDB table using the AWS CLI against a local endpoint
+# Usage: DB_ENDPOINT=<url> ./create-table.sh <table_name>
 
 # Check if the table name is provided
 if [ $# -eq 0 ]; then
@@ -12,18 +12,27 @@ fi
 # Get the table name
 table_name=$1
 
+# LocalStack by default, e.g. DB_ENDPOINT=http://localhost:8000 for DynamoDB Local
+endpoint=${DB_ENDPOINT:-http://localhost:4566}
+
 # Check if the table exists
 if aws dynamodb describe-table \
-    --endpoint-url http://localhost:4566 \
+    --endpoint-url "$endpoint" \
     --table-name "$table_name" > /dev/null 2>&1; then
     echo "Table $table_name already exists"
     exit 1
 fi
 
-# Create the table
+# Create the table (single-table layout, see sys/database/ddb/keys.go)
 aws dynamodb create-table \
-    --endpoint-url http://localhost:4566 \
+    --endpoint-url "$endpoint" \
     --table-name "$table_name" \
-    --attribute-definitions AttributeName=id,AttributeType=S \
-    --key-schema AttributeName=id,KeyType=HASH \
+    --attribute-definitions \
+        AttributeName=pk,AttributeType=S AttributeName=sk,AttributeType=S \
+        AttributeName=gsi1pk,AttributeType=S AttributeName=gsi1sk,AttributeType=S \
+        AttributeName=kind,AttributeType=S AttributeName=id,AttributeType=S \
+    --key-schema AttributeName=pk,KeyType=HASH AttributeName=sk,KeyType=RANGE \
+    --global-secondary-indexes \
+        "IndexName=gsi1,KeySchema=[{AttributeName=gsi1pk,KeyType=HASH},{AttributeName=gsi1sk,KeyType=RANGE}],Projection={ProjectionType=ALL}" \
+        "IndexName=kind-id-index,KeySchema=[{AttributeName=kind,KeyType=HASH},{AttributeName=id,KeyType=RANGE}],Projection={ProjectionType=ALL}" \
     --billing-mode PAY_PER_REQUEST
\ No newline at end of file
@@ -1,7 +1,7 @@
 #!/usr/bin/env bash
 
-# Delete a DynamoDB table using the AWS CLI and the localstack endpoint
-# Usage: ./delete-table.sh <table_name>
+# Delete a DynamoDB table using the AWS CLI against a local endpoint
+# Usage: DB_ENDPOINT=<url> ./delete-table.sh <table_name>
 
 # Check if the table name is provided
 if [ $# -eq 0 ]; then
@@ -12,9 +12,12 @@ fi
 # Get the table name
 table_name=$1
 
+# LocalStack by default, e.g. DB_ENDPOINT=http://localhost:8000 for DynamoDB Local
+endpoint=${DB_ENDPOINT:-http://localhost:4566}
+
 # Check if the table exists
 if ! aws dynamodb describe-table \
-    --endpoint-url http://localhost:4566 \
+    --endpoint-url "$endpoint" \
     --table-name "$table_name" > /dev/null 2>&1; then
     echo "Table $table_name does not exists"
     exit 1
@@ -22,5 +25,5 @@ fi
 
 # Delete the table
 aws dynamodb delete-table \
-    --endpoint-url http://localhost:4566 \
+    --endpoint-url "$endpoint" \
     --table-name "$table_name"
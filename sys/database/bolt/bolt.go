@@ -0,0 +1,618 @@
+// Package bolt implements domain.Storer on a bbolt database, a single file
+// embedded in the binary for the local server and the edge kiosks.
+//
+// The books are stored in the books bucket under their 16-byte ID, so that
+// the order of the keys is the order of the IDs. The index buckets map a key
+// derived from a book followed by its ID to nothing:
+//
+//	bucket          key
+//	books           <id>
+//	books_by_isbn   <isbn13> 0x00 <id>
+//	books_by_title  <folded title> 0x00 <id>
+//
+// Every write updates the books and their index entries in a single
+// transaction.
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"go.etcd.io/bbolt"
+)
+
+// ErrMissingPath is returned when the path of the database is empty.
+var ErrMissingPath = errors.New("missing database path")
+
+// DefaultTimeout is how long NewStore waits for the lock of a database
+// opened by another process.
+const DefaultTimeout = time.Second
+
+// errCorruptKey is returned when an index entry does not end with an ID.
+var errCorruptKey = errors.New("corrupt index key")
+
+var (
+	booksBucket  = []byte("books")
+	isbnBucket   = []byte("books_by_isbn")
+	titleBucket  = []byte("books_by_title")
+	indexBuckets = [][]byte{isbnBucket, titleBucket}
+	keySeparator = []byte{0}
+)
+
+// Option is a function that configures a Store.
+type Option func(*Store) error
+
+// WithTimeout returns a Store Option that sets how long NewStore waits for
+// the lock of a database opened by another process, in place of
+// DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Store) error {
+		if d <= 0 {
+			return fmt.Errorf("bolt.withtimeout: invalid timeout %s", d)
+		}
+
+		s.timeout = d
+
+		return nil
+	}
+}
+
+// Store manages the set of APIs for book access on bbolt.
+type Store struct {
+	db      *bbolt.DB
+	timeout time.Duration
+}
+
+// Ensure Store implements the Storer interface.
+var _ domain.Storer = (*Store)(nil)
+
+// NewStore returns a Store on the database at path, created when missing.
+// The database is locked until Close, a second Store on the same file waits
+// for the lock and fails with domain.ErrUnavailable.
+func NewStore(path string, opts ...Option) (*Store, error) {
+	store := &Store{timeout: DefaultTimeout}
+
+	for _, opt := range opts {
+		if err := opt(store); err != nil {
+			return nil, fmt.Errorf("bolt.newstore option: %w", err)
+		}
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("bolt.newstore: %w", ErrMissingPath)
+	}
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: store.timeout})
+	if err != nil {
+		return nil, fmt.Errorf("bolt.newstore open: %w", translateError(err))
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range append([][]byte{booksBucket}, indexBuckets...) {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+
+		return nil, fmt.Errorf("bolt.newstore: %w", translateError(err))
+	}
+
+	store.db = db
+
+	return store, nil
+}
+
+// Close closes the database, releasing its lock.
+func (s *Store) Close() error {
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("bolt.close: %w", err)
+	}
+
+	return nil
+}
+
+// translateError maps the errors returned by bbolt to the domain error set,
+// keeping the original error in the chain.
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, bbolt.ErrTimeout), errors.Is(err, bbolt.ErrDatabaseNotOpen), errors.Is(err, bbolt.ErrDatabaseReadOnly):
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, err)
+	default:
+		return err
+	}
+}
+
+// record is the value stored for a book, its ID is the key.
+type record struct {
+	Title      string   `json:"title"`
+	Authors    string   `json:"authors,omitempty"`
+	Publisher  string   `json:"publisher,omitempty"`
+	Pages      int      `json:"pages,omitempty"`
+	ISBN       string   `json:"isbn,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	MergedInto string   `json:"merged_into,omitempty"`
+}
+
+func encodeBook(book domain.Book) ([]byte, error) {
+	r := record{
+		Title:     book.Title,
+		Authors:   book.Authors,
+		Publisher: book.Publisher,
+		Pages:     book.Pages,
+		ISBN:      book.ISBN,
+		Tags:      book.Tags,
+	}
+
+	if book.MergedInto != uuid.Nil {
+		r.MergedInto = book.MergedInto.String()
+	}
+
+	return json.Marshal(r)
+}
+
+func decodeBook(key, value []byte) (domain.Book, error) {
+	bookID, err := uuid.FromBytes(key)
+	if err != nil {
+		return domain.Book{}, fmt.Errorf("invalid key %x: %w", key, err)
+	}
+
+	var r record
+	if err := json.Unmarshal(value, &r); err != nil {
+		return domain.Book{}, fmt.Errorf("invalid book %s: %w", bookID, err)
+	}
+
+	book := domain.Book{
+		ID:        bookID,
+		Title:     r.Title,
+		Authors:   r.Authors,
+		Publisher: r.Publisher,
+		Pages:     r.Pages,
+		ISBN:      r.ISBN,
+		Tags:      r.Tags,
+	}
+
+	if r.MergedInto != "" {
+		if book.MergedInto, err = uuid.Parse(r.MergedInto); err != nil {
+			return domain.Book{}, fmt.Errorf("invalid merged_into of %s: %w", bookID, err)
+		}
+	}
+
+	return book, nil
+}
+
+// foldTitle returns the form of a title stored in the title index: lower
+// case, with runs of spaces collapsed.
+func foldTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// indexKey returns the key of the entry of a book in an index.
+func indexKey(value string, bookID uuid.UUID) []byte {
+	key := make([]byte, 0, len(value)+1+len(bookID))
+	key = append(key, value...)
+	key = append(key, keySeparator...)
+
+	return append(key, bookID[:]...)
+}
+
+// indexKeys returns the keys of the entries of a book in the index buckets,
+// in the order of indexBuckets; a zero key means no entry.
+func indexKeys(book domain.Book) [][]byte {
+	keys := make([][]byte, len(indexBuckets))
+
+	if isbn13 := domain.ISBN13(book.ISBN); isbn13 != "" {
+		keys[0] = indexKey(isbn13, book.ID)
+	}
+
+	if title := foldTitle(book.Title); title != "" {
+		keys[1] = indexKey(title, book.ID)
+	}
+
+	return keys
+}
+
+// put writes a book and its index entries, replacing the ones of previous
+// when not nil.
+func put(tx *bbolt.Tx, book domain.Book, previous *domain.Book) error {
+	value, err := encodeBook(book)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil {
+		for i, key := range indexKeys(*previous) {
+			if key != nil {
+				if err := tx.Bucket(indexBuckets[i]).Delete(key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for i, key := range indexKeys(book) {
+		if key != nil {
+			if err := tx.Bucket(indexBuckets[i]).Put(key, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Bucket(booksBucket).Put(book.ID[:], value)
+}
+
+// get reads a book, the second result is false when it does not exist.
+func get(tx *bbolt.Tx, bookID uuid.UUID) (domain.Book, bool, error) {
+	value := tx.Bucket(booksBucket).Get(bookID[:])
+	if value == nil {
+		return domain.Book{}, false, nil
+	}
+
+	book, err := decodeBook(bookID[:], value)
+
+	return book, err == nil, err
+}
+
+// Save adds a new book into the bbolt database.
+func (s *Store) Save(_ context.Context, book domain.Book) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(booksBucket).Get(book.ID[:]) != nil {
+			return domain.ErrAlreadyExists
+		}
+
+		return put(tx, book, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("bolt.save: %w", translateError(err))
+	}
+
+	return nil
+}
+
+// SaveMany adds a batch of new books into the bbolt database, in a single
+// transaction.
+//
+// Books whose ID already exists are reported in a domain.BatchError, the
+// others are saved.
+func (s *Store) SaveMany(_ context.Context, books []domain.Book) error {
+	failed := make(map[uuid.UUID]error)
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		for _, book := range books {
+			if tx.Bucket(booksBucket).Get(book.ID[:]) != nil {
+				failed[book.ID] = fmt.Errorf("bolt.savemany: %w", domain.ErrAlreadyExists)
+				continue
+			}
+
+			if err := put(tx, book, nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bolt.savemany: %w", translateError(err))
+	}
+
+	if len(failed) > 0 {
+		return &domain.BatchError{Failed: failed}
+	}
+
+	return nil
+}
+
+// FindAll returns all books from the bbolt database, ordered by ID.
+func (s *Store) FindAll(_ context.Context) ([]domain.Book, error) {
+	books := []domain.Book{}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(booksBucket).ForEach(func(key, value []byte) error {
+			book, err := decodeBook(key, value)
+			if err != nil {
+				return err
+			}
+
+			books = append(books, book)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return []domain.Book{}, fmt.Errorf("bolt.findall: %w", translateError(err))
+	}
+
+	return books, nil
+}
+
+// FindPage returns up to limit books with an ID greater than after, ordered
+// by ID, from the bbolt database.
+func (s *Store) FindPage(_ context.Context, after uuid.UUID, limit int) ([]domain.Book, error) {
+	books := make([]domain.Book, 0, max(limit, 0))
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(booksBucket).Cursor()
+
+		key, value := c.Seek(after[:])
+		if key != nil && bytes.Equal(key, after[:]) {
+			key, value = c.Next()
+		}
+
+		for ; key != nil && len(books) < limit; key, value = c.Next() {
+			book, err := decodeBook(key, value)
+			if err != nil {
+				return err
+			}
+
+			books = append(books, book)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return []domain.Book{}, fmt.Errorf("bolt.findpage: %w", translateError(err))
+	}
+
+	return books, nil
+}
+
+// FindMany returns the books matching the given IDs from the bbolt database.
+// IDs without a matching book are ignored.
+func (s *Store) FindMany(_ context.Context, bookIDs []uuid.UUID) ([]domain.Book, error) {
+	books := make([]domain.Book, 0, len(bookIDs))
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		for _, bookID := range bookIDs {
+			book, ok, err := get(tx, bookID)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				books = append(books, book)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return []domain.Book{}, fmt.Errorf("bolt.findmany: %w", translateError(err))
+	}
+
+	return books, nil
+}
+
+// FindOne returns a book from the bbolt database.
+func (s *Store) FindOne(_ context.Context, bookID uuid.UUID) (domain.Book, error) {
+	var book domain.Book
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		found, ok, err := get(tx, bookID)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		book = found
+
+		return nil
+	})
+	if err != nil {
+		return domain.Book{}, fmt.Errorf("bolt.findone: %w", translateError(err))
+	}
+
+	return book, nil
+}
+
+// FindByISBN returns the books with the given ISBN, in either the ISBN-10 or
+// the ISBN-13 form, ordered by ID.
+func (s *Store) FindByISBN(_ context.Context, isbn string) ([]domain.Book, error) {
+	isbn13 := domain.ISBN13(isbn)
+	if isbn13 == "" {
+		isbn13 = domain.NormalizeISBN(isbn)
+	}
+
+	books, err := s.scanIndex(isbnBucket, append([]byte(isbn13), keySeparator...), 0)
+	if err != nil {
+		return []domain.Book{}, fmt.Errorf("bolt.findbyisbn: %w", err)
+	}
+
+	return books, nil
+}
+
+// FindByTitlePrefix returns up to limit books whose title starts with prefix,
+// ignoring case and repeated spaces, ordered by title. A limit that is not
+// positive returns all of them.
+func (s *Store) FindByTitlePrefix(_ context.Context, prefix string, limit int) ([]domain.Book, error) {
+	books, err := s.scanIndex(titleBucket, []byte(foldTitle(prefix)), limit)
+	if err != nil {
+		return []domain.Book{}, fmt.Errorf("bolt.findbytitleprefix: %w", err)
+	}
+
+	return books, nil
+}
+
+// scanIndex returns up to limit books with an entry starting with prefix in
+// the given index bucket, all of them when limit is not positive.
+func (s *Store) scanIndex(bucket, prefix []byte, limit int) ([]domain.Book, error) {
+	books := []domain.Book{}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+
+		for key, _ := c.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = c.Next() {
+			if limit > 0 && len(books) == limit {
+				break
+			}
+
+			if len(key) < len(uuid.Nil)+1 {
+				return fmt.Errorf("%w: %x", errCorruptKey, key)
+			}
+
+			bookID, err := uuid.FromBytes(key[len(key)-len(uuid.Nil):])
+			if err != nil {
+				return fmt.Errorf("%w: %x", errCorruptKey, key)
+			}
+
+			book, ok, err := get(tx, bookID)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				books = append(books, book)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return books, nil
+}
+
+// Update replaces an existing book in the bbolt database.
+func (s *Store) Update(_ context.Context, book domain.Book) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		previous, ok, err := get(tx, book.ID)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return domain.ErrNotFound
+		}
+
+		return put(tx, book, &previous)
+	})
+	if err != nil {
+		return fmt.Errorf("bolt.update: %w", translateError(err))
+	}
+
+	return nil
+}
+
+// CompareAndSwap replaces the previous books with books in the bbolt
+// database, in a single transaction, provided none of them changed since it
+// was read.
+func (s *Store) CompareAndSwap(_ context.Context, previous, books []domain.Book) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		for i, book := range books {
+			stored, ok, err := get(tx, previous[i].ID)
+			if err != nil {
+				return err
+			}
+
+			if !ok || !stored.Equal(previous[i]) {
+				return domain.ErrPreconditionFailed
+			}
+
+			if err := put(tx, book, &stored); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bolt.compareandswap: %w", translateError(err))
+	}
+
+	return nil
+}
+
+// Delete removes a book and its index entries from the bbolt database,
+// deleting a missing book is not an error.
+func (s *Store) Delete(_ context.Context, bookID uuid.UUID) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		book, ok, err := get(tx, bookID)
+		if err != nil || !ok {
+			return err
+		}
+
+		for i, key := range indexKeys(book) {
+			if key != nil {
+				if err := tx.Bucket(indexBuckets[i]).Delete(key); err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Bucket(booksBucket).Delete(bookID[:])
+	})
+	if err != nil {
+		return fmt.Errorf("bolt.delete: %w", translateError(err))
+	}
+
+	return nil
+}
+
+// Backup writes a consistent copy of the database to w while the Store keeps
+// serving reads and writes, returning the number of bytes written.
+func (s *Store) Backup(w io.Writer) (int64, error) {
+	var n int64
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+
+		return err
+	})
+	if err != nil {
+		return n, fmt.Errorf("bolt.backup: %w", translateError(err))
+	}
+
+	return n, nil
+}
+
+// BackupFile writes a consistent copy of the database to the file at path,
+// like Backup. The copy is written next to path and renamed, so path never
+// holds a partial backup.
+func (s *Store) BackupFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("bolt.backupfile: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := s.Backup(tmp); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("bolt.backupfile: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("bolt.backupfile: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("bolt.backupfile: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("bolt.backupfile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+package bolt_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/domain/storertest"
+	"github.com/rotiroti/alessandrina/sys/database/bolt"
+	"github.com/stretchr/testify/require"
+)
+
+func newStore(t *testing.T) *bolt.Store {
+	t.Helper()
+
+	store, err := bolt.NewStore(filepath.Join(t.TempDir(), "catalog.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func TestBoltStore(t *testing.T) {
+	t.Parallel()
+
+	book := domain.Book{
+		ID:        uuid.New(),
+		Title:     "The Go Programming Language",
+		Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher: "Addison-Wesley Professional",
+		Pages:     400,
+		ISBN:      "978-0134190440",
+		Tags:      []string{"go"},
+	}
+
+	t.Run("should save a new book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+	})
+
+	t.Run("should not save an existing book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+		err2 := store.Save(context.Background(), book)
+		require.ErrorIs(t, err2, domain.ErrAlreadyExists)
+	})
+
+	t.Run("should save a batch of books", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+
+		newBook := book
+		newBook.ID = uuid.New()
+		err2 := store.SaveMany(context.Background(), []domain.Book{newBook, book})
+
+		var batchErr *domain.BatchError
+		require.ErrorAs(t, err2, &batchErr)
+		require.Len(t, batchErr.Failed, 1)
+		require.ErrorIs(t, batchErr.Failed[book.ID], domain.ErrAlreadyExists)
+
+		ret, err3 := store.FindOne(context.Background(), newBook.ID)
+		require.NoError(t, err3)
+		require.Equal(t, newBook, ret)
+	})
+
+	t.Run("should return a book by ID", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+		ret, err2 := store.FindOne(context.Background(), book.ID)
+		require.NoError(t, err2)
+		require.Equal(t, book, ret)
+	})
+
+	t.Run("should return the books matching the given IDs", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+		ret, err2 := store.FindMany(context.Background(), []uuid.UUID{uuid.New(), book.ID})
+		require.NoError(t, err2)
+		require.Equal(t, []domain.Book{book}, ret)
+	})
+
+	t.Run("should throw error for unfound book ID", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		_, err := store.FindOne(context.Background(), book.ID)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("should update an existing book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+
+		updated := book
+		updated.MergedInto = uuid.New()
+		err = store.Update(context.Background(), updated)
+		require.NoError(t, err)
+
+		ret, err := store.FindOne(context.Background(), book.ID)
+		require.NoError(t, err)
+		require.Equal(t, updated, ret)
+	})
+
+	t.Run("should not update a non existing book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		err := store.Update(context.Background(), book)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("should return the books ordered by ID one page at a time", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		ids := make([]uuid.UUID, 5)
+
+		for i := range ids {
+			ids[i] = domain.TimeOrderedID(domain.NewBook{})
+			err := store.Save(context.Background(), domain.Book{ID: ids[i]})
+			require.NoError(t, err)
+		}
+
+		first, err := store.FindPage(context.Background(), uuid.Nil, 3)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{{ID: ids[0]}, {ID: ids[1]}, {ID: ids[2]}}, first)
+
+		second, err := store.FindPage(context.Background(), ids[2], 3)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{{ID: ids[3]}, {ID: ids[4]}}, second)
+	})
+
+	t.Run("should delete an existing book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+		err2 := store.Delete(context.Background(), book.ID)
+		require.NoError(t, err2)
+
+		ret, err3 := store.FindByISBN(context.Background(), book.ISBN)
+		require.NoError(t, err3)
+		require.Empty(t, ret)
+	})
+
+	t.Run("should not throw error for deleting a non existing book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		err := store.Delete(context.Background(), book.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("should return all books", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+
+		for i := 0; i < 10; i++ {
+			book := domain.Book{
+				ID:        uuid.New(),
+				Title:     "The Go Programming Language",
+				Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+				Publisher: "Addison-Wesley Professional",
+				Pages:     400,
+			}
+			err := store.Save(context.Background(), book)
+			require.NoError(t, err)
+		}
+
+		ret, err2 := store.FindAll(context.Background())
+		require.NoError(t, err2)
+		require.Len(t, ret, 10)
+	})
+}
+
+func TestIndexes(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	gopl := domain.Book{ID: uuid.New(), Title: "The Go Programming Language", ISBN: "978-0134190440"}
+	learning := domain.Book{ID: uuid.New(), Title: "Learning  Go", ISBN: "978-1492077213"}
+	require.NoError(t, store.SaveMany(ctx, []domain.Book{gopl, learning}))
+
+	t.Run("ISBN", func(t *testing.T) {
+		ret, err := store.FindByISBN(ctx, "0-13-419044-0")
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{gopl}, ret)
+	})
+
+	t.Run("TitlePrefix", func(t *testing.T) {
+		ret, err := store.FindByTitlePrefix(ctx, "learning g", 0)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{learning}, ret)
+
+		ret, err = store.FindByTitlePrefix(ctx, "", 1)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{learning}, ret)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		renamed := gopl
+		renamed.Title = "Go Programming"
+		renamed.ISBN = ""
+		require.NoError(t, store.Update(ctx, renamed))
+
+		ret, err := store.FindByTitlePrefix(ctx, "the go", 0)
+		require.NoError(t, err)
+		require.Empty(t, ret)
+
+		ret, err = store.FindByTitlePrefix(ctx, "go pro", 0)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{renamed}, ret)
+
+		ret, err = store.FindByISBN(ctx, gopl.ISBN)
+		require.NoError(t, err)
+		require.Empty(t, ret)
+	})
+}
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	book := domain.Book{ID: uuid.New(), Title: "Learning Go", ISBN: "978-1492077213"}
+
+	store, err := bolt.NewStore(filepath.Join(dir, "catalog.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Save(ctx, book))
+
+	var buf bytes.Buffer
+	n, err := store.Backup(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+
+	// The store keeps serving writes while the backup is restored elsewhere.
+	path := filepath.Join(dir, "backup.db")
+	require.NoError(t, store.BackupFile(path))
+	require.NoError(t, store.Save(ctx, domain.Book{ID: uuid.New(), Title: "After the backup"}))
+
+	restored, err := bolt.NewStore(path)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	books, err := restored.FindAll(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Book{book}, books)
+
+	ret, err := restored.FindByISBN(ctx, book.ISBN)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Book{book}, ret)
+}
+
+func TestLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.db")
+	store, err := bolt.NewStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = bolt.NewStore(path, bolt.WithTimeout(50*time.Millisecond))
+	require.ErrorIs(t, err, domain.ErrUnavailable)
+
+	_, err = bolt.NewStore("")
+	require.ErrorIs(t, err, bolt.ErrMissingPath)
+}
+
+func TestConformance(t *testing.T) {
+	storertest.Run(t, func(t *testing.T) domain.Storer {
+		return newStore(t)
+	})
+}
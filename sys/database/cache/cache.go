@@ -0,0 +1,435 @@
+// Package cache implements a read-through cache of the books of any
+// domain.Storer.
+//
+// FindOne and FindMany are answered from a size-bounded LRU cache, whose
+// entries expire after a TTL. The books not found are cached too, for a
+// shorter TTL, and concurrent misses of the same book share a single read
+// of the underlying Storer. Save, SaveMany, Update and Delete invalidate the
+// books they write, the other methods go straight to the underlying Storer.
+//
+// The cache only sees the writes made through it: the writes of other
+// processes, e.g. other AWS Lambda instances, are seen once the cached
+// books expire.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultSize is the number of books cached by default.
+	DefaultSize = 1024
+
+	// DefaultTTL is how long a book is cached by default.
+	DefaultTTL = time.Minute
+
+	// DefaultNegativeTTL is how long a book not found is cached by default.
+	DefaultNegativeTTL = 10 * time.Second
+)
+
+// Option is a function that configures a Store.
+type Option func(*Store) error
+
+// WithSize returns a Store Option that sets the number of books, found or
+// not, kept in the cache, in place of DefaultSize. The least recently used
+// ones are evicted first.
+func WithSize(n int) Option {
+	return func(s *Store) error {
+		if n <= 0 {
+			return fmt.Errorf("cache.withsize: invalid size %d", n)
+		}
+
+		s.size = n
+
+		return nil
+	}
+}
+
+// WithTTL returns a Store Option that sets how long a book is cached, in
+// place of DefaultTTL.
+func WithTTL(d time.Duration) Option {
+	return func(s *Store) error {
+		if d <= 0 {
+			return fmt.Errorf("cache.withttl: invalid TTL %s", d)
+		}
+
+		s.ttl = d
+
+		return nil
+	}
+}
+
+// WithNegativeTTL returns a Store Option that sets how long a book not found
+// is cached, in place of DefaultNegativeTTL. Zero disables the caching of
+// the books not found.
+func WithNegativeTTL(d time.Duration) Option {
+	return func(s *Store) error {
+		if d < 0 {
+			return fmt.Errorf("cache.withnegativettl: invalid TTL %s", d)
+		}
+
+		s.negativeTTL = d
+
+		return nil
+	}
+}
+
+// Stats are the counters of a Store.
+type Stats struct {
+	// Hits is the number of books read from the cache, found or not.
+	Hits uint64
+
+	// Misses is the number of books read from the underlying Storer.
+	Misses uint64
+
+	// Evictions is the number of books evicted to make room for others.
+	Evictions uint64
+
+	// Entries is the number of books in the cache.
+	Entries int
+}
+
+// entry is a cached book, or a book not found when err is set.
+type entry struct {
+	bookID  uuid.UUID
+	book    domain.Book
+	err     error
+	expires time.Time
+}
+
+// flight is a read of the underlying Storer in progress, stale when the
+// book was written in the meantime, so that the result is not cached.
+type flight struct {
+	stale bool
+}
+
+// Store caches the books of a domain.Storer.
+type Store struct {
+	next        domain.Storer
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]*list.Element
+	lru     *list.List                         // of *entry, most recently used first
+	flights map[uuid.UUID]map[*flight]struct{} // FindOne and FindMany may both be reading a book
+	group   singleflight.Group
+
+	hits, misses, evictions atomic.Uint64
+}
+
+// Ensure Store implements the Storer interface.
+var _ domain.Storer = (*Store)(nil)
+
+// NewStore returns a Store caching the books of next.
+func NewStore(next domain.Storer, opts ...Option) (*Store, error) {
+	store := &Store{
+		next:        next,
+		size:        DefaultSize,
+		ttl:         DefaultTTL,
+		negativeTTL: DefaultNegativeTTL,
+		now:         time.Now,
+		entries:     make(map[uuid.UUID]*list.Element),
+		lru:         list.New(),
+		flights:     make(map[uuid.UUID]map[*flight]struct{}),
+	}
+
+	for _, opt := range opts {
+		if err := opt(store); err != nil {
+			return nil, fmt.Errorf("cache.newstore option: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// Stats returns the counters of the Store.
+func (s *Store) Stats() Stats {
+	s.mu.Lock()
+	entries := s.lru.Len()
+	s.mu.Unlock()
+
+	return Stats{
+		Hits:      s.hits.Load(),
+		Misses:    s.misses.Load(),
+		Evictions: s.evictions.Load(),
+		Entries:   entries,
+	}
+}
+
+// get returns the cached entry of a book, nil when missing or expired.
+func (s *Store) get(bookID uuid.UUID) *entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[bookID]
+	if !ok {
+		return nil
+	}
+
+	e := elem.Value.(*entry) //nolint:forcetypeassert
+	if !s.now().Before(e.expires) {
+		s.lru.Remove(elem)
+		delete(s.entries, bookID)
+
+		return nil
+	}
+
+	s.lru.MoveToFront(elem)
+
+	return e
+}
+
+// begin registers a read of the underlying Storer for a book.
+func (s *Store) begin(bookID uuid.UUID) *flight {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := &flight{}
+
+	if s.flights[bookID] == nil {
+		s.flights[bookID] = make(map[*flight]struct{})
+	}
+
+	s.flights[bookID][f] = struct{}{}
+
+	return f
+}
+
+// end caches the result of a read of the underlying Storer, unless the book
+// was written while it was read. Only domain.ErrNotFound is cached among the
+// errors.
+func (s *Store) end(bookID uuid.UUID, f *flight, book domain.Book, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if flights, ok := s.flights[bookID]; ok {
+		delete(flights, f)
+
+		if len(flights) == 0 {
+			delete(s.flights, bookID)
+		}
+	}
+
+	if f.stale {
+		return
+	}
+
+	ttl := s.ttl
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) || s.negativeTTL == 0 {
+			return
+		}
+
+		ttl = s.negativeTTL
+	}
+
+	e := &entry{bookID: bookID, book: book, err: err, expires: s.now().Add(ttl)}
+
+	if elem, ok := s.entries[bookID]; ok {
+		elem.Value = e
+		s.lru.MoveToFront(elem)
+
+		return
+	}
+
+	s.entries[bookID] = s.lru.PushFront(e)
+
+	for s.lru.Len() > s.size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*entry).bookID) //nolint:forcetypeassert
+		s.evictions.Add(1)
+	}
+}
+
+// invalidate removes the books from the cache, and keeps the reads in
+// progress from caching them.
+func (s *Store) invalidate(bookIDs ...uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, bookID := range bookIDs {
+		if elem, ok := s.entries[bookID]; ok {
+			s.lru.Remove(elem)
+			delete(s.entries, bookID)
+		}
+
+		for f := range s.flights[bookID] {
+			f.stale = true
+		}
+
+		delete(s.flights, bookID)
+
+		// The next reads do not wait for a read started before the write.
+		s.group.Forget(bookID.String())
+	}
+}
+
+// Save saves a new book and invalidates it.
+func (s *Store) Save(ctx context.Context, book domain.Book) error {
+	defer s.invalidate(book.ID)
+
+	return s.next.Save(ctx, book)
+}
+
+// SaveMany saves a batch of new books and invalidates them.
+func (s *Store) SaveMany(ctx context.Context, books []domain.Book) error {
+	bookIDs := make([]uuid.UUID, len(books))
+	for i, book := range books {
+		bookIDs[i] = book.ID
+	}
+
+	defer s.invalidate(bookIDs...)
+
+	return s.next.SaveMany(ctx, books)
+}
+
+// FindAll returns all books from the underlying Storer.
+func (s *Store) FindAll(ctx context.Context) ([]domain.Book, error) {
+	return s.next.FindAll(ctx)
+}
+
+// FindPage returns a page of books from the underlying Storer.
+func (s *Store) FindPage(ctx context.Context, after uuid.UUID, limit int) ([]domain.Book, error) {
+	return s.next.FindPage(ctx, after, limit)
+}
+
+// FindOne returns a book from the cache, or from the underlying Storer when
+// missing. Concurrent calls for a missing book share the same read, which is
+// not canceled with the context of the first caller but keeps its deadline.
+func (s *Store) FindOne(ctx context.Context, bookID uuid.UUID) (domain.Book, error) {
+	if e := s.get(bookID); e != nil {
+		s.hits.Add(1)
+
+		return e.book, e.err
+	}
+
+	s.misses.Add(1)
+
+	ch := s.group.DoChan(bookID.String(), func() (any, error) {
+		shared := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			shared, cancel = context.WithDeadline(shared, deadline)
+			defer cancel()
+		}
+
+		f := s.begin(bookID)
+		book, err := s.next.FindOne(shared, bookID)
+		s.end(bookID, f, book, err)
+
+		return book, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return domain.Book{}, fmt.Errorf("cache.findone: %w", ctx.Err())
+	case r := <-ch:
+		book, _ := r.Val.(domain.Book)
+
+		return book, r.Err
+	}
+}
+
+// FindMany returns the books matching the given IDs, from the cache or from
+// the underlying Storer for the missing ones, in the order of the IDs. IDs
+// without a matching book are ignored.
+func (s *Store) FindMany(ctx context.Context, bookIDs []uuid.UUID) ([]domain.Book, error) {
+	cached := make(map[uuid.UUID]*entry, len(bookIDs))
+	flights := make(map[uuid.UUID]*flight)
+
+	var missing []uuid.UUID
+
+	for _, bookID := range bookIDs {
+		if _, ok := cached[bookID]; ok || flights[bookID] != nil {
+			continue
+		}
+
+		if e := s.get(bookID); e != nil {
+			s.hits.Add(1)
+			cached[bookID] = e
+
+			continue
+		}
+
+		s.misses.Add(1)
+		flights[bookID] = s.begin(bookID)
+		missing = append(missing, bookID)
+	}
+
+	if len(missing) > 0 {
+		found, err := s.next.FindMany(ctx, missing)
+		if err != nil {
+			for _, bookID := range missing {
+				s.end(bookID, flights[bookID], domain.Book{}, err)
+			}
+
+			return nil, err
+		}
+
+		for _, book := range found {
+			cached[book.ID] = &entry{book: book}
+		}
+
+		for _, bookID := range missing {
+			if e, ok := cached[bookID]; ok {
+				s.end(bookID, flights[bookID], e.book, nil)
+
+				continue
+			}
+
+			// The error is returned by FindOne, when it finds the entry.
+			err := fmt.Errorf("cache.findone: %w", domain.ErrNotFound)
+			cached[bookID] = &entry{err: err}
+			s.end(bookID, flights[bookID], domain.Book{}, err)
+		}
+	}
+
+	books := make([]domain.Book, 0, len(bookIDs))
+	for _, bookID := range bookIDs {
+		if e := cached[bookID]; e.err == nil {
+			books = append(books, e.book)
+		}
+	}
+
+	return books, nil
+}
+
+// Update replaces an existing book and invalidates it.
+func (s *Store) Update(ctx context.Context, book domain.Book) error {
+	defer s.invalidate(book.ID)
+
+	return s.next.Update(ctx, book)
+}
+
+// CompareAndSwap replaces the previous books with books and invalidates them.
+func (s *Store) CompareAndSwap(ctx context.Context, previous, books []domain.Book) error {
+	bookIDs := make([]uuid.UUID, len(books))
+	for i, book := range books {
+		bookIDs[i] = book.ID
+	}
+
+	defer s.invalidate(bookIDs...)
+
+	return s.next.CompareAndSwap(ctx, previous, books)
+}
+
+// Delete removes a book and invalidates it.
+func (s *Store) Delete(ctx context.Context, bookID uuid.UUID) error {
+	defer s.invalidate(bookID)
+
+	return s.next.Delete(ctx, bookID)
+}
@@ -0,0 +1,355 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/domain/storertest"
+	"github.com/rotiroti/alessandrina/sys/database/cache"
+	"github.com/rotiroti/alessandrina/sys/database/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorer counts the reads of a memory.Store, and holds their results
+// until release is closed when set.
+type countingStorer struct {
+	*memory.Store
+	finds   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func newCountingStorer() *countingStorer {
+	return &countingStorer{Store: memory.NewStore()}
+}
+
+func (s *countingStorer) wait() {
+	if s.release != nil {
+		s.started <- struct{}{}
+		<-s.release
+	}
+}
+
+func (s *countingStorer) FindOne(ctx context.Context, bookID uuid.UUID) (domain.Book, error) {
+	s.finds.Add(1)
+	defer s.wait()
+
+	if s.err != nil {
+		return domain.Book{}, s.err
+	}
+
+	return s.Store.FindOne(ctx, bookID)
+}
+
+func (s *countingStorer) FindMany(ctx context.Context, bookIDs []uuid.UUID) ([]domain.Book, error) {
+	s.finds.Add(1)
+	defer s.wait()
+
+	return s.Store.FindMany(ctx, bookIDs)
+}
+
+func newBook(title string) domain.Book {
+	return domain.Book{ID: uuid.New(), Title: title, Authors: "Jon Bodner", Pages: 375}
+}
+
+func newStore(t *testing.T, next domain.Storer, opts ...cache.Option) *cache.Store {
+	t.Helper()
+
+	store, err := cache.NewStore(next, opts...)
+	require.NoError(t, err)
+
+	return store
+}
+
+func TestCacheStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("should read a book once", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		store := newStore(t, next)
+		book := newBook("Learning Go")
+		require.NoError(t, store.Save(ctx, book))
+
+		for range 3 {
+			ret, err := store.FindOne(ctx, book.ID)
+			require.NoError(t, err)
+			require.Equal(t, book, ret)
+		}
+
+		require.Equal(t, int32(1), next.finds.Load())
+		require.Equal(t, cache.Stats{Hits: 2, Misses: 1, Entries: 1}, store.Stats())
+	})
+
+	t.Run("should cache a book not found until it is saved", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		store := newStore(t, next)
+		book := newBook("Learning Go")
+
+		for range 2 {
+			_, err := store.FindOne(ctx, book.ID)
+			require.ErrorIs(t, err, domain.ErrNotFound)
+		}
+
+		require.Equal(t, int32(1), next.finds.Load())
+
+		require.NoError(t, store.Save(ctx, book))
+		ret, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+		require.Equal(t, book, ret)
+	})
+
+	t.Run("should not cache a book not found without a negative TTL", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		store := newStore(t, next, cache.WithNegativeTTL(0))
+
+		for range 2 {
+			_, err := store.FindOne(ctx, uuid.New())
+			require.ErrorIs(t, err, domain.ErrNotFound)
+		}
+
+		require.Equal(t, int32(2), next.finds.Load())
+		require.Zero(t, store.Stats().Entries)
+	})
+
+	t.Run("should not cache errors", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		next.err = domain.ErrUnavailable
+		store := newStore(t, next)
+		bookID := uuid.New()
+
+		_, err := store.FindOne(ctx, bookID)
+		require.ErrorIs(t, err, domain.ErrUnavailable)
+
+		next.err = nil
+		_, err = store.FindOne(ctx, bookID)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+		require.Equal(t, int32(2), next.finds.Load())
+	})
+
+	t.Run("should invalidate the updated and deleted books", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t, memory.NewStore())
+		book := newBook("Learning Go")
+		require.NoError(t, store.Save(ctx, book))
+		_, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+
+		book.Title = "Learning Go, Second Edition"
+		require.NoError(t, store.Update(ctx, book))
+		ret, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+		require.Equal(t, book, ret)
+
+		require.NoError(t, store.Delete(ctx, book.ID))
+		_, err = store.FindOne(ctx, book.ID)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("should read an expired book again", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		store := newStore(t, next, cache.WithTTL(20*time.Millisecond))
+		book := newBook("Learning Go")
+		require.NoError(t, next.Save(ctx, book))
+
+		_, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+		time.Sleep(30 * time.Millisecond)
+		_, err = store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+
+		require.Equal(t, int32(2), next.finds.Load())
+	})
+
+	t.Run("should evict the least recently used book", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		store := newStore(t, next, cache.WithSize(2))
+		a, b, c := newBook("A"), newBook("B"), newBook("C")
+		require.NoError(t, next.SaveMany(ctx, []domain.Book{a, b, c}))
+
+		for _, book := range []domain.Book{a, b, a, c, a} {
+			_, err := store.FindOne(ctx, book.ID)
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, cache.Stats{Hits: 2, Misses: 3, Evictions: 1, Entries: 2}, store.Stats())
+
+		_, err := store.FindOne(ctx, b.ID)
+		require.NoError(t, err)
+		require.Equal(t, int32(4), next.finds.Load(), "b was evicted")
+	})
+
+	t.Run("should share the read of concurrent misses", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		book := newBook("Learning Go")
+		require.NoError(t, next.Save(ctx, book))
+		next.started, next.release = make(chan struct{}), make(chan struct{})
+		store := newStore(t, next)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				ret, err := store.FindOne(ctx, book.ID)
+				if err != nil || ret.ID != book.ID {
+					t.Errorf("FindOne() = %v, %v", ret, err)
+				}
+			}()
+		}
+
+		<-next.started
+		require.Eventually(t, func() bool { return store.Stats().Misses == 10 }, time.Second, time.Millisecond)
+		close(next.release)
+		wg.Wait()
+
+		require.Equal(t, int32(1), next.finds.Load())
+	})
+
+	t.Run("should not cache a book written while it is read", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		book := newBook("Learning Go")
+		require.NoError(t, next.Save(ctx, book))
+		next.started, next.release = make(chan struct{}, 2), make(chan struct{})
+		store := newStore(t, next)
+
+		done := make(chan domain.Book)
+		go func() {
+			ret, _ := store.FindOne(ctx, book.ID)
+			done <- ret
+		}()
+
+		<-next.started
+		updated := book
+		updated.Title = "Learning Go, Second Edition"
+		require.NoError(t, store.Update(ctx, updated))
+		close(next.release)
+		require.Equal(t, book, <-done, "the read started before the update")
+
+		ret, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+		require.Equal(t, updated, ret)
+	})
+
+	t.Run("should not cache a book written while a FindOne and a FindMany read it", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		book := newBook("Learning Go")
+		require.NoError(t, next.Save(ctx, book))
+		next.started, next.release = make(chan struct{}, 2), make(chan struct{})
+		store := newStore(t, next)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			_, _ = store.FindOne(ctx, book.ID)
+		}()
+
+		<-next.started
+
+		go func() {
+			defer wg.Done()
+
+			_, _ = store.FindMany(ctx, []uuid.UUID{book.ID})
+		}()
+
+		<-next.started
+		updated := book
+		updated.Title = "Learning Go, Second Edition"
+		require.NoError(t, store.Update(ctx, updated))
+		close(next.release)
+		wg.Wait()
+
+		ret, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+		require.Equal(t, updated, ret)
+
+		books, err := store.FindMany(ctx, []uuid.UUID{book.ID})
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{updated}, books)
+	})
+
+	t.Run("should stop waiting for a shared read when the context is done", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		next.started, next.release = make(chan struct{}, 1), make(chan struct{})
+		store := newStore(t, next)
+		defer close(next.release)
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := store.FindOne(ctx, uuid.New())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("should read the missing books of a batch", func(t *testing.T) {
+		t.Parallel()
+		next := newCountingStorer()
+		store := newStore(t, next)
+		a, b := newBook("A"), newBook("B")
+		require.NoError(t, next.SaveMany(ctx, []domain.Book{a, b}))
+		_, err := store.FindOne(ctx, a.ID)
+		require.NoError(t, err)
+
+		missingID := uuid.New()
+		for range 2 {
+			books, err := store.FindMany(ctx, []uuid.UUID{b.ID, missingID, a.ID})
+			require.NoError(t, err)
+			require.Equal(t, []domain.Book{b, a}, books)
+		}
+
+		_, err = store.FindOne(ctx, missingID)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+
+		require.Equal(t, int32(2), next.finds.Load(), "a FindOne and a FindMany")
+		require.Equal(t, cache.Stats{Hits: 5, Misses: 3, Entries: 3}, store.Stats())
+	})
+
+	t.Run("should invalidate the books of a batch", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t, memory.NewStore())
+		book := newBook("Learning Go")
+		_, err := store.FindOne(ctx, book.ID)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+
+		require.NoError(t, store.SaveMany(ctx, []domain.Book{book}))
+		books, err := store.FindMany(ctx, []uuid.UUID{book.ID})
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{book}, books)
+	})
+
+	t.Run("should reject invalid options", func(t *testing.T) {
+		t.Parallel()
+		for _, opt := range []cache.Option{cache.WithSize(0), cache.WithTTL(0), cache.WithNegativeTTL(-time.Second)} {
+			_, err := cache.NewStore(memory.NewStore(), opt)
+			require.Error(t, err)
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	storertest.Run(t, func(t *testing.T) domain.Storer {
+		return newStore(t, memory.NewStore())
+	})
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// OptionsFromEnv returns the Store Options set by the environment variables
+// of the functions, read with lookup (e.g. os.LookupEnv), and whether the
+// cache is enabled:
+//
+//	CACHE_SIZE          number of books cached, the cache is disabled when
+//	                    unset or 0
+//	CACHE_TTL           how long a book is cached, e.g. 30s (default: 1m)
+//	CACHE_NEGATIVE_TTL  how long a book not found is cached, 0 disables it
+//	                    (default: 10s)
+func OptionsFromEnv(lookup func(key string) (string, bool)) ([]Option, bool, error) {
+	value, ok := lookup("CACHE_SIZE")
+	if !ok || value == "" {
+		return nil, false, nil
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, false, fmt.Errorf("cache.optionsfromenv: invalid CACHE_SIZE: %w", err)
+	}
+
+	if size == 0 {
+		return nil, false, nil
+	}
+
+	opts := []Option{WithSize(size)}
+
+	durations := []struct {
+		key    string
+		option func(time.Duration) Option
+	}{
+		{"CACHE_TTL", WithTTL},
+		{"CACHE_NEGATIVE_TTL", WithNegativeTTL},
+	}
+
+	for _, d := range durations {
+		value, ok := lookup(d.key)
+		if !ok || value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("cache.optionsfromenv: invalid %s: %w", d.key, err)
+		}
+
+		opts = append(opts, d.option(duration))
+	}
+
+	return opts, true, nil
+}
@@ -0,0 +1,55 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/rotiroti/alessandrina/sys/database/cache"
+	"github.com/rotiroti/alessandrina/sys/database/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) (string, bool) {
+		return func(key string) (string, bool) {
+			value, ok := vars[key]
+			return value, ok
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		for _, vars := range []map[string]string{nil, {"CACHE_SIZE": "0", "CACHE_TTL": "30s"}} {
+			opts, enabled, err := cache.OptionsFromEnv(env(vars))
+
+			require.NoError(t, err)
+			require.False(t, enabled)
+			require.Empty(t, opts)
+		}
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		opts, enabled, err := cache.OptionsFromEnv(env(map[string]string{
+			"CACHE_SIZE":         "500",
+			"CACHE_TTL":          "30s",
+			"CACHE_NEGATIVE_TTL": "0",
+		}))
+		require.NoError(t, err)
+		require.True(t, enabled)
+		require.Len(t, opts, 3)
+
+		_, err = cache.NewStore(memory.NewStore(), opts...)
+		require.NoError(t, err)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for key, value := range map[string]string{
+			"CACHE_TTL":          "soon",
+			"CACHE_NEGATIVE_TTL": "1",
+		} {
+			_, _, err := cache.OptionsFromEnv(env(map[string]string{"CACHE_SIZE": "10", key: value}))
+			require.Error(t, err, key)
+		}
+
+		_, _, err := cache.OptionsFromEnv(env(map[string]string{"CACHE_SIZE": "many"}))
+		require.Error(t, err)
+	})
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
@@ -17,11 +21,40 @@ import (
 // ErrMissingTableName is returned when the DB_TABLE environment variable is not set.
 var ErrMissingTableName = errors.New("missing DB_TABLE environment variable")
 
-// DefaultTableScanLimit is the default limit for the Scan operation.
-//
-// NOTE: This is a temporary solution to avoid scanning the entire table.
+// DefaultTableScanLimit is the number of items evaluated by each Scan
+// request of FindAll, which keeps scanning until the whole table is read.
 const DefaultTableScanLimit = 25
 
+// ByIDIndex is the global secondary index listing the entities of a kind
+// ordered by ID, its partition key is the kind attribute (e.g. BookKind) and
+// its sort key the id attribute.
+//
+// NOTE: a single partition serves the whole index, which is fine for the
+// size of a library catalog.
+const ByIDIndex = "kind-id-index"
+
+// MaxBatchWriteItems is the maximum number of items accepted by a single
+// BatchWriteItem request.
+const MaxBatchWriteItems = 25
+
+// MaxBatchGetItems is the maximum number of keys accepted by a single
+// BatchGetItem request.
+const MaxBatchGetItems = 100
+
+// MaxTransactWriteItems is the maximum number of actions accepted by a single
+// TransactWriteItems request.
+const MaxTransactWriteItems = 100
+
+const (
+	// batchMaxAttempts is the number of requests sent for a chunk before
+	// giving up on its unprocessed items.
+	batchMaxAttempts = 5
+
+	// batchBaseBackoff is the delay before the first retry of a chunk, it
+	// doubles on every following attempt.
+	batchBaseBackoff = 50 * time.Millisecond
+)
+
 // Option is a function that configures a Store.
 type Option func(*Store) error
 
@@ -34,46 +67,146 @@ func WithClient(client DynamoDBClient) Option {
 	}
 }
 
-// WithClientLog returns a Store Option that sets the DynamoDB client with logging enabled.
+// WithClientLog returns a Store Option that sets the DynamoDB client with
+// logging of the requests and responses enabled, bodies included.
 func WithClientLog() Option {
+	return WithLogMode(aws.LogRequestWithBody | aws.LogResponseWithBody)
+}
+
+// WithLogMode returns a Store Option that sets what the DynamoDB client logs.
+func WithLogMode(mode aws.ClientLogMode) Option {
 	return func(s *Store) error {
-		logMode := aws.LogRequestWithBody | aws.LogResponseWithBody
-		conf, err := config.LoadDefaultConfig(context.Background(), config.WithClientLogMode(logMode))
+		s.loadOptions = append(s.loadOptions, config.WithClientLogMode(mode))
 
-		if err != nil {
-			return fmt.Errorf("ddb.withclientlog loaddefaultconfig: %w", err)
+		return nil
+	}
+}
+
+// WithEndpoint returns a Store Option that sends the requests of the
+// DynamoDB client to the given URL instead of the AWS endpoint of the
+// region, e.g. DynamoDB Local (http://localhost:8000), LocalStack
+// (http://localhost:4566) or any compatible stand-in.
+func WithEndpoint(endpoint string) Option {
+	return func(s *Store) error {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("ddb.withendpoint: invalid endpoint %q", endpoint)
 		}
 
-		s.client = dynamodb.NewFromConfig(conf)
+		// The resolver of dynamodb.EndpointResolverFromURL sets the signing
+		// region of a shared endpoint on every request, a data race when
+		// the Store is used concurrently.
+		s.clientOptions = append(s.clientOptions, func(o *dynamodb.Options) {
+			o.EndpointResolver = dynamodb.EndpointResolverFunc(
+				func(region string, _ dynamodb.EndpointResolverOptions) (aws.Endpoint, error) {
+					return aws.Endpoint{
+						URL:               endpoint,
+						SigningRegion:     region,
+						HostnameImmutable: true,
+						Source:            aws.EndpointSourceCustom,
+					}, nil
+				},
+			)
+		})
 
 		return nil
 	}
 }
 
-// WithLocalStack returns a Store Option that sets the DynamoDB client with LocalStack.
-func WithLocalStack() Option {
+// WithRegion returns a Store Option that sets the region of the DynamoDB
+// client, in place of the one of the environment.
+func WithRegion(region string) Option {
 	return func(s *Store) error {
-		options := []func(*config.LoadOptions) error{}
-		resolver := aws.EndpointResolverWithOptionsFunc(
-			func(_, region string, options ...interface{}) (aws.Endpoint, error) {
-				return aws.Endpoint{
-					PartitionID:   "aws",
-					URL:           "http://localstack_main:4566",
-					SigningRegion: region,
-				}, nil
-			})
-		logMode := aws.LogRequestWithBody | aws.LogResponseWithBody
-		options = append(options,
-			config.WithEndpointResolverWithOptions(resolver),
-			config.WithClientLogMode(logMode),
-		)
-
-		conf, err := config.LoadDefaultConfig(context.Background(), options...)
-		if err != nil {
-			return fmt.Errorf("ddb.withlocalstack loaddefaultconfig: %w", err)
+		if region == "" {
+			return errors.New("ddb.withregion: empty region")
 		}
 
-		s.client = dynamodb.NewFromConfig(conf)
+		s.loadOptions = append(s.loadOptions, config.WithRegion(region))
+
+		return nil
+	}
+}
+
+// WithStaticCredentials returns a Store Option that signs the requests of the
+// DynamoDB client with the given credentials, in place of the ones of the
+// environment. Local stand-ins accept any non-empty credentials.
+func WithStaticCredentials(accessKeyID, secretAccessKey, sessionToken string) Option {
+	return func(s *Store) error {
+		if accessKeyID == "" || secretAccessKey == "" {
+			return errors.New("ddb.withstaticcredentials: empty access key")
+		}
+
+		provider := credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken)
+		s.loadOptions = append(s.loadOptions, config.WithCredentialsProvider(provider))
+
+		return nil
+	}
+}
+
+// WithAdaptiveRetry returns a Store Option that sets the retry mode of the
+// DynamoDB client to adaptive: besides retrying, the client slows down its
+// requests while DynamoDB is throttling them.
+func WithAdaptiveRetry() Option {
+	return func(s *Store) error {
+		s.adaptiveRetry = true
+
+		return nil
+	}
+}
+
+// WithMaxAttempts returns a Store Option that sets the maximum number of
+// attempts of a request of the DynamoDB client, the first one included.
+func WithMaxAttempts(n int) Option {
+	return func(s *Store) error {
+		if n < 1 {
+			return fmt.Errorf("ddb.withmaxattempts: invalid number of attempts %d", n)
+		}
+
+		s.maxAttempts = n
+
+		return nil
+	}
+}
+
+// WithMaxBackoff returns a Store Option that sets the maximum delay between
+// two attempts of a request of the DynamoDB client.
+func WithMaxBackoff(d time.Duration) Option {
+	return func(s *Store) error {
+		if d <= 0 {
+			return fmt.Errorf("ddb.withmaxbackoff: invalid delay %s", d)
+		}
+
+		s.maxBackoff = d
+
+		return nil
+	}
+}
+
+// WithOperationTimeout returns a Store Option that bounds every operation of
+// the Store, retries included, to the given duration.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(s *Store) error {
+		if d <= 0 {
+			return fmt.Errorf("ddb.withoperationtimeout: invalid timeout %s", d)
+		}
+
+		s.operationTimeout = d
+
+		return nil
+	}
+}
+
+// WithDeadlineMargin returns a Store Option that ends every operation of the
+// Store margin before the deadline of its context. In AWS Lambda the deadline
+// is the one of the invocation, so the handler is left the time to report
+// the failure instead of being stopped by the function timeout.
+func WithDeadlineMargin(margin time.Duration) Option {
+	return func(s *Store) error {
+		if margin <= 0 {
+			return fmt.Errorf("ddb.withdeadlinemargin: invalid margin %s", margin)
+		}
+
+		s.deadlineMargin = margin
 
 		return nil
 	}
@@ -82,15 +215,34 @@ func WithLocalStack() Option {
 // DynamoDBClient is the interface used to interact with AWS DynamoDB.
 type DynamoDBClient interface {
 	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
 	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
 	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
 	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
 }
 
 // Store is a DynamoDB implementation of the Storer interface.
+//
+// The Options configuring the DynamoDB client (endpoint, region, credentials,
+// logging and retries) only apply to the client created by NewStore, not to
+// one set by WithClient.
 type Store struct {
-	client DynamoDBClient
-	table  string
+	client      DynamoDBClient
+	table       string
+	quarantine  string
+	onMalformed MalformedHandler
+	writeBack   bool
+	legacy      string
+
+	loadOptions      []func(*config.LoadOptions) error
+	clientOptions    []func(*dynamodb.Options)
+	adaptiveRetry    bool
+	maxAttempts      int
+	maxBackoff       time.Duration
+	operationTimeout time.Duration
+	deadlineMargin   time.Duration
 }
 
 // Ensure Store implements the Storer interface.
@@ -102,7 +254,10 @@ func NewStore(ctx context.Context, table string, opts ...Option) (*Store, error)
 		return nil, fmt.Errorf("ddb.newstore: %w", ErrMissingTableName)
 	}
 
-	store := &Store{table: table}
+	store := &Store{
+		table:       table,
+		onMalformed: logMalformed,
+	}
 
 	for _, opt := range opts {
 		err := opt(store)
@@ -112,97 +267,552 @@ func NewStore(ctx context.Context, table string, opts ...Option) (*Store, error)
 	}
 
 	if store.client == nil {
-		cfg, err := config.LoadDefaultConfig(ctx)
+		loadOptions := store.loadOptions
+		if store.adaptiveRetry || store.maxAttempts > 0 || store.maxBackoff > 0 {
+			loadOptions = append(loadOptions, config.WithRetryer(store.newRetryer))
+		}
+
+		cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
 		if err != nil {
 			return nil, fmt.Errorf("ddb.newstore loaddefaultconfig: %w", err)
 		}
 
-		store.client = dynamodb.NewFromConfig(cfg)
+		store.client = dynamodb.NewFromConfig(cfg, store.clientOptions...)
 	}
 
 	return store, nil
 }
 
+// newRetryer returns the retryer of the DynamoDB client configured by the
+// retry Options.
+func (s *Store) newRetryer() aws.Retryer {
+	standard := func(o *retry.StandardOptions) {
+		if s.maxAttempts > 0 {
+			o.MaxAttempts = s.maxAttempts
+		}
+
+		if s.maxBackoff > 0 {
+			o.MaxBackoff = s.maxBackoff
+		}
+	}
+
+	if s.adaptiveRetry {
+		return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+			o.StandardOptions = append(o.StandardOptions, standard)
+		})
+	}
+
+	return retry.NewStandard(standard)
+}
+
+// operationContext returns the context of an operation of the Store, bounded
+// by the operation timeout and the deadline margin. The context is returned
+// as is when neither is set.
+func (s *Store) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	var (
+		deadline time.Time
+		bounded  bool
+	)
+
+	if d, ok := ctx.Deadline(); ok && s.deadlineMargin > 0 {
+		deadline, bounded = d.Add(-s.deadlineMargin), true
+	}
+
+	if s.operationTimeout > 0 {
+		if d := time.Now().Add(s.operationTimeout); !bounded || d.Before(deadline) {
+			deadline, bounded = d, true
+		}
+	}
+
+	if !bounded {
+		return ctx, func() {}
+	}
+
+	return context.WithDeadline(ctx, deadline)
+}
+
 // Save adds a new book into the DynamoDB database.
 func (s *Store) Save(ctx context.Context, book domain.Book) error {
-	item, err := attributevalue.MarshalMap(ToDynamodbBook(book))
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
+	item, err := MarshalItem(ToDynamodbBook(book))
 	if err != nil {
-		return fmt.Errorf("ddb.save marshalmap: %w", err)
+		return fmt.Errorf("ddb.save: %w", err)
 	}
 
 	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
-		Item:      item,
-		TableName: aws.String(s.table),
+		Item:                item,
+		TableName:           aws.String(s.table),
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
 	})
 
 	if err != nil {
-		return fmt.Errorf("ddb.save putitem: %w", err)
+		return fmt.Errorf("ddb.save putitem: %w", translateError(err, domain.ErrAlreadyExists))
+	}
+
+	return nil
+}
+
+// SaveMany adds a batch of new books into the DynamoDB database.
+//
+// Books are written in transactions of up to MaxTransactWriteItems, each book
+// on condition that its ID does not exist yet, and the transactions canceled
+// by throttling or conflicting requests are retried with exponential backoff.
+// Books whose ID already exists, or that could not be written, are reported
+// in a domain.BatchError.
+func (s *Store) SaveMany(ctx context.Context, books []domain.Book) error {
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
+	failed := make(map[uuid.UUID]error)
+
+	for start := 0; start < len(books); start += MaxTransactWriteItems {
+		end := min(start+MaxTransactWriteItems, len(books))
+		s.saveChunk(ctx, books[start:end], failed)
+	}
+
+	if len(failed) > 0 {
+		return &domain.BatchError{Failed: failed}
 	}
 
 	return nil
 }
 
-// FindAll returns all books from the DynamoDB database.
+// saveChunk writes up to MaxTransactWriteItems books in a transaction,
+// recording into failed the ones that could not be written.
+//
+// A transaction is all or nothing: when it is canceled because some books
+// already exist, they are recorded and the transaction is sent again at once
+// with the other books.
+func (s *Store) saveChunk(ctx context.Context, books []domain.Book, failed map[uuid.UUID]error) {
+	bookIDs := make([]uuid.UUID, 0, len(books))
+	actions := make([]types.TransactWriteItem, 0, len(books))
+
+	for _, book := range books {
+		item, err := MarshalItem(ToDynamodbBook(book))
+		if err != nil {
+			failed[book.ID] = fmt.Errorf("ddb.savemany: %w", err)
+			continue
+		}
+
+		bookIDs = append(bookIDs, book.ID)
+		actions = append(actions, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:           aws.String(s.table),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(pk)"),
+			},
+		})
+	}
+
+	var lastErr error
+
+	for attempt := 0; len(actions) > 0 && attempt < batchMaxAttempts; {
+		_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: actions,
+		})
+
+		if err == nil {
+			return
+		}
+
+		lastErr = translateError(err, nil)
+
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) == len(actions) {
+			kept := 0
+
+			for i, reason := range canceled.CancellationReasons {
+				if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+					failed[bookIDs[i]] = fmt.Errorf("ddb.savemany transactwriteitems: %w", domain.ErrAlreadyExists)
+					continue
+				}
+
+				bookIDs[kept], actions[kept] = bookIDs[i], actions[i]
+				kept++
+			}
+
+			if kept < len(actions) {
+				bookIDs, actions = bookIDs[:kept], actions[:kept]
+				continue
+			}
+
+			// Canceled by a throttled or conflicting request, e.g. another
+			// transaction on the same books.
+			lastErr = fmt.Errorf("%w: %w", domain.ErrThrottled, err)
+		}
+
+		if !errors.Is(lastErr, domain.ErrThrottled) {
+			break
+		}
+
+		attempt++
+
+		if attempt < batchMaxAttempts {
+			if err := sleep(ctx, batchBaseBackoff<<(attempt-1)); err != nil {
+				lastErr = err
+				break
+			}
+		}
+	}
+
+	for _, bookID := range bookIDs {
+		failed[bookID] = fmt.Errorf("ddb.savemany transactwriteitems: %w", lastErr)
+	}
+}
+
+// sleep waits for the given duration or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// FindAll returns all books from the DynamoDB database, scanning the whole
+// table one page at a time. The books are the items of BookKind, the ones
+// listed by ByIDIndex (see FindPage).
+//
+// Malformed items are skipped, see WithMalformedHandler and WithQuarantine.
 func (s *Store) FindAll(ctx context.Context) ([]domain.Book, error) {
-	response, err := s.client.Scan(ctx, &dynamodb.ScanInput{
-		TableName: aws.String(s.table),
-		Limit:     aws.Int32(DefaultTableScanLimit),
-	})
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(s.table),
+		Limit:            aws.Int32(DefaultTableScanLimit),
+		FilterExpression: aws.String("#kind = :kind"),
+		ExpressionAttributeNames: map[string]string{
+			"#kind": "kind",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":kind": &types.AttributeValueMemberS{Value: BookKind},
+		},
+	}
 
-	if err != nil {
-		return []domain.Book{}, fmt.Errorf("ddb.findall scan: %w", err)
+	books := []domain.Book{}
+
+	// The filter is applied after the Limit, so a page may hold fewer books
+	// than the items it evaluated, even none.
+	for {
+		response, err := s.client.Scan(ctx, input)
+		if err != nil {
+			return []domain.Book{}, fmt.Errorf("ddb.findall scan: %w", translateError(err, nil))
+		}
+
+		books = append(books, s.decodeItems(ctx, "findall", response.Items)...)
+
+		if len(response.LastEvaluatedKey) == 0 {
+			return books, nil
+		}
+
+		input.ExclusiveStartKey = response.LastEvaluatedKey
 	}
+}
 
-	items := make([]DynamodbBook, 0, len(response.Items))
+// FindPage returns up to limit books with an ID greater than after, ordered
+// by ID, querying the ByIDIndex.
+func (s *Store) FindPage(ctx context.Context, after uuid.UUID, limit int) ([]domain.Book, error) {
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		IndexName:              aws.String(ByIDIndex),
+		KeyConditionExpression: aws.String("#kind = :kind AND #id > :after"),
+		ExpressionAttributeNames: map[string]string{
+			"#kind": "kind",
+			"#id":   "id",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":kind":  &types.AttributeValueMemberS{Value: BookKind},
+			":after": &types.AttributeValueMemberS{Value: after.String()},
+		},
+	}
+
+	books := make([]domain.Book, 0, limit)
+
+	// A response is cut at 1 MB and malformed items are skipped, so more
+	// than one query may be needed.
+	for len(books) < limit {
+		input.Limit = aws.Int32(int32(limit - len(books)))
+
+		response, err := s.client.Query(ctx, input)
+		if err != nil {
+			return []domain.Book{}, fmt.Errorf("ddb.findpage query: %w", translateError(err, nil))
+		}
 
-	if err = attributevalue.UnmarshalListOfMaps(response.Items, &items); err != nil {
-		return []domain.Book{}, fmt.Errorf("ddb.findall unmarshallistofmaps: %w", err)
+		books = append(books, s.decodeItems(ctx, "findpage", response.Items)...)
+
+		if len(response.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = response.LastEvaluatedKey
 	}
 
-	books := ToDomainBooks(items)
+	return books, nil
+}
+
+// FindMany returns the books matching the given IDs from the DynamoDB database.
+// IDs without a matching book are ignored.
+//
+// Keys are requested in chunks of MaxBatchGetItems and unprocessed keys are
+// retried with exponential backoff; if some keys are still unprocessed after
+// the last attempt, the whole lookup fails.
+func (s *Store) FindMany(ctx context.Context, bookIDs []uuid.UUID) ([]domain.Book, error) {
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
+	books := make([]domain.Book, 0, len(bookIDs))
+
+	for start := 0; start < len(bookIDs); start += MaxBatchGetItems {
+		end := min(start+MaxBatchGetItems, len(bookIDs))
+
+		chunk, err := s.findChunk(ctx, bookIDs[start:end])
+		if err != nil {
+			return []domain.Book{}, err
+		}
+
+		books = append(books, chunk...)
+	}
 
 	return books, nil
 }
 
+// findChunk reads up to MaxBatchGetItems books.
+func (s *Store) findChunk(ctx context.Context, bookIDs []uuid.UUID) ([]domain.Book, error) {
+	items, err := s.batchGetItems(ctx, bookKeys(bookIDs))
+	if err != nil {
+		return nil, fmt.Errorf("ddb.findmany: %w", err)
+	}
+
+	return s.decodeItems(ctx, "findmany", items), nil
+}
+
+// bookKeys returns the request reading the books with the given IDs.
+func bookKeys(bookIDs []uuid.UUID) types.KeysAndAttributes {
+	keys := make([]map[string]types.AttributeValue, len(bookIDs))
+	for i, bookID := range bookIDs {
+		keys[i] = BookEntity.Key(bookID).AttributeValues()
+	}
+
+	return types.KeysAndAttributes{Keys: keys}
+}
+
+// batchGetItems reads the items of request, retrying the unprocessed keys
+// with exponential backoff; if some keys are still unprocessed after the
+// last attempt, the whole read fails.
+func (s *Store) batchGetItems(ctx context.Context, request types.KeysAndAttributes) ([]map[string]types.AttributeValue, error) {
+	requestItems := map[string]types.KeysAndAttributes{s.table: request}
+	items := make([]map[string]types.AttributeValue, 0, len(request.Keys))
+
+	for attempt := 0; len(requestItems[s.table].Keys) > 0; attempt++ {
+		if attempt == batchMaxAttempts {
+			return nil, fmt.Errorf("batchgetitem: %w", domain.ErrThrottled)
+		}
+
+		if attempt > 0 {
+			if err := sleep(ctx, batchBaseBackoff<<(attempt-1)); err != nil {
+				return nil, fmt.Errorf("batchgetitem: %w", err)
+			}
+		}
+
+		response, err := s.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: requestItems,
+		})
+
+		if err != nil {
+			err = translateError(err, nil)
+			if errors.Is(err, domain.ErrThrottled) {
+				continue
+			}
+
+			return nil, fmt.Errorf("batchgetitem: %w", err)
+		}
+
+		items = append(items, response.Responses[s.table]...)
+		requestItems = response.UnprocessedKeys
+	}
+
+	return items, nil
+}
+
 // FindOne returns a book from the DynamoDB database by using bookID as primary key.
 func (s *Store) FindOne(ctx context.Context, bookID uuid.UUID) (domain.Book, error) {
-	item := DynamodbBook{ID: bookID.String()}
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
 	response, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(s.table),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: item.ID},
-		},
+		Key:       BookEntity.Key(bookID).AttributeValues(),
 	})
 
 	if err != nil {
-		return domain.Book{}, fmt.Errorf("ddb.findone getitem: %w", err)
+		return domain.Book{}, fmt.Errorf("ddb.findone getitem: %w", translateError(err, nil))
 	}
 
 	if len(response.Item) == 0 {
+		if s.legacy != "" {
+			return s.findLegacy(ctx, bookID)
+		}
+
 		return domain.Book{}, fmt.Errorf("ddb.findone getitem: %w", domain.ErrNotFound)
 	}
 
-	if err = attributevalue.UnmarshalMap(response.Item, &item); err != nil {
-		return domain.Book{}, fmt.Errorf("ddb.findone unmarshalmap: %w", err)
-	}
+	book, err := s.readItem(ctx, "findone", response.Item)
+	if err != nil {
+		s.reportMalformed(ctx, "findone", newMalformedItem(response.Item, err))
 
-	book := ToDomainBook(item)
+		// The deploy that wrote the book is about to replace this one.
+		if errors.Is(err, ErrNewerSchema) {
+			return domain.Book{}, fmt.Errorf("ddb.findone: %w: %w", domain.ErrUnavailable, err)
+		}
+
+		return domain.Book{}, fmt.Errorf("ddb.findone: %w", err)
+	}
 
 	return book, nil
 }
 
+// Update replaces an existing book in the DynamoDB database.
+func (s *Store) Update(ctx context.Context, book domain.Book) error {
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
+	item, err := MarshalItem(ToDynamodbBook(book))
+	if err != nil {
+		return fmt.Errorf("ddb.update: %w", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		Item:                item,
+		TableName:           aws.String(s.table),
+		ConditionExpression: aws.String("attribute_exists(pk)"),
+	})
+
+	if err != nil {
+		return fmt.Errorf("ddb.update putitem: %w", translateError(err, domain.ErrNotFound))
+	}
+
+	return nil
+}
+
+// bookAttributes are the attributes holding the details of a book, compared
+// by CompareAndSwap.
+var bookAttributes = []string{"title", "authors", "publisher", "pages", "isbn", "tags", "merged_into"}
+
+// CompareAndSwap replaces the previous books with books in a single
+// transaction, each on condition that the stored item still holds the
+// details of the previous book.
+func (s *Store) CompareAndSwap(ctx context.Context, previous, books []domain.Book) error {
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
+	actions := make([]types.TransactWriteItem, len(books))
+
+	for i, book := range books {
+		item, err := MarshalItem(ToDynamodbBook(book))
+		if err != nil {
+			return fmt.Errorf("ddb.compareandswap: %w", err)
+		}
+
+		stored, err := MarshalItem(ToDynamodbBook(previous[i]))
+		if err != nil {
+			return fmt.Errorf("ddb.compareandswap: %w", err)
+		}
+
+		conditions := []string{"attribute_exists(pk)"}
+		names := make(map[string]string, len(bookAttributes))
+		values := make(map[string]types.AttributeValue, len(bookAttributes))
+
+		// Optional attributes, e.g. tags, are not stored when empty.
+		for j, attribute := range bookAttributes {
+			name, value := fmt.Sprintf("#a%d", j), fmt.Sprintf(":a%d", j)
+			names[name] = attribute
+
+			if v, ok := stored[attribute]; ok {
+				values[value] = v
+				conditions = append(conditions, name+" = "+value)
+			} else {
+				conditions = append(conditions, "attribute_not_exists("+name+")")
+			}
+		}
+
+		actions[i] = types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:                 aws.String(s.table),
+				Item:                      item,
+				ConditionExpression:       aws.String(strings.Join(conditions, " AND ")),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		}
+	}
+
+	_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: actions,
+	})
+
+	// The transaction is canceled when a book changed, or is changing in a
+	// conflicting request.
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		return fmt.Errorf("ddb.compareandswap transactwriteitems: %w: %w", domain.ErrPreconditionFailed, err)
+	}
+
+	if err != nil {
+		return fmt.Errorf("ddb.compareandswap transactwriteitems: %w", translateError(err, nil))
+	}
+
+	return nil
+}
+
 // Delete removes a book from the DynamoDB database by using bookID as primary key.
 func (s *Store) Delete(ctx context.Context, bookID uuid.UUID) error {
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
 	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName: aws.String(s.table),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: bookID.String()},
-		},
+		Key:       BookEntity.Key(bookID).AttributeValues(),
 	})
 
 	if err != nil {
-		return fmt.Errorf("ddb.delete deleteitem: %w", err)
+		return fmt.Errorf("ddb.delete deleteitem: %w", translateError(err, nil))
 	}
 
 	return nil
 }
+
+// FindByISBN returns the books with the given ISBN, in either the ISBN-10 or
+// the ISBN-13 form, querying GSI1.
+func (s *Store) FindByISBN(ctx context.Context, isbn string) ([]domain.Book, error) {
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+
+	input := BooksByISBN(isbn).Input(s.table)
+
+	var books []domain.Book
+
+	for {
+		response, err := s.client.Query(ctx, input)
+		if err != nil {
+			return []domain.Book{}, fmt.Errorf("ddb.findbyisbn query: %w", translateError(err, nil))
+		}
+
+		books = append(books, s.decodeItems(ctx, "findbyisbn", response.Items)...)
+
+		if len(response.LastEvaluatedKey) == 0 {
+			return books, nil
+		}
+
+		input.ExclusiveStartKey = response.LastEvaluatedKey
+	}
+}
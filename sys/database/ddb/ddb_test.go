@@ -2,17 +2,27 @@ package ddb_test
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
 	"github.com/google/uuid"
 	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/domain/storertest"
 	"github.com/rotiroti/alessandrina/sys/database/ddb"
+	"github.com/rotiroti/alessandrina/sys/database/ddb/ddbtest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -35,15 +45,17 @@ func TestNewStore(t *testing.T) {
 		os.Unsetenv("AWS_ENABLE_ENDPOINT_DISCOVERY")
 	})
 
-	t.Run("WithInvalidLocalStack", func(t *testing.T) {
-		os.Setenv("AWS_ENABLE_ENDPOINT_DISCOVERY", "foo-bar")
-
-		store, err := ddb.NewStore(ctx, "test-table", ddb.WithLocalStack())
-
-		require.Error(t, err)
-		require.Nil(t, store)
+	t.Run("WithInvalidEndpoint", func(t *testing.T) {
+		for _, opt := range []ddb.Option{
+			ddb.WithEndpoint("localhost"),
+			ddb.WithRegion(""),
+			ddb.WithStaticCredentials("", "secret", ""),
+		} {
+			store, err := ddb.NewStore(ctx, "test-table", opt)
 
-		os.Unsetenv("AWS_ENABLE_ENDPOINT_DISCOVERY")
+			require.Error(t, err)
+			require.Nil(t, store)
+		}
 	})
 
 	t.Run("WithInvalidClientLog", func(t *testing.T) {
@@ -79,14 +91,112 @@ func TestNewStore(t *testing.T) {
 		require.NotNil(t, store)
 	})
 
-	t.Run("WithLocalStack", func(t *testing.T) {
-		store, err := ddb.NewStore(ctx, "test-table", ddb.WithLocalStack())
+	t.Run("WithEndpoint", func(t *testing.T) {
+		var authorization string
+
+		// A stand-in answering every GetItem with no item.
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+			fmt.Fprint(w, "{}")
+		}))
+		defer server.Close()
+
+		store, err := ddb.NewStore(ctx, "test-table",
+			ddb.WithEndpoint(server.URL),
+			ddb.WithRegion("eu-south-1"),
+			ddb.WithStaticCredentials("local", "local", ""),
+			ddb.WithLogMode(aws.LogRetries),
+		)
+		require.NoError(t, err)
+		_, err = store.FindOne(ctx, uuid.New())
+
+		require.ErrorIs(t, err, domain.ErrNotFound)
+		require.Contains(t, authorization, "Credential=local/")
+		require.Contains(t, authorization, "/eu-south-1/dynamodb/")
+	})
+
+	t.Run("WithRetry", func(t *testing.T) {
+		store, err := ddb.NewStore(ctx, "test-table",
+			ddb.WithAdaptiveRetry(),
+			ddb.WithMaxAttempts(5),
+			ddb.WithMaxBackoff(time.Second),
+			ddb.WithOperationTimeout(2*time.Second),
+			ddb.WithDeadlineMargin(250*time.Millisecond),
+		)
 
 		require.NoError(t, err)
 		require.NotNil(t, store)
 	})
+
+	t.Run("WithInvalidRetry", func(t *testing.T) {
+		for _, opt := range []ddb.Option{
+			ddb.WithMaxAttempts(0),
+			ddb.WithMaxBackoff(0),
+			ddb.WithOperationTimeout(-time.Second),
+			ddb.WithDeadlineMargin(0),
+		} {
+			store, err := ddb.NewStore(ctx, "test-table", opt)
+
+			require.Error(t, err)
+			require.Nil(t, store)
+		}
+	})
+}
+
+func TestRetry(t *testing.T) {
+	bookID := uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812")
+
+	t.Run("DeadlineMargin", func(t *testing.T) {
+		deadline := time.Now().Add(3 * time.Second)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().GetItem(mock.MatchedBy(func(ctx context.Context) bool {
+			d, ok := ctx.Deadline()
+			return ok && d.Equal(deadline.Add(-500*time.Millisecond))
+		}), mock.Anything).Return(nil, &types.ResourceNotFoundException{}).Once()
+		store, err := ddb.NewStore(ctx, "test-table", ddb.WithClient(mockClient), ddb.WithDeadlineMargin(500*time.Millisecond))
+		require.NoError(t, err)
+		_, err = store.FindOne(ctx, bookID)
+		require.ErrorIs(t, err, domain.ErrUnavailable)
+	})
+
+	t.Run("OperationTimeout", func(t *testing.T) {
+		ctx := context.Background()
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().DeleteItem(mock.MatchedBy(func(ctx context.Context) bool {
+			d, ok := ctx.Deadline()
+			return ok && time.Until(d) <= time.Second
+		}), mock.Anything).Return(nil, context.DeadlineExceeded).Once()
+		store, err := ddb.NewStore(ctx, "test-table", ddb.WithClient(mockClient), ddb.WithOperationTimeout(time.Second))
+		require.NoError(t, err)
+		err = store.Delete(ctx, bookID)
+		require.ErrorIs(t, err, domain.ErrUnavailable)
+	})
+
+	t.Run("Throttled", func(t *testing.T) {
+		ctx := context.Background()
+		for _, apiErr := range []error{
+			&smithy.GenericAPIError{Code: "ThrottlingException"},
+			&retry.MaxAttemptsError{Attempt: 3, Err: &types.ProvisionedThroughputExceededException{}},
+			ratelimit.QuotaExceededError{},
+		} {
+			mockClient := ddb.NewMockDynamoDBClient(t)
+			mockClient.EXPECT().GetItem(ctx, mock.Anything).Return(nil, apiErr).Once()
+			store, err := ddb.NewStore(ctx, "test-table", ddb.WithClient(mockClient))
+			require.NoError(t, err)
+			_, err = store.FindOne(ctx, bookID)
+			require.ErrorIs(t, err, domain.ErrThrottled)
+		}
+	})
 }
 
+// batchBooks is the number of books saved in the SaveMany test, enough to
+// need two transactions.
+const batchBooks = 130
+
 func TestStore(t *testing.T) {
 	ctx := context.Background()
 	expectedTable := "test-table"
@@ -101,11 +211,10 @@ func TestStore(t *testing.T) {
 		ISBN:      "978-0-261-10235-4",
 	}
 	expectedPutItemInput := &dynamodb.PutItemInput{
-		TableName: aws.String(expectedTable),
-	}
-	expectedKey := map[string]types.AttributeValue{
-		"id": &types.AttributeValueMemberS{Value: expectedBookID.String()},
+		TableName:           aws.String(expectedTable),
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
 	}
+	expectedKey := ddb.BookEntity.Key(expectedBookID).AttributeValues()
 	expectedGetItemInput := &dynamodb.GetItemInput{
 		Key:       expectedKey,
 		TableName: aws.String(expectedTable),
@@ -115,12 +224,17 @@ func TestStore(t *testing.T) {
 		TableName: aws.String(expectedTable),
 	}
 	expectedScanInput := dynamodb.ScanInput{
-		TableName: aws.String(expectedTable),
-		Limit:     aws.Int32(ddb.DefaultTableScanLimit),
+		TableName:                aws.String(expectedTable),
+		Limit:                    aws.Int32(ddb.DefaultTableScanLimit),
+		FilterExpression:         aws.String("#kind = :kind"),
+		ExpressionAttributeNames: map[string]string{"#kind": "kind"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":kind": &types.AttributeValueMemberS{Value: ddb.BookKind},
+		},
 	}
 
 	t.Run("Save", func(t *testing.T) {
-		saveBookItem, err := attributevalue.MarshalMap(ddb.ToDynamodbBook(expectedBook))
+		saveBookItem, err := ddb.MarshalItem(ddb.ToDynamodbBook(expectedBook))
 		require.NoError(t, err)
 		expectedPutItemInput.Item = saveBookItem
 		mockClient.EXPECT().PutItem(ctx, expectedPutItemInput).Return(nil, nil).Once()
@@ -132,7 +246,7 @@ func TestStore(t *testing.T) {
 	})
 
 	t.Run("SaveFail", func(t *testing.T) {
-		saveBookItem, err := attributevalue.MarshalMap(ddb.ToDynamodbBook(expectedBook))
+		saveBookItem, err := ddb.MarshalItem(ddb.ToDynamodbBook(expectedBook))
 		require.NoError(t, err)
 		expectedPutItemInput.Item = saveBookItem
 		mockClient.EXPECT().PutItem(ctx, expectedPutItemInput).Return(&dynamodb.PutItemOutput{}, assert.AnError).Once()
@@ -143,6 +257,91 @@ func TestStore(t *testing.T) {
 		mockClient.AssertExpectations(t)
 	})
 
+	t.Run("SaveAlreadyExists", func(t *testing.T) {
+		saveBookItem, err := ddb.MarshalItem(ddb.ToDynamodbBook(expectedBook))
+		require.NoError(t, err)
+		expectedPutItemInput.Item = saveBookItem
+		mockClient.EXPECT().PutItem(ctx, expectedPutItemInput).Return(nil, &types.ConditionalCheckFailedException{}).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		err = store.Save(ctx, expectedBook)
+		require.ErrorIs(t, err, domain.ErrAlreadyExists)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("SaveMany", func(t *testing.T) {
+		books := make([]domain.Book, batchBooks)
+		for i := range books {
+			books[i] = expectedBook
+			books[i].ID = uuid.New()
+		}
+
+		chunkOf := func(size int) any {
+			return mock.MatchedBy(func(in *dynamodb.TransactWriteItemsInput) bool {
+				for _, action := range in.TransactItems {
+					if aws.ToString(action.Put.TableName) != expectedTable ||
+						aws.ToString(action.Put.ConditionExpression) != "attribute_not_exists(pk)" {
+						return false
+					}
+				}
+
+				return len(in.TransactItems) == size
+			})
+		}
+		canceled := &types.TransactionCanceledException{CancellationReasons: make([]types.CancellationReason, ddb.MaxTransactWriteItems)}
+		for i := range canceled.CancellationReasons {
+			canceled.CancellationReasons[i].Code = aws.String("None")
+		}
+		canceled.CancellationReasons[0].Code = aws.String("TransactionConflict")
+
+		// The first chunk conflicts with another transaction and is sent again.
+		mockClient.EXPECT().TransactWriteItems(ctx, chunkOf(ddb.MaxTransactWriteItems)).Return(nil, canceled).Once()
+		mockClient.EXPECT().TransactWriteItems(ctx, chunkOf(ddb.MaxTransactWriteItems)).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
+		mockClient.EXPECT().TransactWriteItems(ctx, chunkOf(batchBooks-ddb.MaxTransactWriteItems)).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		err = store.SaveMany(ctx, books)
+		require.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("SaveManyThrottled", func(t *testing.T) {
+		mockClient.EXPECT().TransactWriteItems(ctx, mock.Anything).Return(nil, &types.ProvisionedThroughputExceededException{}).Times(5)
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		err = store.SaveMany(ctx, []domain.Book{expectedBook})
+
+		var batchErr *domain.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		require.ErrorIs(t, batchErr.Failed[expectedBookID], domain.ErrThrottled)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("SaveManyAlreadyExists", func(t *testing.T) {
+		newBook := expectedBook
+		newBook.ID = uuid.New()
+		mockClient.EXPECT().TransactWriteItems(ctx, mock.MatchedBy(func(in *dynamodb.TransactWriteItemsInput) bool {
+			return len(in.TransactItems) == 2
+		})).Return(nil, &types.TransactionCanceledException{
+			CancellationReasons: []types.CancellationReason{
+				{Code: aws.String("None")},
+				{Code: aws.String("ConditionalCheckFailed")},
+			},
+		}).Once()
+		mockClient.EXPECT().TransactWriteItems(ctx, mock.MatchedBy(func(in *dynamodb.TransactWriteItemsInput) bool {
+			return len(in.TransactItems) == 1 && in.TransactItems[0].Put.Item["id"].(*types.AttributeValueMemberS).Value == newBook.ID.String()
+		})).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		err = store.SaveMany(ctx, []domain.Book{newBook, expectedBook})
+
+		var batchErr *domain.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		require.Len(t, batchErr.Failed, 1)
+		require.ErrorIs(t, batchErr.Failed[expectedBookID], domain.ErrAlreadyExists)
+		mockClient.AssertExpectations(t)
+	})
+
 	t.Run("FindAll", func(t *testing.T) {
 		expectedScanOutput := &dynamodb.ScanOutput{
 			Items: []map[string]types.AttributeValue{
@@ -165,6 +364,72 @@ func TestStore(t *testing.T) {
 		mockClient.AssertExpectations(t)
 	})
 
+	t.Run("FindAllPages", func(t *testing.T) {
+		lastKey := ddb.BookEntity.Key(expectedBookID).AttributeValues()
+		bookID := uuid.New()
+
+		// Every item evaluated by the first request is filtered out.
+		mockClient.EXPECT().Scan(ctx, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+			return in.ExclusiveStartKey == nil
+		})).Return(&dynamodb.ScanOutput{LastEvaluatedKey: lastKey}, nil).Once()
+		mockClient.EXPECT().Scan(ctx, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+			return in.ExclusiveStartKey != nil
+		})).Return(&dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{
+				{
+					"id":   &types.AttributeValueMemberS{Value: bookID.String()},
+					"kind": &types.AttributeValueMemberS{Value: ddb.BookKind},
+				},
+			},
+		}, nil).Once()
+
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{{ID: bookID}}, books)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("FindPage", func(t *testing.T) {
+		item := func(id string) map[string]types.AttributeValue {
+			return map[string]types.AttributeValue{
+				"id":   &types.AttributeValueMemberS{Value: id},
+				"kind": &types.AttributeValueMemberS{Value: ddb.BookKind},
+			}
+		}
+		firstID, secondID := uuid.New(), uuid.New()
+
+		// The first response is cut before reaching the limit.
+		mockClient.EXPECT().Query(ctx, mock.MatchedBy(func(in *dynamodb.QueryInput) bool {
+			return *in.IndexName == ddb.ByIDIndex && *in.Limit == 2 && in.ExclusiveStartKey == nil
+		})).Return(&dynamodb.QueryOutput{
+			Items:            []map[string]types.AttributeValue{item(firstID.String())},
+			LastEvaluatedKey: item(firstID.String()),
+		}, nil).Once()
+		mockClient.EXPECT().Query(ctx, mock.MatchedBy(func(in *dynamodb.QueryInput) bool {
+			return *in.Limit == 1 && in.ExclusiveStartKey != nil
+		})).Return(&dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{item(secondID.String())},
+		}, nil).Once()
+
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		books, err := store.FindPage(ctx, uuid.Nil, 2)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{{ID: firstID}, {ID: secondID}}, books)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("FindPageFail", func(t *testing.T) {
+		mockClient.EXPECT().Query(ctx, mock.Anything).Return(nil, &types.ProvisionedThroughputExceededException{}).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		_, err = store.FindPage(ctx, expectedBookID, 10)
+		require.ErrorIs(t, err, domain.ErrThrottled)
+		mockClient.AssertExpectations(t)
+	})
+
 	t.Run("FindAllFail", func(t *testing.T) {
 		mockClient.EXPECT().Scan(ctx, &expectedScanInput).Return(&dynamodb.ScanOutput{}, assert.AnError).Once()
 		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
@@ -174,8 +439,58 @@ func TestStore(t *testing.T) {
 		mockClient.AssertExpectations(t)
 	})
 
+	t.Run("FindAllThrottled", func(t *testing.T) {
+		mockClient.EXPECT().Scan(ctx, &expectedScanInput).Return(nil, &types.ProvisionedThroughputExceededException{}).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		_, err = store.FindAll(ctx)
+		require.ErrorIs(t, err, domain.ErrThrottled)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("FindMany", func(t *testing.T) {
+		missingID := uuid.New()
+		missingKey := ddb.BookEntity.Key(missingID).AttributeValues()
+		item, err := ddb.MarshalItem(ddb.ToDynamodbBook(expectedBook))
+		require.NoError(t, err)
+
+		mockClient.EXPECT().BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{
+				expectedTable: {Keys: []map[string]types.AttributeValue{expectedKey, missingKey}},
+			},
+		}).Return(&dynamodb.BatchGetItemOutput{
+			UnprocessedKeys: map[string]types.KeysAndAttributes{
+				expectedTable: {Keys: []map[string]types.AttributeValue{expectedKey}},
+			},
+		}, nil).Once()
+		mockClient.EXPECT().BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{
+				expectedTable: {Keys: []map[string]types.AttributeValue{expectedKey}},
+			},
+		}).Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]types.AttributeValue{
+				expectedTable: {item},
+			},
+		}, nil).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		books, err := store.FindMany(ctx, []uuid.UUID{expectedBookID, missingID})
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{expectedBook}, books)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("FindManyFail", func(t *testing.T) {
+		mockClient.EXPECT().BatchGetItem(ctx, mock.Anything).Return(nil, assert.AnError).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		_, err = store.FindMany(ctx, []uuid.UUID{expectedBookID})
+		require.Error(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
 	t.Run("FindOne", func(t *testing.T) {
-		getItemOutput, err := attributevalue.MarshalMap(ddb.ToDynamodbBook(expectedBook))
+		getItemOutput, err := ddb.MarshalItem(ddb.ToDynamodbBook(expectedBook))
 		require.NoError(t, err)
 
 		mockClient.EXPECT().GetItem(ctx, expectedGetItemInput).Return(
@@ -201,6 +516,15 @@ func TestStore(t *testing.T) {
 		mockClient.AssertExpectations(t)
 	})
 
+	t.Run("FindOneTableNotFound", func(t *testing.T) {
+		mockClient.EXPECT().GetItem(ctx, expectedGetItemInput).Return(nil, &types.ResourceNotFoundException{}).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		_, err = store.FindOne(ctx, expectedBookID)
+		require.ErrorIs(t, err, domain.ErrUnavailable)
+		mockClient.AssertExpectations(t)
+	})
+
 	t.Run("FindOneItemNotFound", func(t *testing.T) {
 		mockClient.EXPECT().GetItem(ctx, expectedGetItemInput).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{}}, nil).Once()
 		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
@@ -210,6 +534,55 @@ func TestStore(t *testing.T) {
 		mockClient.AssertExpectations(t)
 	})
 
+	t.Run("FindOneLegacy", func(t *testing.T) {
+		// A book written to the legacy table by the previous deploy.
+		legacyKey := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: expectedBookID.String()}}
+		legacyItem := map[string]types.AttributeValue{
+			"id":    legacyKey["id"],
+			"title": &types.AttributeValueMemberS{Value: expectedBook.Title},
+		}
+		expectedLegacyInput := &dynamodb.GetItemInput{Key: legacyKey, TableName: aws.String("legacy-table")}
+
+		mockClient.EXPECT().GetItem(ctx, expectedGetItemInput).Return(&dynamodb.GetItemOutput{}, nil).Twice()
+		mockClient.EXPECT().GetItem(ctx, expectedLegacyInput).Return(&dynamodb.GetItemOutput{Item: legacyItem}, nil).Once()
+		mockClient.EXPECT().GetItem(ctx, expectedLegacyInput).Return(&dynamodb.GetItemOutput{}, nil).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient), ddb.WithLegacyTable("legacy-table"))
+		require.NoError(t, err)
+
+		foundBook, err := store.FindOne(ctx, expectedBookID)
+		require.NoError(t, err)
+		assert.Equal(t, expectedBook.Title, foundBook.Title)
+
+		_, err = store.FindOne(ctx, expectedBookID)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		item, err := ddb.MarshalItem(ddb.ToDynamodbBook(expectedBook))
+		require.NoError(t, err)
+		expectedUpdateInput := &dynamodb.PutItemInput{
+			Item:                item,
+			TableName:           aws.String(expectedTable),
+			ConditionExpression: aws.String("attribute_exists(pk)"),
+		}
+		mockClient.EXPECT().PutItem(ctx, expectedUpdateInput).Return(nil, nil).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		err = store.Update(ctx, expectedBook)
+		require.NoError(t, err)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("UpdateNotFound", func(t *testing.T) {
+		mockClient.EXPECT().PutItem(ctx, mock.Anything).Return(nil, &types.ConditionalCheckFailedException{}).Once()
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		err = store.Update(ctx, expectedBook)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+		mockClient.AssertExpectations(t)
+	})
+
 	t.Run("Delete", func(t *testing.T) {
 		mockClient.EXPECT().DeleteItem(ctx, expectedDeleteInput).Return(nil, nil).Once()
 		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
@@ -227,3 +600,443 @@ func TestStore(t *testing.T) {
 		mockClient.AssertExpectations(t)
 	})
 }
+
+func TestMalformedItems(t *testing.T) {
+	ctx := context.Background()
+	expectedTable := "test-table"
+	quarantineTable := "test-quarantine"
+	goodID := uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812")
+	good := map[string]types.AttributeValue{
+		"id":    &types.AttributeValueMemberS{Value: goodID.String()},
+		"title": &types.AttributeValueMemberS{Value: "The Lord of the Rings"},
+	}
+	badID := map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "BOOK#not-a-uuid"},
+		"sk": &types.AttributeValueMemberS{Value: "BOOK#not-a-uuid"},
+		"id": &types.AttributeValueMemberS{Value: "not-a-uuid"},
+	}
+	upperID := map[string]types.AttributeValue{
+		"pk":          &types.AttributeValueMemberS{Value: "BOOK#upper"},
+		"sk":          &types.AttributeValueMemberS{Value: "BOOK#upper"},
+		"id":          &types.AttributeValueMemberS{Value: " AD8B59C2-5FE6-4267-B0CF-6D2F9EB1C812"},
+		"merged_into": &types.AttributeValueMemberS{Value: "nowhere"},
+	}
+	badPages := map[string]types.AttributeValue{
+		"id":    &types.AttributeValueMemberS{Value: uuid.NewString()},
+		"pages": &types.AttributeValueMemberS{Value: "many"},
+	}
+
+	newStore := func(t *testing.T, client ddb.DynamoDBClient, opts ...ddb.Option) (*ddb.Store, *[]ddb.MalformedItem) {
+		var reported []ddb.MalformedItem
+
+		opts = append(opts, ddb.WithClient(client), ddb.WithMalformedHandler(func(_ context.Context, _ string, item ddb.MalformedItem) {
+			reported = append(reported, item)
+		}))
+		store, err := ddb.NewStore(ctx, expectedTable, opts...)
+		require.NoError(t, err)
+
+		return store, &reported
+	}
+
+	t.Run("ToDomainBook", func(t *testing.T) {
+		_, err := ddb.ToDomainBook(ddb.DynamodbBook{ID: "not-a-uuid"})
+		require.ErrorIs(t, err, ddb.ErrMalformedItem)
+
+		_, err = ddb.ToDomainBook(ddb.DynamodbBook{ID: strings.ToUpper(goodID.String())})
+		require.ErrorIs(t, err, ddb.ErrMalformedItem)
+
+		_, err = ddb.ToDomainBook(ddb.DynamodbBook{ID: goodID.String(), MergedInto: "nowhere"})
+		require.ErrorIs(t, err, ddb.ErrMalformedItem)
+
+		book, err := ddb.ToDomainBook(ddb.DynamodbBook{ID: goodID.String()})
+		require.NoError(t, err)
+		require.Equal(t, goodID, book.ID)
+	})
+
+	t.Run("FindAllSkips", func(t *testing.T) {
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().Scan(ctx, mock.Anything).Return(&dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{badID, good, badPages},
+		}, nil).Once()
+
+		store, reported := newStore(t, mockClient)
+		books, err := store.FindAll(ctx)
+
+		require.NoError(t, err)
+		require.Len(t, books, 1)
+		require.Equal(t, goodID, books[0].ID)
+		require.Len(t, *reported, 2)
+		require.Equal(t, "not-a-uuid", (*reported)[0].ID)
+		require.ErrorIs(t, (*reported)[1].Err, ddb.ErrMalformedItem)
+	})
+
+	t.Run("FindAllQuarantines", func(t *testing.T) {
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().Scan(ctx, mock.Anything).Return(&dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{badID, good},
+		}, nil).Once()
+		mockClient.EXPECT().PutItem(ctx, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+			_, ok := in.Item["quarantine_reason"]
+
+			return *in.TableName == quarantineTable && ok
+		})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+		mockClient.EXPECT().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(expectedTable),
+			Key:       map[string]types.AttributeValue{"pk": badID["pk"], "sk": badID["sk"]},
+		}).Return(&dynamodb.DeleteItemOutput{}, nil).Once()
+
+		store, _ := newStore(t, mockClient, ddb.WithQuarantine(quarantineTable))
+		books, err := store.FindAll(ctx)
+
+		require.NoError(t, err)
+		require.Len(t, books, 1)
+	})
+
+	t.Run("FindOneMalformed", func(t *testing.T) {
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().GetItem(ctx, mock.Anything).Return(&dynamodb.GetItemOutput{Item: badPages}, nil).Once()
+
+		store, reported := newStore(t, mockClient)
+		_, err := store.FindOne(ctx, uuid.New())
+
+		require.ErrorIs(t, err, ddb.ErrMalformedItem)
+		require.Len(t, *reported, 1)
+	})
+
+	t.Run("FindMalformed", func(t *testing.T) {
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().Scan(ctx, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+			return in.ExclusiveStartKey == nil
+		})).Return(&dynamodb.ScanOutput{
+			Items:            []map[string]types.AttributeValue{good, badID},
+			LastEvaluatedKey: badID,
+		}, nil).Once()
+		mockClient.EXPECT().Scan(ctx, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+			return in.ExclusiveStartKey != nil
+		})).Return(&dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{upperID},
+		}, nil).Once()
+
+		store, _ := newStore(t, mockClient)
+		malformed, err := store.FindMalformed(ctx)
+
+		require.NoError(t, err)
+		require.Len(t, malformed, 2)
+		require.Equal(t, "not-a-uuid", malformed[0].ID)
+		require.Equal(t, upperID["id"].(*types.AttributeValueMemberS).Value, malformed[1].ID)
+	})
+
+	t.Run("RepairFixed", func(t *testing.T) {
+		mockClient := ddb.NewMockDynamoDBClient(t)
+
+		// The item is moved under the canonical ID, without the broken merged_into.
+		mockClient.EXPECT().PutItem(ctx, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+			_, merged := in.Item["merged_into"]
+
+			return *in.ConditionExpression == "attribute_not_exists(pk)" &&
+				in.Item["pk"].(*types.AttributeValueMemberS).Value == "BOOK#"+goodID.String() && !merged
+		})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+		mockClient.EXPECT().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(expectedTable),
+			Key:       map[string]types.AttributeValue{"pk": upperID["pk"], "sk": upperID["sk"]},
+		}).Return(&dynamodb.DeleteItemOutput{}, nil).Once()
+
+		store, _ := newStore(t, mockClient)
+		action, err := store.Repair(ctx, ddb.MalformedItem{
+			ID:   upperID["id"].(*types.AttributeValueMemberS).Value,
+			Item: upperID,
+			Err:  ddb.ErrMalformedItem,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, ddb.RepairFixed, action)
+	})
+
+	t.Run("RepairSkipped", func(t *testing.T) {
+		store, _ := newStore(t, ddb.NewMockDynamoDBClient(t))
+		action, err := store.Repair(ctx, ddb.MalformedItem{ID: "not-a-uuid", Item: badID, Err: ddb.ErrMalformedItem})
+
+		require.NoError(t, err)
+		require.Equal(t, ddb.RepairSkipped, action)
+	})
+
+	t.Run("RepairQuarantined", func(t *testing.T) {
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().PutItem(ctx, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+			return *in.TableName == quarantineTable
+		})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+		mockClient.EXPECT().DeleteItem(ctx, mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil).Once()
+
+		store, _ := newStore(t, mockClient, ddb.WithQuarantine(quarantineTable))
+		action, err := store.Repair(ctx, ddb.MalformedItem{ID: "not-a-uuid", Item: badID, Err: ddb.ErrMalformedItem})
+
+		require.NoError(t, err)
+		require.Equal(t, ddb.RepairQuarantined, action)
+	})
+}
+
+func TestSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	expectedTable := "test-table"
+	legacyTable := "legacy-table"
+
+	// legacyItem returns a book as stored in the legacy table, keyed by id
+	// only: version 0 items were written without kind and schema_version,
+	// version 1 ones added both.
+	legacyItem := func(id string, version int) map[string]types.AttributeValue {
+		item := map[string]types.AttributeValue{
+			"id":        &types.AttributeValueMemberS{Value: id},
+			"title":     &types.AttributeValueMemberS{Value: "The Hobbit"},
+			"authors":   &types.AttributeValueMemberS{Value: "J.R.R. Tolkien"},
+			"publisher": &types.AttributeValueMemberS{Value: "George Allen & Unwin"},
+			"pages":     &types.AttributeValueMemberN{Value: "310"},
+			"isbn":      &types.AttributeValueMemberS{Value: "978-0-261-10221-7"},
+		}
+
+		if version > 0 {
+			item["kind"] = &types.AttributeValueMemberS{Value: ddb.BookKind}
+			item["schema_version"] = &types.AttributeValueMemberN{Value: fmt.Sprint(version)}
+		}
+
+		return item
+	}
+
+	// currentItem returns a book as written by the Store.
+	currentItem := func(id string) map[string]types.AttributeValue {
+		item, err := ddb.MarshalItem(ddb.ToDynamodbBook(domain.Book{ID: uuid.MustParse(id), Title: "The Hobbit"}))
+		require.NoError(t, err)
+
+		return item
+	}
+
+	isCopied := func(in *dynamodb.PutItemInput, id string) bool {
+		version, ok := in.Item["schema_version"].(*types.AttributeValueMemberN)
+		pk, _ := in.Item["pk"].(*types.AttributeValueMemberS)
+		gsi1pk, _ := in.Item["gsi1pk"].(*types.AttributeValueMemberS)
+
+		return *in.TableName == expectedTable && *in.ConditionExpression == "attribute_not_exists(pk)" &&
+			ok && version.Value == fmt.Sprint(ddb.SchemaVersion) && in.Item["kind"] != nil &&
+			pk != nil && pk.Value == "BOOK#"+id && gsi1pk != nil && gsi1pk.Value == "ISBN#9780261102217"
+	}
+
+	legacyGet := func(id string) *dynamodb.GetItemInput {
+		return &dynamodb.GetItemInput{
+			TableName: aws.String(legacyTable),
+			Key:       map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+		}
+	}
+
+	t.Run("ToDynamodbBook", func(t *testing.T) {
+		require.Equal(t, ddb.SchemaVersion, ddb.ToDynamodbBook(domain.Book{ID: uuid.New()}).SchemaVersion)
+	})
+
+	t.Run("UpgradeOnRead", func(t *testing.T) {
+		for version := 0; version < ddb.SchemaVersion; version++ {
+			bookID := uuid.New()
+			mockClient := ddb.NewMockDynamoDBClient(t)
+			mockClient.EXPECT().GetItem(ctx, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+				return *in.TableName == expectedTable
+			})).Return(&dynamodb.GetItemOutput{}, nil).Once()
+			mockClient.EXPECT().GetItem(ctx, legacyGet(bookID.String())).Return(
+				&dynamodb.GetItemOutput{Item: legacyItem(bookID.String(), version)}, nil,
+			).Once()
+
+			// Without write back, the book is only read.
+			store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient), ddb.WithLegacyTable(legacyTable))
+			require.NoError(t, err)
+			book, err := store.FindOne(ctx, bookID)
+
+			require.NoError(t, err, "version %d", version)
+			require.Equal(t, bookID, book.ID)
+			require.Equal(t, "The Hobbit", book.Title)
+			require.Equal(t, 310, book.Pages)
+		}
+	})
+
+	t.Run("WriteBack", func(t *testing.T) {
+		for version := 0; version < ddb.SchemaVersion; version++ {
+			bookID := uuid.NewString()
+			mockClient := ddb.NewMockDynamoDBClient(t)
+			mockClient.EXPECT().GetItem(ctx, mock.MatchedBy(func(in *dynamodb.GetItemInput) bool {
+				return *in.TableName == expectedTable
+			})).Return(&dynamodb.GetItemOutput{}, nil).Twice()
+			mockClient.EXPECT().GetItem(ctx, legacyGet(bookID)).Return(
+				&dynamodb.GetItemOutput{Item: legacyItem(bookID, version)}, nil,
+			).Twice()
+			mockClient.EXPECT().PutItem(ctx, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+				return isCopied(in, bookID)
+			})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+			// The book was copied concurrently, e.g. by the catch-up of the
+			// cutover: the copy is left as it is.
+			mockClient.EXPECT().PutItem(ctx, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+				return isCopied(in, bookID)
+			})).Return(nil, &types.ConditionalCheckFailedException{}).Once()
+
+			store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient),
+				ddb.WithLegacyTable(legacyTable), ddb.WithWriteBack())
+			require.NoError(t, err)
+
+			for range 2 {
+				book, err := store.FindOne(ctx, uuid.MustParse(bookID))
+				require.NoError(t, err, "version %d", version)
+				require.Equal(t, "The Hobbit", book.Title)
+			}
+		}
+	})
+
+	t.Run("WriteBackCurrent", func(t *testing.T) {
+		// Items of the table are at SchemaVersion, nothing is written back.
+		bookID := uuid.NewString()
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().GetItem(ctx, mock.Anything).Return(&dynamodb.GetItemOutput{Item: currentItem(bookID)}, nil).Once()
+
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient),
+			ddb.WithLegacyTable(legacyTable), ddb.WithWriteBack())
+		require.NoError(t, err)
+		_, err = store.FindOne(ctx, uuid.MustParse(bookID))
+
+		require.NoError(t, err)
+	})
+
+	t.Run("NewerVersion", func(t *testing.T) {
+		// A valid item written by a newer deploy, during a rolling deploy.
+		bookID := uuid.NewString()
+		newer := currentItem(bookID)
+		newer["schema_version"] = &types.AttributeValueMemberN{Value: fmt.Sprint(ddb.SchemaVersion + 1)}
+
+		// Neither PutItem nor DeleteItem is expected: the item is never
+		// quarantined, repaired or migrated.
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().GetItem(ctx, mock.Anything).Return(&dynamodb.GetItemOutput{Item: newer}, nil).Once()
+		mockClient.EXPECT().Scan(ctx, mock.Anything).Return(&dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{newer},
+		}, nil).Times(3)
+
+		var reported []ddb.MalformedItem
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient), ddb.WithQuarantine("test-quarantine"),
+			ddb.WithMalformedHandler(func(_ context.Context, _ string, item ddb.MalformedItem) {
+				reported = append(reported, item)
+			}))
+		require.NoError(t, err)
+
+		_, err = store.FindOne(ctx, uuid.MustParse(bookID))
+		require.ErrorIs(t, err, ddb.ErrNewerSchema)
+		require.ErrorIs(t, err, domain.ErrUnavailable)
+		require.NotErrorIs(t, err, ddb.ErrMalformedItem)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Empty(t, books)
+
+		malformed, err := store.FindMalformed(ctx)
+		require.NoError(t, err)
+		require.Empty(t, malformed)
+
+		action, err := store.Repair(ctx, ddb.MalformedItem{ID: bookID, Item: newer, Err: ddb.ErrMalformedItem})
+		require.NoError(t, err)
+		require.Equal(t, ddb.RepairSkipped, action)
+
+		progress, err := store.Migrate(ctx, "", nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, ddb.MigrationProgress{Scanned: 1}, progress)
+
+		require.Empty(t, reported)
+	})
+
+	t.Run("Migrate", func(t *testing.T) {
+		// The items of the table are written at SchemaVersion, so an in-place
+		// migration has nothing to write: it only counts the malformed ones.
+		currentID := uuid.NewString()
+		current := currentItem(currentID)
+		malformed := currentItem(uuid.NewString())
+		malformed["id"] = &types.AttributeValueMemberS{Value: "not-a-uuid"}
+		mockClient := ddb.NewMockDynamoDBClient(t)
+
+		mockClient.EXPECT().Scan(ctx, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+			start, ok := in.ExclusiveStartKey["pk"].(*types.AttributeValueMemberS)
+
+			return *in.TableName == expectedTable && ok && start.Value == "BOOK#resume-from"
+		})).Return(&dynamodb.ScanOutput{
+			Items:            []map[string]types.AttributeValue{current, malformed},
+			LastEvaluatedKey: map[string]types.AttributeValue{"pk": current["pk"], "sk": current["sk"]},
+		}, nil).Once()
+		mockClient.EXPECT().Scan(ctx, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+			start, ok := in.ExclusiveStartKey["pk"].(*types.AttributeValueMemberS)
+
+			return ok && start.Value == "BOOK#"+currentID
+		})).Return(&dynamodb.ScanOutput{}, nil).Once()
+
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+
+		var reports []ddb.MigrationProgress
+		startAfter := map[string]string{"pk": "BOOK#resume-from", "sk": "BOOK#resume-from"}
+		progress, err := store.Migrate(ctx, "", startAfter, func(p ddb.MigrationProgress) error {
+			reports = append(reports, p)
+
+			return nil
+		})
+
+		lastKey := map[string]string{"pk": "BOOK#" + currentID, "sk": "BOOK#" + currentID}
+		require.NoError(t, err)
+		require.Equal(t, ddb.MigrationProgress{Scanned: 2, Failed: 1, LastKey: lastKey}, progress)
+		require.Len(t, reports, 2)
+	})
+
+	t.Run("MigrateFrom", func(t *testing.T) {
+		v0ID, v1ID, copiedID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+		mockClient := ddb.NewMockDynamoDBClient(t)
+
+		mockClient.EXPECT().Scan(ctx, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+			start, ok := in.ExclusiveStartKey["id"].(*types.AttributeValueMemberS)
+
+			return *in.TableName == legacyTable && ok && start.Value == "resume-from"
+		})).Return(&dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{
+				legacyItem(v0ID, 0),
+				legacyItem(v1ID, 1),
+				legacyItem("not-a-uuid", 0),
+			},
+			LastEvaluatedKey: map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: v1ID}},
+		}, nil).Once()
+		mockClient.EXPECT().Scan(ctx, mock.MatchedBy(func(in *dynamodb.ScanInput) bool {
+			start, ok := in.ExclusiveStartKey["id"].(*types.AttributeValueMemberS)
+
+			return ok && start.Value == v1ID
+		})).Return(&dynamodb.ScanOutput{
+			Items: []map[string]types.AttributeValue{legacyItem(copiedID, 1)},
+		}, nil).Once()
+
+		for _, id := range []string{v0ID, v1ID} {
+			mockClient.EXPECT().PutItem(ctx, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+				return isCopied(in, id)
+			})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+		}
+
+		// The book was copied already, e.g. by a previous run.
+		mockClient.EXPECT().PutItem(ctx, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+			return isCopied(in, copiedID)
+		})).Return(nil, &types.ConditionalCheckFailedException{}).Once()
+
+		store, err := ddb.NewStore(ctx, expectedTable, ddb.WithClient(mockClient))
+		require.NoError(t, err)
+
+		progress, err := store.Migrate(ctx, legacyTable, map[string]string{"id": "resume-from"}, nil)
+
+		require.NoError(t, err)
+		require.Equal(t, ddb.MigrationProgress{Scanned: 4, Upgraded: 2, Failed: 1, LastKey: map[string]string{"id": v1ID}}, progress)
+	})
+}
+
+// TestConformance runs the Storer conformance suite on the in-process fake of
+// the catalog table.
+func TestConformance(t *testing.T) {
+	storertest.Run(t, func(t *testing.T) domain.Storer {
+		client := ddbtest.NewClient(ddbtest.CatalogTable("catalog"))
+		store, err := ddb.NewStore(context.Background(), "catalog", ddb.WithClient(client))
+		require.NoError(t, err)
+
+		return store
+	})
+}
@@ -0,0 +1,302 @@
+package ddbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// The requests and the responses of the DynamoDB API are the JSON
+// documents of the input and output structs of the SDK: the fields keep
+// their names, the attribute values are objects keyed by their type (e.g.
+// {"S": "text"}), binaries are base64 strings and times are seconds since
+// the epoch.
+
+var (
+	attributeValueType = reflect.TypeOf((*types.AttributeValue)(nil)).Elem()
+	timeType           = reflect.TypeOf(time.Time{})
+)
+
+// decode decodes a request into the input struct pointed by v.
+func decode(data []byte, v any) error {
+	return decodeValue(data, reflect.ValueOf(v).Elem())
+}
+
+func decodeValue(data json.RawMessage, v reflect.Value) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	switch {
+	case v.Type() == attributeValueType:
+		value, err := decodeAttributeValue(data)
+		if err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(value))
+
+		return nil
+	case v.Type() == timeType:
+		var seconds float64
+		if err := json.Unmarshal(data, &seconds); err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(time.Unix(0, int64(seconds*float64(time.Second)))))
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeValue(data, elem.Elem()); err != nil {
+			return err
+		}
+
+		v.Set(elem)
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+
+		for name, field := range fields {
+			f := v.FieldByName(name)
+			if !f.IsValid() || !f.CanSet() {
+				continue
+			}
+
+			if err := decodeValue(field, f); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return json.Unmarshal(data, v.Addr().Interface())
+		}
+
+		var elements []json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return err
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(elements), len(elements))
+		for i, element := range elements {
+			if err := decodeValue(element, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+
+		v.Set(slice)
+	case reflect.Map:
+		var elements map[string]json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return err
+		}
+
+		m := reflect.MakeMapWithSize(v.Type(), len(elements))
+		for key, element := range elements {
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(element, value); err != nil {
+				return err
+			}
+
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), value)
+		}
+
+		v.Set(m)
+	default: // strings, enums, numbers and booleans
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+
+	return nil
+}
+
+// decodeAttributeValue decodes an attribute value, an object with a single
+// field named after its type.
+func decodeAttributeValue(data json.RawMessage) (types.AttributeValue, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	if len(fields) != 1 {
+		return nil, fmt.Errorf("supplied AttributeValue must contain exactly one of the supported datatypes")
+	}
+
+	for name, field := range fields {
+		var (
+			value types.AttributeValue
+			err   error
+		)
+
+		switch name {
+		case "S":
+			v := &types.AttributeValueMemberS{}
+			value, err = v, json.Unmarshal(field, &v.Value)
+		case "N":
+			v := &types.AttributeValueMemberN{}
+			value, err = v, json.Unmarshal(field, &v.Value)
+		case "B":
+			v := &types.AttributeValueMemberB{}
+			value, err = v, json.Unmarshal(field, &v.Value)
+		case "BOOL":
+			v := &types.AttributeValueMemberBOOL{}
+			value, err = v, json.Unmarshal(field, &v.Value)
+		case "NULL":
+			v := &types.AttributeValueMemberNULL{}
+			value, err = v, json.Unmarshal(field, &v.Value)
+		case "SS":
+			v := &types.AttributeValueMemberSS{}
+			value, err = v, json.Unmarshal(field, &v.Value)
+		case "NS":
+			v := &types.AttributeValueMemberNS{}
+			value, err = v, json.Unmarshal(field, &v.Value)
+		case "BS":
+			v := &types.AttributeValueMemberBS{}
+			value, err = v, json.Unmarshal(field, &v.Value)
+		case "L":
+			v := &types.AttributeValueMemberL{}
+			value, err = v, decodeValue(field, reflect.ValueOf(&v.Value).Elem())
+		case "M":
+			v := &types.AttributeValueMemberM{}
+			value, err = v, decodeValue(field, reflect.ValueOf(&v.Value).Elem())
+		default:
+			err = fmt.Errorf("unsupported AttributeValue type %q", name)
+		}
+
+		return value, err
+	}
+
+	return nil, nil
+}
+
+// encode returns the JSON document of an output struct or of an error.
+func encode(v any) any {
+	document, _ := encodeValue(reflect.ValueOf(v))
+
+	return document
+}
+
+// encodeValue returns the JSON document of v, false when v is not set and
+// is omitted.
+func encodeValue(v reflect.Value) (any, bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+
+		if value, ok := v.Interface().(types.AttributeValue); ok {
+			return encodeAttributeValue(value), true
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time) //nolint:forcetypeassert
+
+		return float64(t.UnixNano()) / float64(time.Second), true
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, false
+		}
+
+		return encodeValue(v.Elem())
+	case reflect.Struct:
+		document := make(map[string]any)
+
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			if !field.IsExported() || field.Name == "ResultMetadata" {
+				continue
+			}
+
+			if value, ok := encodeValue(v.Field(i)); ok {
+				document[field.Name] = value
+			}
+		}
+
+		return document, true
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, false
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), true
+		}
+
+		elements := make([]any, v.Len())
+		for i := range elements {
+			elements[i], _ = encodeValue(v.Index(i))
+		}
+
+		return elements, true
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, false
+		}
+
+		elements := make(map[string]any, v.Len())
+		for _, key := range v.MapKeys() {
+			elements[key.String()], _ = encodeValue(v.MapIndex(key))
+		}
+
+		return elements, true
+	case reflect.String:
+		return v.String(), v.Len() > 0
+	default: // numbers and booleans
+		return v.Interface(), true
+	}
+}
+
+// encodeAttributeValue returns the JSON document of an attribute value.
+func encodeAttributeValue(value types.AttributeValue) map[string]any {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]any{"S": v.Value}
+	case *types.AttributeValueMemberN:
+		return map[string]any{"N": v.Value}
+	case *types.AttributeValueMemberB:
+		return map[string]any{"B": v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return map[string]any{"BOOL": v.Value}
+	case *types.AttributeValueMemberNULL:
+		return map[string]any{"NULL": v.Value}
+	case *types.AttributeValueMemberSS:
+		return map[string]any{"SS": v.Value}
+	case *types.AttributeValueMemberNS:
+		return map[string]any{"NS": v.Value}
+	case *types.AttributeValueMemberBS:
+		return map[string]any{"BS": v.Value}
+	case *types.AttributeValueMemberL:
+		elements := make([]any, len(v.Value))
+		for i, element := range v.Value {
+			elements[i] = encodeAttributeValue(element)
+		}
+
+		return map[string]any{"L": elements}
+	case *types.AttributeValueMemberM:
+		elements := make(map[string]any, len(v.Value))
+		for name, element := range v.Value {
+			elements[name] = encodeAttributeValue(element)
+		}
+
+		return map[string]any{"M": elements}
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,608 @@
+// Package ddbtest provides Client, an in-process fake of DynamoDB
+// implementing ddb.DynamoDBClient, to test the code built on ddb.Store
+// against stateful tables instead of mocked requests, and to serve the
+// DynamoDB API locally without Docker (see cmd/ddblocal).
+//
+// The fake keeps its tables in memory and understands key schemas and
+// global secondary indexes, condition, filter, key condition and projection
+// expressions, Limit/ExclusiveStartKey pagination, batch and transactional
+// requests, and validates them as DynamoDB does. It does not implement
+// UpdateItem, local secondary indexes, streams, TTL, the reserved words of
+// the expressions nor the 1 MB limit of the pages of Query and Scan.
+package ddbtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/rotiroti/alessandrina/sys/database/ddb"
+)
+
+// Item is an item of a table.
+type Item = map[string]types.AttributeValue
+
+// Client is a stateful, in-memory DynamoDBClient. It is safe for concurrent
+// use, every request is applied atomically.
+type Client struct {
+	mu         sync.Mutex
+	tables     map[string]*table
+	failures   map[string][]error
+	batchLimit int
+}
+
+// Ensure Client implements the DynamoDBClient interface.
+var _ ddb.DynamoDBClient = (*Client)(nil)
+
+// NewClient returns a Client serving the given empty tables.
+func NewClient(tables ...Table) *Client {
+	c := &Client{
+		tables:   make(map[string]*table, len(tables)),
+		failures: make(map[string][]error),
+	}
+
+	for _, t := range tables {
+		c.tables[t.Name] = newTable(t)
+	}
+
+	return c
+}
+
+// FailNext makes the next requests of operation, e.g. "PutItem", fail with
+// the given errors, one per request, before they are applied. It simulates
+// throttling and outages, e.g. with a types.ProvisionedThroughputExceededException.
+func (c *Client) FailNext(operation string, errs ...error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failures[operation] = append(c.failures[operation], errs...)
+}
+
+// SetBatchLimit makes BatchGetItem and BatchWriteItem process at most n keys
+// or writes of each request, returning the others as unprocessed, as
+// DynamoDB does when a table is throttled. Zero, the default, removes the
+// limit.
+func (c *Client) SetBatchLimit(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.batchLimit = n
+}
+
+// Items returns a copy of the items of a table, ordered by primary key, nil
+// when there is no such table.
+func (c *Client) Items(tableName string) []Item {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.tables[tableName]
+	if !ok {
+		return nil
+	}
+
+	items := t.sorted([]KeySchema{t.Key}, nil)
+	for i, item := range items {
+		items[i] = cloneItem(item)
+	}
+
+	return items
+}
+
+// begin locks the Client for a request of operation, failing with the next
+// injected failure, if any. The caller must unlock the Client.
+func (c *Client) begin(operation string) error {
+	c.mu.Lock()
+
+	if errs := c.failures[operation]; len(errs) > 0 {
+		c.failures[operation] = errs[1:]
+
+		return errs[0]
+	}
+
+	return nil
+}
+
+// validationError returns the error of DynamoDB for an invalid request.
+func validationError(format string, args ...any) error {
+	return &smithy.GenericAPIError{
+		Code:    "ValidationException",
+		Message: fmt.Sprintf(format, args...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+// conditionFailed returns the error of DynamoDB for a failed condition,
+// with the current item when requested by ReturnValuesOnConditionCheckFailure.
+func conditionFailed(returnValues types.ReturnValuesOnConditionCheckFailure, current Item) error {
+	err := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	if returnValues == types.ReturnValuesOnConditionCheckFailureAllOld && current != nil {
+		err.Item = cloneItem(current)
+	}
+
+	return err
+}
+
+// table returns the table with the given name.
+func (c *Client) table(name *string) (*table, error) {
+	t, ok := c.tables[aws.ToString(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{
+			Message: aws.String("Requested resource not found: Table: " + aws.ToString(name) + " not found"),
+		}
+	}
+
+	return t, nil
+}
+
+// check parses the condition of a write request and evaluates it against
+// the current item, nil when there is none.
+func check(condition *string, names map[string]string, values Item, current Item,
+	returnValues types.ReturnValuesOnConditionCheckFailure,
+) error {
+	if condition == nil && (names != nil || values != nil) {
+		return validationError("ExpressionAttributeNames and ExpressionAttributeValues can only be specified when using expressions")
+	}
+
+	x := newExpressions(names, values)
+
+	expr, err := x.condition("ConditionExpression", condition)
+	if err != nil {
+		return err
+	}
+
+	if err := x.checkUsage(); err != nil {
+		return err
+	}
+
+	if expr == nil {
+		return nil
+	}
+
+	if current == nil {
+		current = Item{}
+	}
+
+	if !expr.eval(current) {
+		return conditionFailed(returnValues, current)
+	}
+
+	return nil
+}
+
+// projector parses the projection expression of a read request, used by
+// project.
+func projector(projection *string, names map[string]string) ([]path, error) {
+	if projection == nil && names != nil {
+		return nil, validationError("ExpressionAttributeNames can only be specified when using expressions")
+	}
+
+	x := newExpressions(names, nil)
+
+	paths, err := x.projection(projection)
+	if err != nil {
+		return nil, err
+	}
+
+	return paths, x.checkUsage()
+}
+
+// GetItem returns the item with the given primary key.
+func (c *Client) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	err := c.begin("GetItem")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := projector(params.ProjectionExpression, params.ExpressionAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+
+	_, item, err := t.lookup(params.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: project(item, paths)}, nil
+}
+
+// PutItem creates or replaces an item, if its condition holds.
+func (c *Client) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	err := c.begin("PutItem")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkReturnValues(params.ReturnValues); err != nil {
+		return nil, err
+	}
+
+	key, err := t.validate(params.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+
+	err = check(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, old,
+		params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	t.items[key] = cloneItem(params.Item)
+
+	output := &dynamodb.PutItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = old
+	}
+
+	return output, nil
+}
+
+// DeleteItem deletes the item with the given primary key, if its condition
+// holds. Deleting a missing item is not an error.
+func (c *Client) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	err := c.begin("DeleteItem")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkReturnValues(params.ReturnValues); err != nil {
+		return nil, err
+	}
+
+	key, old, err := t.lookup(params.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = check(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, old,
+		params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(t.items, key)
+
+	output := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = old
+	}
+
+	return output, nil
+}
+
+// checkReturnValues checks the ReturnValues of PutItem and DeleteItem,
+// which only return the old item.
+func checkReturnValues(returnValues types.ReturnValue) error {
+	switch returnValues {
+	case "", types.ReturnValueNone, types.ReturnValueAllOld:
+		return nil
+	default:
+		return validationError("ReturnValues can only be ALL_OLD or NONE")
+	}
+}
+
+// BatchWriteItem puts and deletes up to ddb.MaxBatchWriteItems items, in
+// any number of tables. The writes are not conditional and a batch cannot
+// write the same item twice.
+func (c *Client) BatchWriteItem(_ context.Context, params *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	err := c.begin("BatchWriteItem")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	type write struct {
+		table   *table
+		key     string
+		item    Item
+		request types.WriteRequest
+	}
+
+	// The whole batch is validated before any write, as DynamoDB does.
+	var writes []write
+
+	seen := make(map[string]bool)
+
+	for _, name := range sortedNames(params.RequestItems) {
+		t, err := c.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, request := range params.RequestItems[name] {
+			w := write{table: t, request: request}
+
+			switch {
+			case request.PutRequest != nil && request.DeleteRequest == nil:
+				w.item = request.PutRequest.Item
+				w.key, err = t.validate(w.item)
+			case request.DeleteRequest != nil && request.PutRequest == nil:
+				w.key, _, err = t.lookup(request.DeleteRequest.Key)
+			default:
+				err = validationError("Supplied WriteRequest must contain exactly one of PutRequest or DeleteRequest")
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			if seen[name+"\x00"+w.key] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+
+			seen[name+"\x00"+w.key] = true
+			writes = append(writes, w)
+		}
+	}
+
+	if len(writes) == 0 || len(writes) > ddb.MaxBatchWriteItems {
+		return nil, validationError("Member must have length less than or equal to %d: %d write requests", ddb.MaxBatchWriteItems, len(writes))
+	}
+
+	unprocessed := make(map[string][]types.WriteRequest)
+
+	for i, w := range writes {
+		if c.batchLimit > 0 && i >= c.batchLimit {
+			unprocessed[w.table.Name] = append(unprocessed[w.table.Name], w.request)
+			continue
+		}
+
+		if w.item == nil {
+			delete(w.table.items, w.key)
+			continue
+		}
+
+		w.table.items[w.key] = cloneItem(w.item)
+	}
+
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil
+}
+
+// BatchGetItem returns up to ddb.MaxBatchGetItems items, from any number of
+// tables, in no particular order.
+func (c *Client) BatchGetItem(_ context.Context, params *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	err := c.begin("BatchGetItem")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for _, request := range params.RequestItems {
+		count += len(request.Keys)
+	}
+
+	if count == 0 || count > ddb.MaxBatchGetItems {
+		return nil, validationError("Too many items requested for the BatchGetItem call: %d keys", count)
+	}
+
+	responses := make(map[string][]Item, len(params.RequestItems))
+	unprocessed := make(map[string]types.KeysAndAttributes)
+	processed := 0
+
+	for _, name := range sortedNames(params.RequestItems) {
+		request := params.RequestItems[name]
+
+		t, err := c.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+
+		paths, err := projector(request.ProjectionExpression, request.ExpressionAttributeNames)
+		if err != nil {
+			return nil, err
+		}
+
+		items := make([]Item, 0, len(request.Keys))
+		seen := make(map[string]bool, len(request.Keys))
+
+		for _, key := range request.Keys {
+			encoded, item, err := t.lookup(key)
+			if err != nil {
+				return nil, err
+			}
+
+			if seen[encoded] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+
+			seen[encoded] = true
+
+			if c.batchLimit > 0 && processed >= c.batchLimit {
+				rest := unprocessed[name]
+				rest.Keys = append(rest.Keys, key)
+				rest.ProjectionExpression = request.ProjectionExpression
+				rest.ExpressionAttributeNames = request.ExpressionAttributeNames
+				rest.ConsistentRead = request.ConsistentRead
+				unprocessed[name] = rest
+
+				continue
+			}
+
+			processed++
+
+			if item != nil {
+				items = append(items, project(item, paths))
+			}
+		}
+
+		responses[name] = items
+	}
+
+	return &dynamodb.BatchGetItemOutput{Responses: responses, UnprocessedKeys: unprocessed}, nil
+}
+
+// sortedNames returns the keys of m in order, so that the batches are
+// processed in a deterministic order.
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Scan reads the items of a table, or of one of its indexes, in the order of
+// their keys.
+func (c *Client) Scan(_ context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	err := c.begin("Scan")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Segment != nil || params.TotalSegments != nil {
+		return nil, validationError("ddbtest: parallel scans are not supported")
+	}
+
+	x := newExpressions(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+
+	filter, err := x.condition("FilterExpression", params.FilterExpression)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := x.projection(params.ProjectionExpression)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := x.checkUsage(); err != nil {
+		return nil, err
+	}
+
+	result, err := t.read(readRequest{
+		index:    params.IndexName,
+		filter:   filter,
+		paths:    paths,
+		startKey: params.ExclusiveStartKey,
+		limit:    params.Limit,
+		forward:  true,
+		count:    params.Select == types.SelectCount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.ScanOutput{
+		Items:            result.items,
+		Count:            result.count,
+		ScannedCount:     result.scanned,
+		LastEvaluatedKey: result.lastKey,
+	}, nil
+}
+
+// Query reads the items of a partition of a table, or of one of its
+// indexes, matching the key condition, in the order of their sort key.
+func (c *Client) Query(_ context.Context, params *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	err := c.begin("Query")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.KeyConditionExpression == nil {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request")
+	}
+
+	x := newExpressions(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+
+	keyCondition, err := x.condition("KeyConditionExpression", params.KeyConditionExpression)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := x.condition("FilterExpression", params.FilterExpression)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := x.projection(params.ProjectionExpression)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := x.checkUsage(); err != nil {
+		return nil, err
+	}
+
+	schemas, err := t.schemas(params.IndexName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkKeyCondition(keyCondition, schemas[0]); err != nil {
+		return nil, err
+	}
+
+	result, err := t.read(readRequest{
+		index:        params.IndexName,
+		keyCondition: keyCondition,
+		filter:       filter,
+		paths:        paths,
+		startKey:     params.ExclusiveStartKey,
+		limit:        params.Limit,
+		forward:      params.ScanIndexForward == nil || *params.ScanIndexForward,
+		count:        params.Select == types.SelectCount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.QueryOutput{
+		Items:            result.items,
+		Count:            result.count,
+		ScannedCount:     result.scanned,
+		LastEvaluatedKey: result.lastKey,
+	}, nil
+}
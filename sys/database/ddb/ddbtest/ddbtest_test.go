@@ -0,0 +1,535 @@
+package ddbtest_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/domain/storertest"
+	"github.com/rotiroti/alessandrina/sys/database/ddb"
+	"github.com/rotiroti/alessandrina/sys/database/ddb/ddbtest"
+	"github.com/stretchr/testify/require"
+)
+
+const tableName = "catalog"
+
+func s(value string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: value}
+}
+
+func n(value int) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.Itoa(value)}
+}
+
+func key(pk, sk string) ddbtest.Item {
+	return ddbtest.Item{"pk": s(pk), "sk": s(sk)}
+}
+
+// requireAPIError requires err to be an error of the DynamoDB API with the
+// given code.
+func requireAPIError(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var apiErr smithy.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, code, apiErr.ErrorCode(), apiErr.ErrorMessage())
+}
+
+// newClient returns a Client with the catalog table holding the given
+// items.
+func newClient(t *testing.T, items ...ddbtest.Item) *ddbtest.Client {
+	t.Helper()
+
+	client := ddbtest.NewClient(ddbtest.CatalogTable(tableName))
+	for _, item := range items {
+		_, err := client.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: item})
+		require.NoError(t, err)
+	}
+
+	return client
+}
+
+func TestItems(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Conditions", func(t *testing.T) {
+		client := newClient(t)
+		item := ddbtest.Item{"pk": s("BOOK#1"), "sk": s("BOOK#1"), "title": s("Learning Go"), "pages": n(375)}
+		put := &dynamodb.PutItemInput{
+			TableName:           aws.String(tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(pk)"),
+		}
+
+		_, err := client.PutItem(ctx, put)
+		require.NoError(t, err)
+
+		put.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+		_, err = client.PutItem(ctx, put)
+
+		var conditionErr *types.ConditionalCheckFailedException
+		require.ErrorAs(t, err, &conditionErr)
+		require.Equal(t, item, conditionErr.Item)
+
+		// The items are copied, changing the request does not change the table.
+		item["title"] = s("Changed")
+		got, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:                aws.String(tableName),
+			Key:                      key("BOOK#1", "BOOK#1"),
+			ProjectionExpression:     aws.String("#title, pages"),
+			ExpressionAttributeNames: map[string]string{"#title": "title"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, ddbtest.Item{"title": s("Learning Go"), "pages": n(375)}, got.Item)
+
+		deleted, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName:                 aws.String(tableName),
+			Key:                       key("BOOK#1", "BOOK#1"),
+			ConditionExpression:       aws.String("pages BETWEEN :min AND :max"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":min": n(100), ":max": n(400)},
+			ReturnValues:              types.ReturnValueAllOld,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Learning Go", deleted.Attributes["title"].(*types.AttributeValueMemberS).Value)
+		require.Empty(t, client.Items(tableName))
+
+		_, err = client.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: aws.String(tableName), Key: key("BOOK#1", "BOOK#1")})
+		require.NoError(t, err)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		client := newClient(t)
+		tests := []struct {
+			name  string
+			input *dynamodb.PutItemInput
+		}{
+			{"MissingKey", &dynamodb.PutItemInput{Item: ddbtest.Item{"pk": s("A")}}},
+			{"KeyType", &dynamodb.PutItemInput{Item: ddbtest.Item{"pk": s("A"), "sk": n(1)}}},
+			{"EmptyKey", &dynamodb.PutItemInput{Item: key("A", "")}},
+			{"IndexKeyType", &dynamodb.PutItemInput{Item: ddbtest.Item{"pk": s("A"), "sk": s("A"), "kind": n(1)}}},
+			{"EmptySet", &dynamodb.PutItemInput{Item: ddbtest.Item{"pk": s("A"), "sk": s("A"), "tags": &types.AttributeValueMemberSS{}}}},
+			{"InvalidNumber", &dynamodb.PutItemInput{Item: ddbtest.Item{"pk": s("A"), "sk": s("A"), "pages": &types.AttributeValueMemberN{Value: "many"}}}},
+			{"UnusedValue", &dynamodb.PutItemInput{
+				Item:                      key("A", "A"),
+				ConditionExpression:       aws.String("attribute_not_exists(pk)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":unused": n(1)},
+			}},
+			{"UndefinedName", &dynamodb.PutItemInput{Item: key("A", "A"), ConditionExpression: aws.String("attribute_exists(#pk)")}},
+			{"Syntax", &dynamodb.PutItemInput{Item: key("A", "A"), ConditionExpression: aws.String("attribute_exists(pk) AND")}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tt.input.TableName = aws.String(tableName)
+				_, err := client.PutItem(ctx, tt.input)
+				requireAPIError(t, err, "ValidationException")
+			})
+		}
+
+		_, err := client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(tableName), Key: ddbtest.Item{"pk": s("A")}})
+		requireAPIError(t, err, "ValidationException")
+
+		_, err = client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("missing"), Key: key("A", "A")})
+		require.ErrorAs(t, err, new(*types.ResourceNotFoundException))
+		require.Empty(t, client.Items(tableName))
+	})
+}
+
+func TestExpressions(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t,
+		ddbtest.Item{"pk": s("A"), "sk": s("A"), "title": s("Learning Go"), "pages": n(375),
+			"tags": &types.AttributeValueMemberSS{Value: []string{"go"}},
+			"meta": &types.AttributeValueMemberM{Value: ddbtest.Item{
+				"editions": &types.AttributeValueMemberL{Value: []types.AttributeValue{n(2021), n(2024)}},
+			}},
+		},
+		ddbtest.Item{"pk": s("B"), "sk": s("B"), "title": s("The Go Programming Language"), "pages": n(400)},
+		ddbtest.Item{"pk": s("C"), "sk": s("C"), "title": s("Concurrency in Go"), "pages": n(238)},
+	)
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"pages > :n", []string{"A", "B"}},
+		{"pages >= :n OR begins_with(title, :prefix)", []string{"A", "B", "C"}},
+		{"NOT (pages > :n) AND NOT attribute_exists(tags)", []string{"C"}},
+		{"pages BETWEEN :low AND :n", []string{"C"}},
+		{"pk IN (:a, :b) AND pages <> :n", []string{"A", "B"}},
+		{"contains(tags, :go) OR contains(title, :word)", []string{"A", "B"}},
+		{"size(title) < :n AND size(title) > :low", nil},
+		{"size(title) <= :short", []string{"A"}},
+		{"attribute_type(tags, :set)", []string{"A"}},
+		{"meta.editions[1] = :edition", []string{"A"}},
+		{"pages = :title", nil},
+	}
+
+	values := map[string]types.AttributeValue{
+		":n": n(300), ":low": n(200), ":prefix": s("Conc"), ":a": s("A"), ":b": s("B"), ":go": s("go"),
+		":word": s("Programming"), ":short": n(11), ":set": s("SS"), ":edition": n(2024), ":title": s("Learning Go"),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			input := &dynamodb.ScanInput{
+				TableName:                 aws.String(tableName),
+				FilterExpression:          aws.String(tt.filter),
+				ExpressionAttributeValues: make(map[string]types.AttributeValue),
+				ProjectionExpression:      aws.String("pk"),
+			}
+
+			for _, token := range []string{":n", ":low", ":prefix", ":a", ":b", ":go", ":word", ":short", ":set", ":edition", ":title"} {
+				if containsToken(tt.filter, token) {
+					input.ExpressionAttributeValues[token] = values[token]
+				}
+			}
+
+			out, err := client.Scan(ctx, input)
+			require.NoError(t, err)
+
+			var got []string
+			for _, item := range out.Items {
+				got = append(got, item["pk"].(*types.AttributeValueMemberS).Value)
+			}
+
+			require.Equal(t, tt.want, got)
+			require.Equal(t, int32(3), out.ScannedCount)
+		})
+	}
+}
+
+// containsToken reports whether the placeholder token is in expr.
+func containsToken(expr, token string) bool {
+	for i := 0; i+len(token) <= len(expr); i++ {
+		if expr[i:i+len(token)] != token {
+			continue
+		}
+
+		end := i + len(token)
+		if end == len(expr) || expr[end] == ' ' || expr[end] == ',' || expr[end] == ')' {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+
+	var items []ddbtest.Item
+	for i := range 7 {
+		items = append(items, ddbtest.Item{"pk": s("LIST"), "sk": s("ITEM#" + strconv.Itoa(i)), "kind": s("item"), "id": s(strconv.Itoa(i))})
+	}
+
+	// Items without the key attributes of the index are not listed in it.
+	items = append(items, key("OTHER", "ITEM#9"))
+	client := newClient(t, items...)
+
+	query := func(forward bool, start ddbtest.Item) *dynamodb.QueryOutput {
+		t.Helper()
+
+		out, err := client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(tableName),
+			IndexName:                 aws.String(ddb.ByIDIndex),
+			KeyConditionExpression:    aws.String("kind = :kind AND id >= :from"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":kind": s("item"), ":from": s("2")},
+			Limit:                     aws.Int32(2),
+			ExclusiveStartKey:         start,
+			ScanIndexForward:          aws.Bool(forward),
+		})
+		require.NoError(t, err)
+
+		return out
+	}
+
+	for _, forward := range []bool{true, false} {
+		t.Run("Forward="+strconv.FormatBool(forward), func(t *testing.T) {
+			var (
+				ids   []string
+				start ddbtest.Item
+				pages int
+			)
+
+			for {
+				out := query(forward, start)
+				pages++
+
+				for _, item := range out.Items {
+					ids = append(ids, item["id"].(*types.AttributeValueMemberS).Value)
+				}
+
+				if out.LastEvaluatedKey == nil {
+					break
+				}
+
+				require.Len(t, out.LastEvaluatedKey, 4, "the keys of the table and of the index")
+				start = out.LastEvaluatedKey
+			}
+
+			want := []string{"2", "3", "4", "5", "6"}
+			if !forward {
+				want = []string{"6", "5", "4", "3", "2"}
+			}
+
+			require.Equal(t, want, ids)
+			require.Equal(t, 3, pages)
+		})
+	}
+
+	t.Run("KeyCondition", func(t *testing.T) {
+		for _, condition := range []string{"sk = :v", "pk = :v OR sk = :v", "pk = :v AND title = :v", "pk > :v"} {
+			_, err := client.Query(ctx, &dynamodb.QueryInput{
+				TableName:                 aws.String(tableName),
+				KeyConditionExpression:    aws.String(condition),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":v": s("LIST")},
+			})
+			requireAPIError(t, err, "ValidationException")
+		}
+
+		out, err := client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(tableName),
+			KeyConditionExpression:    aws.String("pk = :pk AND sk BETWEEN :from AND :to"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("LIST"), ":from": s("ITEM#1"), ":to": s("ITEM#3")},
+			Select:                    types.SelectCount,
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(3), out.Count)
+		require.Nil(t, out.Items)
+	})
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+
+	puts := make([]types.WriteRequest, 5)
+	for i := range puts {
+		puts[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: key("BOOK#"+strconv.Itoa(i), "BOOK")}}
+	}
+
+	_, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{tableName: {puts[0], puts[0]}},
+	})
+	requireAPIError(t, err, "ValidationException")
+
+	client.SetBatchLimit(3)
+	out, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{tableName: puts},
+	})
+	require.NoError(t, err)
+	require.Equal(t, puts[3:], out.UnprocessedItems[tableName])
+	require.Len(t, client.Items(tableName), 3)
+
+	get, err := client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]types.KeysAndAttributes{tableName: {Keys: []map[string]types.AttributeValue{
+			key("BOOK#0", "BOOK"), key("BOOK#9", "BOOK"), key("BOOK#1", "BOOK"), key("BOOK#2", "BOOK"),
+		}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, get.Responses[tableName], 2, "a missing key and an unprocessed one")
+	require.Equal(t, []map[string]types.AttributeValue{key("BOOK#2", "BOOK")}, get.UnprocessedKeys[tableName].Keys)
+}
+
+// TestStoreRetries checks that ddb.Store retries the throttled requests and
+// translates the injected failures.
+func TestStoreRetries(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+	store, err := ddb.NewStore(ctx, tableName, ddb.WithClient(client))
+	require.NoError(t, err)
+
+	books := make([]domain.Book, 20)
+	for i := range books {
+		books[i] = domain.Book{ID: uuid.New(), Title: "Book " + strconv.Itoa(i)}
+	}
+
+	client.FailNext("TransactWriteItems", &types.ProvisionedThroughputExceededException{Message: aws.String("slow down")})
+	require.NoError(t, store.SaveMany(ctx, books))
+	require.Len(t, client.Items(tableName), len(books))
+
+	client.FailNext("GetItem", &types.ProvisionedThroughputExceededException{Message: aws.String("slow down")})
+	_, err = store.FindOne(ctx, books[0].ID)
+	require.ErrorIs(t, err, domain.ErrThrottled)
+
+	ret, err := store.FindOne(ctx, books[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, books[0], ret)
+}
+
+func TestTransactions(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t, key("A", "A"))
+
+	write := func(items ...types.TransactWriteItem) error {
+		_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+
+		return err
+	}
+
+	putNew := func(pk string) types.TransactWriteItem {
+		return types.TransactWriteItem{Put: &types.Put{
+			TableName:           aws.String(tableName),
+			Item:                key(pk, pk),
+			ConditionExpression: aws.String("attribute_not_exists(pk)"),
+		}}
+	}
+
+	err := write(putNew("B"), putNew("A"))
+
+	var canceled *types.TransactionCanceledException
+	require.ErrorAs(t, err, &canceled)
+	require.Len(t, canceled.CancellationReasons, 2)
+	require.Equal(t, "None", aws.ToString(canceled.CancellationReasons[0].Code))
+	require.Equal(t, "ConditionalCheckFailed", aws.ToString(canceled.CancellationReasons[1].Code))
+	require.Len(t, client.Items(tableName), 1, "nothing is written when the transaction is canceled")
+
+	err = write(putNew("B"), types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			TableName:           aws.String(tableName),
+			Key:                 key("A", "A"),
+			ConditionExpression: aws.String("attribute_exists(pk)"),
+		},
+	}, types.TransactWriteItem{Delete: &types.Delete{TableName: aws.String(tableName), Key: key("C", "C")}})
+	require.NoError(t, err)
+	require.Equal(t, []ddbtest.Item{key("A", "A"), key("B", "B")}, client.Items(tableName))
+
+	err = write(putNew("C"), types.TransactWriteItem{Delete: &types.Delete{TableName: aws.String(tableName), Key: key("C", "C")}})
+	requireAPIError(t, err, "ValidationException")
+
+	got, err := client.TransactGetItems(ctx, &dynamodb.TransactGetItemsInput{TransactItems: []types.TransactGetItem{
+		{Get: &types.Get{TableName: aws.String(tableName), Key: key("B", "B")}},
+		{Get: &types.Get{TableName: aws.String(tableName), Key: key("C", "C")}},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, key("B", "B"), got.Responses[0].Item)
+	require.Nil(t, got.Responses[1].Item)
+}
+
+// TestServer runs the Storer conformance suite, and a few requests of the
+// DynamoDB API, through the HTTP endpoint served by a Client.
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(ddbtest.NewClient())
+	defer server.Close()
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion("local"),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("local", "local", "")),
+	)
+	require.NoError(t, err)
+
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		// The conformance tests create their tables concurrently.
+		o.EndpointResolver = dynamodb.EndpointResolverFromURL(server.URL, func(e *aws.Endpoint) {
+			e.SigningRegion = "local"
+		})
+	})
+	table := ddbtest.CatalogTable(tableName)
+	_, err = client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("pk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("gsi1pk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("gsi1sk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("kind"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("pk"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sk"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(ddb.GSI1),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("gsi1pk"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("gsi1sk"), KeyType: types.KeyTypeRange},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+			{
+				IndexName: aws.String(ddb.ByIDIndex),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("kind"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("id"), KeyType: types.KeyTypeRange},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	require.NoError(t, err)
+
+	described, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	require.NoError(t, err)
+	require.Equal(t, types.TableStatusActive, described.Table.TableStatus)
+	require.Len(t, described.Table.GlobalSecondaryIndexes, len(table.Indexes))
+
+	t.Run("Errors", func(t *testing.T) {
+		put := func() error {
+			_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{{
+				Put: &types.Put{
+					TableName:           aws.String(tableName),
+					Item:                ddbtest.Item{"pk": s("X"), "sk": s("X"), "data": &types.AttributeValueMemberB{Value: []byte{0, 1}}},
+					ConditionExpression: aws.String("attribute_not_exists(pk)"),
+				},
+			}}})
+
+			return err
+		}
+
+		require.NoError(t, put())
+
+		var canceled *types.TransactionCanceledException
+		require.ErrorAs(t, put(), &canceled)
+		require.Equal(t, "ConditionalCheckFailed", aws.ToString(canceled.CancellationReasons[0].Code))
+
+		got, err := client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(tableName), Key: key("X", "X")})
+		require.NoError(t, err)
+		require.Equal(t, []byte{0, 1}, got.Item["data"].(*types.AttributeValueMemberB).Value)
+
+		_, err = client.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: aws.String(tableName), Key: key("X", "X")})
+		require.NoError(t, err)
+
+		_, err = client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(tableName), Key: ddbtest.Item{"pk": s("X")}})
+		requireAPIError(t, err, "ValidationException")
+	})
+
+	t.Run("Conformance", func(t *testing.T) {
+		storertest.Run(t, func(t *testing.T) domain.Storer {
+			// Every test has its own table on the shared server.
+			name := "catalog-" + uuid.NewString()
+			_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+				TableName:            aws.String(name),
+				AttributeDefinitions: described.Table.AttributeDefinitions,
+				KeySchema:            described.Table.KeySchema,
+				GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+					{IndexName: aws.String(ddb.GSI1), KeySchema: described.Table.GlobalSecondaryIndexes[0].KeySchema, Projection: described.Table.GlobalSecondaryIndexes[0].Projection},
+					{IndexName: aws.String(ddb.ByIDIndex), KeySchema: described.Table.GlobalSecondaryIndexes[1].KeySchema, Projection: described.Table.GlobalSecondaryIndexes[1].Projection},
+				},
+			})
+			require.NoError(t, err)
+
+			store, err := ddb.NewStore(ctx, name,
+				ddb.WithEndpoint(server.URL),
+				ddb.WithRegion("local"),
+				ddb.WithStaticCredentials("local", "local", ""),
+			)
+			require.NoError(t, err)
+
+			return store
+		})
+	})
+}
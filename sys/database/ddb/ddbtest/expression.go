@@ -0,0 +1,760 @@
+package ddbtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// expression is a parsed condition, filter or key condition expression.
+type expression interface {
+	eval(item Item) bool
+}
+
+// operand is a side of a comparison: an attribute of the item, a value of
+// ExpressionAttributeValues or the size of an attribute.
+type operand interface {
+	value(item Item) (types.AttributeValue, bool)
+}
+
+// element is a step of a document path: the name of an attribute of a map,
+// or the index of an element of a list when name is empty.
+type element struct {
+	name  string
+	index int
+}
+
+// path is a document path, e.g. a.b[1].c.
+type path []element
+
+// value returns the value at p in item, false when there is none.
+func (p path) value(item Item) (types.AttributeValue, bool) {
+	var current types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+
+	for _, step := range p {
+		switch v := current.(type) {
+		case *types.AttributeValueMemberM:
+			if step.name == "" {
+				return nil, false
+			}
+
+			next, ok := v.Value[step.name]
+			if !ok {
+				return nil, false
+			}
+
+			current = next
+		case *types.AttributeValueMemberL:
+			if step.name != "" || step.index >= len(v.Value) {
+				return nil, false
+			}
+
+			current = v.Value[step.index]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// attribute returns the name of the top-level attribute of p.
+func (p path) attribute() string {
+	return p[0].name
+}
+
+func (p path) String() string {
+	var b strings.Builder
+
+	for i, step := range p {
+		switch {
+		case step.name == "":
+			fmt.Fprintf(&b, "[%d]", step.index)
+		case i > 0:
+			b.WriteString("." + step.name)
+		default:
+			b.WriteString(step.name)
+		}
+	}
+
+	return b.String()
+}
+
+// literal is a value of ExpressionAttributeValues.
+type literal struct {
+	types.AttributeValue
+}
+
+func (l literal) value(Item) (types.AttributeValue, bool) {
+	return l.AttributeValue, true
+}
+
+// size is the size function, an operand.
+type size struct {
+	path path
+}
+
+func (s size) value(item Item) (types.AttributeValue, bool) {
+	value, ok := s.path.value(item)
+	if !ok {
+		return nil, false
+	}
+
+	var n int
+
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		n = utf8.RuneCountInString(v.Value)
+	case *types.AttributeValueMemberB:
+		n = len(v.Value)
+	case *types.AttributeValueMemberSS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberNS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberBS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberL:
+		n = len(v.Value)
+	case *types.AttributeValueMemberM:
+		n = len(v.Value)
+	default:
+		return nil, false
+	}
+
+	return &types.AttributeValueMemberN{Value: strconv.Itoa(n)}, true
+}
+
+// and holds when both of its expressions hold.
+type and struct {
+	left, right expression
+}
+
+func (e and) eval(item Item) bool {
+	return e.left.eval(item) && e.right.eval(item)
+}
+
+// or holds when either of its expressions holds.
+type or struct {
+	left, right expression
+}
+
+func (e or) eval(item Item) bool {
+	return e.left.eval(item) || e.right.eval(item)
+}
+
+// not holds when its expression does not.
+type not struct {
+	expr expression
+}
+
+func (e not) eval(item Item) bool {
+	return !e.expr.eval(item)
+}
+
+// comparison compares two operands, it does not hold when either of them is
+// missing or when they cannot be compared.
+type comparison struct {
+	operator    string
+	left, right operand
+}
+
+func (e comparison) eval(item Item) bool {
+	left, ok := e.left.value(item)
+	if !ok {
+		return false
+	}
+
+	right, ok := e.right.value(item)
+	if !ok {
+		return false
+	}
+
+	if e.operator == "=" || e.operator == "<>" {
+		return equalValues(left, right) == (e.operator == "=")
+	}
+
+	c, ok := compareValues(left, right)
+	if !ok {
+		return false
+	}
+
+	switch e.operator {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// between holds when the operand is within the bounds, both included.
+type between struct {
+	operand, lower, upper operand
+}
+
+func (e between) eval(item Item) bool {
+	return comparison{">=", e.operand, e.lower}.eval(item) && comparison{"<=", e.operand, e.upper}.eval(item)
+}
+
+// in holds when the operand is equal to one of the candidates.
+type in struct {
+	operand    operand
+	candidates []operand
+}
+
+func (e in) eval(item Item) bool {
+	for _, candidate := range e.candidates {
+		if (comparison{"=", e.operand, candidate}).eval(item) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// function is a call of one of the functions of the condition expressions.
+type function struct {
+	name string
+	path path
+	arg  operand
+}
+
+func (e function) eval(item Item) bool {
+	value, ok := e.path.value(item)
+
+	switch e.name {
+	case "attribute_exists":
+		return ok
+	case "attribute_not_exists":
+		return !ok
+	}
+
+	if !ok {
+		return false
+	}
+
+	arg, ok := e.arg.value(item)
+	if !ok {
+		return false
+	}
+
+	switch e.name {
+	case "attribute_type":
+		want, ok := arg.(*types.AttributeValueMemberS)
+
+		return ok && typeOf(value) == want.Value
+	case "begins_with":
+		return beginsWith(value, arg)
+	default: // contains
+		return contains(value, arg)
+	}
+}
+
+// functions lists the functions that are conditions, with their number of
+// arguments; size is an operand.
+var functions = map[string]int{
+	"attribute_exists":     1,
+	"attribute_not_exists": 1,
+	"attribute_type":       2,
+	"begins_with":          2,
+	"contains":             2,
+}
+
+// expressions parses the expressions of a request, recording which of its
+// ExpressionAttributeNames and ExpressionAttributeValues they use: DynamoDB
+// rejects the requests defining names or values that are not used.
+type expressions struct {
+	names      map[string]string
+	values     Item
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+// newExpressions returns the parser of the expressions of a request with
+// the given ExpressionAttributeNames and ExpressionAttributeValues.
+func newExpressions(names map[string]string, values Item) *expressions {
+	return &expressions{
+		names:      names,
+		values:     values,
+		usedNames:  make(map[string]bool),
+		usedValues: make(map[string]bool),
+	}
+}
+
+// condition parses a condition, filter or key condition expression, nil
+// when expr is nil.
+func (x *expressions) condition(parameter string, expr *string) (expression, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	p := &parser{expressions: x, tokens: tokenize(*expr)}
+
+	e, err := p.parseOr()
+	if err == nil && !p.done() {
+		err = fmt.Errorf("syntax error; token: %q", p.peek())
+	}
+
+	if err != nil {
+		return nil, validationError("Invalid %s: %v", parameter, err)
+	}
+
+	return e, nil
+}
+
+// projection parses a projection expression, nil when expr is nil.
+func (x *expressions) projection(expr *string) ([]path, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	p := &parser{expressions: x, tokens: tokenize(*expr)}
+
+	var paths []path
+
+	for {
+		attr, err := p.parsePath()
+		if err != nil {
+			return nil, validationError("Invalid ProjectionExpression: %v", err)
+		}
+
+		paths = append(paths, attr)
+
+		if p.done() {
+			return paths, nil
+		}
+
+		if token := p.next(); token != "," {
+			return nil, validationError("Invalid ProjectionExpression: syntax error; token: %q", token)
+		}
+	}
+}
+
+// checkUsage fails when some names or values of the request are not used
+// by its expressions.
+func (x *expressions) checkUsage() error {
+	if unused := unusedKeys(x.names, x.usedNames); unused != "" {
+		return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", unused)
+	}
+
+	if unused := unusedKeys(x.values, x.usedValues); unused != "" {
+		return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", unused)
+	}
+
+	return nil
+}
+
+// unusedKeys returns the keys of m missing in used, sorted and comma
+// separated.
+func unusedKeys[V any](m map[string]V, used map[string]bool) string {
+	var unused []string
+
+	for key := range m {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+
+	sort.Strings(unused)
+
+	return strings.Join(unused, ", ")
+}
+
+// tokenize splits an expression into names, placeholders, operators and
+// punctuation.
+func tokenize(expr string) []string {
+	var tokens []string
+
+	for i := 0; i < len(expr); {
+		r := rune(expr[i])
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("(),.[]", r):
+			tokens = append(tokens, expr[i:i+1])
+			i++
+		case strings.ContainsRune("<>=", r):
+			j := i + 1
+			if j < len(expr) && strings.ContainsRune("<>=", rune(expr[j])) {
+				j++
+			}
+
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			j := i + 1
+			for j < len(expr) && (expr[j] == '_' || unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j]))) {
+				j++
+			}
+
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+
+	return tokens
+}
+
+// parser is a recursive descent parser of an expression. From the lowest
+// to the highest precedence: OR, AND, NOT, then comparisons, BETWEEN, IN
+// and functions.
+type parser struct {
+	*expressions
+	tokens []string
+}
+
+func (p *parser) done() bool {
+	return len(p.tokens) == 0
+}
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+
+	return p.tokens[0]
+}
+
+func (p *parser) next() string {
+	token := p.peek()
+	if !p.done() {
+		p.tokens = p.tokens[1:]
+	}
+
+	return token
+}
+
+// keyword reports whether the next token is the given keyword, consuming
+// it when it is.
+func (p *parser) keyword(keyword string) bool {
+	if !strings.EqualFold(p.peek(), keyword) {
+		return false
+	}
+
+	p.next()
+
+	return true
+}
+
+func (p *parser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("syntax error; expected %q, found %q", token, got)
+	}
+
+	return nil
+}
+
+func (p *parser) parseOr() (expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = or{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expression, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = and{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (expression, error) {
+	if p.keyword("NOT") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return not{e}, nil
+	}
+
+	return p.parseCondition()
+}
+
+func (p *parser) parseCondition() (expression, error) {
+	if p.peek() == "(" {
+		p.next()
+
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		return e, p.expect(")")
+	}
+
+	if _, ok := functions[p.peek()]; ok {
+		return p.parseFunction()
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch operator := p.next(); {
+	case operator == "=", operator == "<>", operator == "<", operator == "<=", operator == ">", operator == ">=":
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		return comparison{operator: operator, left: left, right: right}, nil
+	case strings.EqualFold(operator, "BETWEEN"):
+		lower, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.keyword("AND") {
+			return nil, fmt.Errorf("syntax error; expected AND in BETWEEN, found %q", p.peek())
+		}
+
+		upper, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		return between{operand: left, lower: lower, upper: upper}, nil
+	case strings.EqualFold(operator, "IN"):
+		candidates, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+
+		return in{operand: left, candidates: candidates}, nil
+	default:
+		return nil, fmt.Errorf("syntax error; token: %q", operator)
+	}
+}
+
+// parseList parses the parenthesized candidates of IN, up to 100.
+func (p *parser) parseList() ([]operand, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var candidates []operand
+
+	for {
+		candidate, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, candidate)
+
+		if p.peek() != "," {
+			break
+		}
+
+		p.next()
+	}
+
+	if len(candidates) > 100 {
+		return nil, fmt.Errorf("the IN operator accepts up to 100 operands, found %d", len(candidates))
+	}
+
+	return candidates, p.expect(")")
+}
+
+func (p *parser) parseFunction() (expression, error) {
+	name := p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	attr, err := p.parsePath()
+	if err != nil {
+		return nil, fmt.Errorf("the first argument of %s: %w", name, err)
+	}
+
+	e := function{name: name, path: attr}
+
+	if functions[name] == 2 {
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+
+		if e.arg, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, p.expect(")")
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	switch token := p.peek(); {
+	case strings.HasPrefix(token, ":"):
+		p.next()
+
+		value, ok := p.values[token]
+		if !ok {
+			return nil, fmt.Errorf("an expression attribute value used in expression is not defined; attribute value: %s", token)
+		}
+
+		p.usedValues[token] = true
+
+		return literal{value}, nil
+	case token == "size" && len(p.tokens) > 1 && p.tokens[1] == "(":
+		p.next()
+		p.next()
+
+		attr, err := p.parsePath()
+		if err != nil {
+			return nil, fmt.Errorf("the argument of size: %w", err)
+		}
+
+		return size{attr}, p.expect(")")
+	default:
+		return p.parsePath()
+	}
+}
+
+// parsePath parses a document path, made of attribute names or
+// placeholders of ExpressionAttributeNames, separated by dots, and of list
+// indexes.
+func (p *parser) parsePath() (path, error) {
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+
+	attr := path{{name: name}}
+
+	for {
+		switch p.peek() {
+		case ".":
+			p.next()
+
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+
+			attr = append(attr, element{name: name})
+		case "[":
+			p.next()
+
+			index, err := strconv.Atoi(p.next())
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid list index in %s", attr)
+			}
+
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+
+			attr = append(attr, element{index: index})
+		default:
+			return attr, nil
+		}
+	}
+}
+
+// parseName parses an attribute name or a placeholder of
+// ExpressionAttributeNames.
+func (p *parser) parseName() (string, error) {
+	token := p.next()
+
+	switch {
+	case strings.HasPrefix(token, "#"):
+		name, ok := p.names[token]
+		if !ok {
+			return "", fmt.Errorf("an expression attribute name used in the document path is not defined; attribute name: %s", token)
+		}
+
+		p.usedNames[token] = true
+
+		return name, nil
+	case token == "" || !unicode.IsLetter(rune(token[0])) && token[0] != '_':
+		return "", fmt.Errorf("syntax error; expected an attribute name, found %q", token)
+	default:
+		return token, nil
+	}
+}
+
+// checkKeyCondition checks that a key condition expression selects a
+// partition of the key schema: an equality on the partition key, optionally
+// and a condition on the sort key.
+func checkKeyCondition(e expression, schema KeySchema) error {
+	conditions := []expression{e}
+	if both, ok := e.(and); ok {
+		conditions = []expression{both.left, both.right}
+	}
+
+	var partition, sorted bool
+
+	for _, condition := range conditions {
+		var (
+			attr  path
+			fixed bool
+		)
+
+		switch c := condition.(type) {
+		case comparison:
+			attr, _ = c.left.(path)
+			_, fixed = c.right.(literal)
+
+			if c.operator == "=" && len(attr) == 1 && attr.attribute() == schema.PartitionKey && fixed && !partition {
+				partition = true
+				continue
+			}
+
+			fixed = fixed && c.operator != "<>"
+		case between:
+			attr, _ = c.operand.(path)
+			_, lower := c.lower.(literal)
+			_, upper := c.upper.(literal)
+			fixed = lower && upper
+		case function:
+			attr = c.path
+			_, fixed = c.arg.(literal)
+			fixed = fixed && c.name == "begins_with"
+		}
+
+		if len(attr) != 1 || attr.attribute() != schema.SortKey || schema.SortKey == "" || !fixed || sorted {
+			return validationError("Query key condition not supported")
+		}
+
+		sorted = true
+	}
+
+	if !partition {
+		return validationError("Query condition missed key schema element: %s", schema.PartitionKey)
+	}
+
+	return nil
+}
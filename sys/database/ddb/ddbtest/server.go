@@ -0,0 +1,138 @@
+package ddbtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go"
+)
+
+// targetPrefix prefixes the operation in the X-Amz-Target header of the
+// requests of the DynamoDB API.
+const targetPrefix = "DynamoDB_20120810."
+
+// operation decodes the body of a request, runs it on the Client and
+// returns its output.
+type operation func(c *Client, ctx context.Context, body []byte) (any, error)
+
+// handle returns the operation calling the method of the Client with the
+// decoded input.
+func handle[In, Out any](method func(c *Client, ctx context.Context, params *In, optFns ...func(*dynamodb.Options)) (*Out, error)) operation {
+	return func(c *Client, ctx context.Context, body []byte) (any, error) {
+		params := new(In)
+		if err := decode(body, params); err != nil {
+			return nil, &smithy.GenericAPIError{Code: "SerializationException", Message: err.Error(), Fault: smithy.FaultClient}
+		}
+
+		return method(c, ctx, params)
+	}
+}
+
+// operations lists the operations served by the Client.
+var operations = map[string]operation{
+	"BatchGetItem":       handle((*Client).BatchGetItem),
+	"BatchWriteItem":     handle((*Client).BatchWriteItem),
+	"CreateTable":        handle((*Client).CreateTable),
+	"DeleteItem":         handle((*Client).DeleteItem),
+	"DeleteTable":        handle((*Client).DeleteTable),
+	"DescribeTable":      handle((*Client).DescribeTable),
+	"GetItem":            handle((*Client).GetItem),
+	"ListTables":         handle((*Client).ListTables),
+	"PutItem":            handle((*Client).PutItem),
+	"Query":              handle((*Client).Query),
+	"Scan":               handle((*Client).Scan),
+	"TransactGetItems":   handle((*Client).TransactGetItems),
+	"TransactWriteItems": handle((*Client).TransactWriteItems),
+}
+
+// ServeHTTP serves the DynamoDB API on the tables of the Client, so that
+// the AWS SDKs and CLI can use it as an endpoint, e.g. with ddb.WithEndpoint
+// or DB_ENDPOINT. The requests are not authenticated.
+func (c *Client) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "DynamoDB API requests are POST requests", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	name, _ := strings.CutPrefix(r.Header.Get("X-Amz-Target"), targetPrefix)
+
+	op, ok := operations[name]
+	if !ok {
+		writeError(w, &smithy.GenericAPIError{
+			Code:    "UnknownOperationException",
+			Message: fmt.Sprintf("ddbtest: operation %q is not supported", name),
+			Fault:   smithy.FaultClient,
+		})
+
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, &smithy.GenericAPIError{Code: "SerializationException", Message: err.Error(), Fault: smithy.FaultClient})
+
+		return
+	}
+
+	output, err := op(c, r.Context(), body)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, encode(output))
+}
+
+// writeJSON writes a response of the DynamoDB API, with the checksum of its
+// body verified by the SDKs.
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Header().Set("X-Amz-Crc32", strconv.FormatUint(uint64(crc32.ChecksumIEEE(data)), 10))
+	w.WriteHeader(status)
+	w.Write(data) //nolint:errcheck
+}
+
+// writeError writes an error of the DynamoDB API, its code in __type.
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = &smithy.GenericAPIError{Code: "InternalServerError", Message: err.Error(), Fault: smithy.FaultServer}
+	}
+
+	// The exceptions of the SDK carry their fields, e.g. the
+	// CancellationReasons of a transaction.
+	body := make(map[string]any)
+	if _, generic := apiErr.(*smithy.GenericAPIError); !generic {
+		body, _ = encode(apiErr).(map[string]any)
+	}
+
+	delete(body, "Message")
+	delete(body, "ErrorCodeOverride")
+	body["__type"] = "com.amazonaws.dynamodb.v20120810#" + apiErr.ErrorCode()
+	body["message"] = apiErr.ErrorMessage()
+
+	status := http.StatusBadRequest
+	if apiErr.ErrorFault() == smithy.FaultServer {
+		status = http.StatusInternalServerError
+	}
+
+	writeJSON(w, status, body)
+}
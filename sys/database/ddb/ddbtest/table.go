@@ -0,0 +1,592 @@
+package ddbtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotiroti/alessandrina/sys/database/ddb"
+)
+
+// KeySchema names the partition key attribute and, when the items are
+// sorted, the sort key attribute of a table or of an index.
+type KeySchema struct {
+	PartitionKey string
+	SortKey      string
+}
+
+// names returns the key attributes of s.
+func (s KeySchema) names() []string {
+	if s.SortKey == "" {
+		return []string{s.PartitionKey}
+	}
+
+	return []string{s.PartitionKey, s.SortKey}
+}
+
+// Index describes a global secondary index of a Table. The items missing
+// one of its key attributes are not listed in it.
+type Index struct {
+	Key KeySchema
+
+	// Projection selects the attributes copied into the index, all of them
+	// when nil.
+	Projection *types.Projection
+}
+
+// Table describes a table of the Client.
+type Table struct {
+	Name    string
+	Key     KeySchema
+	Indexes map[string]Index
+
+	// AttributeTypes declares the types (S, N or B) of the key attributes of
+	// the table and of its indexes. An undeclared key attribute accepts any
+	// of them.
+	AttributeTypes map[string]types.ScalarAttributeType
+}
+
+// CatalogTable returns the single-table layout used by ddb.Store, the same
+// created by scripts/create-table.sh.
+func CatalogTable(name string) Table {
+	return Table{
+		Name: name,
+		Key:  KeySchema{PartitionKey: ddb.PartitionKey, SortKey: ddb.SortKey},
+		Indexes: map[string]Index{
+			ddb.GSI1:      {Key: KeySchema{PartitionKey: ddb.GSI1PartitionKey, SortKey: ddb.GSI1SortKey}},
+			ddb.ByIDIndex: {Key: KeySchema{PartitionKey: "kind", SortKey: "id"}},
+		},
+		AttributeTypes: map[string]types.ScalarAttributeType{
+			ddb.PartitionKey:     types.ScalarAttributeTypeS,
+			ddb.SortKey:          types.ScalarAttributeTypeS,
+			ddb.GSI1PartitionKey: types.ScalarAttributeTypeS,
+			ddb.GSI1SortKey:      types.ScalarAttributeTypeS,
+			"kind":               types.ScalarAttributeTypeS,
+			"id":                 types.ScalarAttributeTypeS,
+		},
+	}
+}
+
+// table holds the items of a Table, keyed by their encoded primary key.
+type table struct {
+	Table
+	created time.Time
+	items   map[string]Item
+}
+
+func newTable(t Table) *table {
+	return &table{Table: t, created: time.Now(), items: make(map[string]Item)}
+}
+
+// validateKey checks the value of a key attribute, of the table or of the
+// index named in the errors, and returns it encoded.
+func (t *table) validateKey(name string, value types.AttributeValue, index string) (string, error) {
+	encoded, ok := encodeKey(value)
+	want := t.AttributeTypes[name]
+
+	switch {
+	case !ok, want != "" && typeOf(value) != string(want):
+		if index != "" {
+			return "", validationError("One or more parameter values were invalid: Type mismatch for Index Key %s Expected: %s Actual: %s IndexName: %s",
+				name, want, typeOf(value), index)
+		}
+
+		return "", validationError("One or more parameter values were invalid: Type mismatch for key %s expected: %s actual: %s",
+			name, want, typeOf(value))
+	case len(encoded) == 1: // an empty string or binary
+		return "", validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", name)
+	}
+
+	return encoded, nil
+}
+
+// encodeKey returns the encoded primary key of an item or of a key.
+func (t *table) encodeKey(item Item) (string, error) {
+	key := ""
+
+	for _, name := range t.Key.names() {
+		value, ok := item[name]
+		if !ok {
+			return "", validationError("One or more parameter values were invalid: Missing the key %s in the item", name)
+		}
+
+		encoded, err := t.validateKey(name, value, "")
+		if err != nil {
+			return "", err
+		}
+
+		key += encoded + "\x00"
+	}
+
+	return key, nil
+}
+
+// validate checks an item written to the table and returns its encoded
+// primary key.
+func (t *table) validate(item Item) (string, error) {
+	key, err := t.encodeKey(item)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range sortedNames(t.Indexes) {
+		for _, attr := range t.Indexes[name].Key.names() {
+			if value, ok := item[attr]; ok {
+				if _, err := t.validateKey(attr, value, name); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+
+	for _, value := range item {
+		if err := validateValue(value); err != nil {
+			return "", err
+		}
+	}
+
+	if size := itemSize(item); size > MaxItemSize {
+		return "", validationError("Item size has exceeded the maximum allowed size: %d bytes", size)
+	}
+
+	return key, nil
+}
+
+// lookup returns the encoded primary key of a request and the item stored
+// under it, nil when there is none. The key must hold exactly the key
+// attributes of the table.
+func (t *table) lookup(key Item) (string, Item, error) {
+	if len(key) != len(t.Key.names()) {
+		return "", nil, validationError("The provided key element does not match the schema")
+	}
+
+	encoded, err := t.encodeKey(key)
+	if err != nil {
+		return "", nil, validationError("The provided key element does not match the schema")
+	}
+
+	return encoded, t.items[encoded], nil
+}
+
+// schemas returns the key schemas ordering the items read from the table,
+// or from one of its indexes: the one of the index first.
+func (t *table) schemas(indexName *string) ([]KeySchema, error) {
+	if indexName == nil {
+		return []KeySchema{t.Key}, nil
+	}
+
+	index, ok := t.Indexes[*indexName]
+	if !ok {
+		return nil, validationError("The table does not have the specified index: %s", *indexName)
+	}
+
+	return []KeySchema{index.Key, t.Key}, nil
+}
+
+// sorted returns the items holding the key attributes of the first schema,
+// ordered by the keys of the schemas, the ones matching keyCondition when
+// it is not nil.
+func (t *table) sorted(schemas []KeySchema, keyCondition expression) []Item {
+	items := make([]Item, 0, len(t.items))
+
+	for _, item := range t.items {
+		if !hasKeys(item, schemas[0]) {
+			continue
+		}
+
+		if keyCondition == nil || keyCondition.eval(item) {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return compareKeys(items[i], items[j], schemas) < 0
+	})
+
+	return items
+}
+
+// readRequest is a Query or a Scan.
+type readRequest struct {
+	index        *string
+	keyCondition expression
+	filter       expression
+	paths        []path
+	startKey     Item
+	limit        *int32
+	forward      bool
+	count        bool
+}
+
+// readResult is a page of the items of a table or of an index.
+type readResult struct {
+	items   []Item
+	count   int32
+	scanned int32
+	lastKey Item
+}
+
+// read returns the page of the items matching the key condition, if any,
+// that follow the start key. Up to limit items are evaluated, the ones
+// matching the filter, if any, are returned.
+func (t *table) read(r readRequest) (readResult, error) {
+	schemas, err := t.schemas(r.index)
+	if err != nil {
+		return readResult{}, err
+	}
+
+	if r.limit != nil && *r.limit < 1 {
+		return readResult{}, validationError("1 validation error detected: Value '%d' at 'limit' failed to satisfy constraint: Member must have value greater than or equal to 1", *r.limit)
+	}
+
+	items := t.sorted(schemas, r.keyCondition)
+
+	if !r.forward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	if r.startKey != nil {
+		for _, schema := range schemas {
+			if !hasKeys(r.startKey, schema) {
+				return readResult{}, validationError("The provided starting key is invalid")
+			}
+		}
+
+		start := sort.Search(len(items), func(i int) bool {
+			c := compareKeys(items[i], r.startKey, schemas)
+
+			return r.forward && c > 0 || !r.forward && c < 0
+		})
+		items = items[start:]
+	}
+
+	var result readResult
+
+	for _, item := range items {
+		if r.limit != nil && result.scanned == *r.limit {
+			break
+		}
+
+		result.scanned++
+		result.lastKey = keyAttributes(item, schemas...)
+
+		if r.filter != nil && !r.filter.eval(item) {
+			continue
+		}
+
+		result.count++
+
+		if !r.count {
+			result.items = append(result.items, project(t.indexed(item, r.index), r.paths))
+		}
+	}
+
+	if r.limit == nil || result.scanned < *r.limit {
+		result.lastKey = nil
+	}
+
+	if !r.count && result.items == nil {
+		result.items = []Item{}
+	}
+
+	return result, nil
+}
+
+// indexed returns the attributes of item projected into the index, all of
+// them when reading the table.
+func (t *table) indexed(item Item, indexName *string) Item {
+	if indexName == nil {
+		return item
+	}
+
+	index := t.Indexes[*indexName]
+	if index.Projection == nil || index.Projection.ProjectionType == types.ProjectionTypeAll {
+		return item
+	}
+
+	projected := keyAttributes(item, index.Key, t.Key)
+
+	if index.Projection.ProjectionType == types.ProjectionTypeInclude {
+		for _, name := range index.Projection.NonKeyAttributes {
+			if value, ok := item[name]; ok {
+				projected[name] = value
+			}
+		}
+	}
+
+	return projected
+}
+
+// project returns a copy of the attributes of item at the given paths, all
+// of them when there are no paths.
+func project(item Item, paths []path) Item {
+	if len(paths) == 0 {
+		return cloneItem(item)
+	}
+
+	projected := make(Item, len(paths))
+
+	for _, p := range paths {
+		value, ok := p.value(item)
+		if !ok {
+			continue
+		}
+
+		// Intermediate maps and lists keep only the projected elements, in
+		// the order of the paths.
+		var parent types.AttributeValue = &types.AttributeValueMemberM{Value: projected}
+
+		for i, step := range p {
+			last := i == len(p)-1
+			container, _ := path(p[:i+1]).value(item)
+
+			switch v := parent.(type) {
+			case *types.AttributeValueMemberM:
+				if last {
+					v.Value[step.name] = cloneValue(value)
+				} else if _, ok := v.Value[step.name]; !ok {
+					v.Value[step.name] = emptyLike(container)
+				}
+
+				parent = v.Value[step.name]
+			case *types.AttributeValueMemberL:
+				v.Value = append(v.Value, emptyLike(container))
+				if last {
+					v.Value[len(v.Value)-1] = cloneValue(value)
+				}
+
+				parent = v.Value[len(v.Value)-1]
+			}
+		}
+	}
+
+	return projected
+}
+
+// emptyLike returns an empty map or list, like value.
+func emptyLike(value types.AttributeValue) types.AttributeValue {
+	if _, ok := value.(*types.AttributeValueMemberL); ok {
+		return &types.AttributeValueMemberL{}
+	}
+
+	return &types.AttributeValueMemberM{Value: Item{}}
+}
+
+// keyAttributes returns a copy of the attributes of item in the given key
+// schemas.
+func keyAttributes(item Item, schemas ...KeySchema) Item {
+	key := make(Item)
+
+	for _, schema := range schemas {
+		for _, name := range schema.names() {
+			if value, ok := item[name]; ok {
+				key[name] = cloneValue(value)
+			}
+		}
+	}
+
+	return key
+}
+
+// hasKeys reports whether item has all the key attributes of schema.
+func hasKeys(item Item, schema KeySchema) bool {
+	for _, name := range schema.names() {
+		if _, ok := item[name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareKeys compares the key attributes of two items in the order of the
+// given key schemas: the partition key, then the sort key of each schema.
+func compareKeys(a, b Item, schemas []KeySchema) int {
+	for _, schema := range schemas {
+		for _, name := range schema.names() {
+			if c, ok := compareValues(a[name], b[name]); ok && c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+// CreateTable creates a table, ready to be used on return. Only the key
+// schemas, the attribute definitions and the global secondary indexes of
+// the request are taken into account.
+func (c *Client) CreateTable(_ context.Context, params *dynamodb.CreateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	err := c.begin("CreateTable")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	name := aws.ToString(params.TableName)
+	if _, ok := c.tables[name]; ok {
+		return nil, &types.ResourceInUseException{Message: aws.String("Table already exists: " + name)}
+	}
+
+	t := Table{
+		Name:           name,
+		Indexes:        make(map[string]Index, len(params.GlobalSecondaryIndexes)),
+		AttributeTypes: make(map[string]types.ScalarAttributeType, len(params.AttributeDefinitions)),
+	}
+
+	for _, definition := range params.AttributeDefinitions {
+		t.AttributeTypes[aws.ToString(definition.AttributeName)] = definition.AttributeType
+	}
+
+	if t.Key, err = keySchema(params.KeySchema, t.AttributeTypes); err != nil {
+		return nil, err
+	}
+
+	for _, index := range params.GlobalSecondaryIndexes {
+		key, err := keySchema(index.KeySchema, t.AttributeTypes)
+		if err != nil {
+			return nil, err
+		}
+
+		t.Indexes[aws.ToString(index.IndexName)] = Index{Key: key, Projection: index.Projection}
+	}
+
+	c.tables[name] = newTable(t)
+
+	return &dynamodb.CreateTableOutput{TableDescription: c.tables[name].describe()}, nil
+}
+
+// keySchema returns the KeySchema of a table or of an index, checking that
+// its attributes are defined.
+func keySchema(elements []types.KeySchemaElement, defined map[string]types.ScalarAttributeType) (KeySchema, error) {
+	var schema KeySchema
+
+	for _, element := range elements {
+		name := aws.ToString(element.AttributeName)
+		if _, ok := defined[name]; !ok {
+			return KeySchema{}, validationError("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions. Keys: [%s]", name)
+		}
+
+		switch element.KeyType {
+		case types.KeyTypeHash:
+			schema.PartitionKey = name
+		case types.KeyTypeRange:
+			schema.SortKey = name
+		}
+	}
+
+	if schema.PartitionKey == "" {
+		return KeySchema{}, validationError("One or more parameter values were invalid: Invalid KeySchema: Some index key attribute have no definition")
+	}
+
+	return schema, nil
+}
+
+// describe returns the description of the table.
+func (t *table) describe() *types.TableDescription {
+	definitions := make([]types.AttributeDefinition, 0, len(t.AttributeTypes))
+	for _, name := range sortedNames(t.AttributeTypes) {
+		definitions = append(definitions, types.AttributeDefinition{
+			AttributeName: aws.String(name),
+			AttributeType: t.AttributeTypes[name],
+		})
+	}
+
+	indexes := make([]types.GlobalSecondaryIndexDescription, 0, len(t.Indexes))
+	for _, name := range sortedNames(t.Indexes) {
+		projection := t.Indexes[name].Projection
+		if projection == nil {
+			projection = &types.Projection{ProjectionType: types.ProjectionTypeAll}
+		}
+
+		indexes = append(indexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   aws.String(name),
+			IndexStatus: types.IndexStatusActive,
+			KeySchema:   keySchemaElements(t.Indexes[name].Key),
+			Projection:  projection,
+		})
+	}
+
+	return &types.TableDescription{
+		TableName:              aws.String(t.Name),
+		TableArn:               aws.String(fmt.Sprintf("arn:aws:dynamodb:ddblocal:000000000000:table/%s", t.Name)),
+		TableStatus:            types.TableStatusActive,
+		CreationDateTime:       aws.Time(t.created),
+		ItemCount:              aws.Int64(int64(len(t.items))),
+		KeySchema:              keySchemaElements(t.Key),
+		AttributeDefinitions:   definitions,
+		GlobalSecondaryIndexes: indexes,
+		BillingModeSummary:     &types.BillingModeSummary{BillingMode: types.BillingModePayPerRequest},
+	}
+}
+
+// keySchemaElements returns the elements of a key schema of DynamoDB.
+func keySchemaElements(schema KeySchema) []types.KeySchemaElement {
+	elements := []types.KeySchemaElement{
+		{AttributeName: aws.String(schema.PartitionKey), KeyType: types.KeyTypeHash},
+	}
+
+	if schema.SortKey != "" {
+		elements = append(elements, types.KeySchemaElement{AttributeName: aws.String(schema.SortKey), KeyType: types.KeyTypeRange})
+	}
+
+	return elements
+}
+
+// DescribeTable returns the description of a table.
+func (c *Client) DescribeTable(_ context.Context, params *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	err := c.begin("DescribeTable")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.DescribeTableOutput{Table: t.describe()}, nil
+}
+
+// DeleteTable deletes a table and its items.
+func (c *Client) DeleteTable(_ context.Context, params *dynamodb.DeleteTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	err := c.begin("DeleteTable")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(c.tables, t.Name)
+
+	description := t.describe()
+	description.TableStatus = types.TableStatusDeleting
+
+	return &dynamodb.DeleteTableOutput{TableDescription: description}, nil
+}
+
+// ListTables returns the names of the tables, in order.
+func (c *Client) ListTables(_ context.Context, _ *dynamodb.ListTablesInput, _ ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error) {
+	err := c.begin("ListTables")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.ListTablesOutput{TableNames: sortedNames(c.tables)}, nil
+}
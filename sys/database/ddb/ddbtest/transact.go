@@ -0,0 +1,204 @@
+package ddbtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MaxTransactItems is the maximum number of actions of a transaction.
+const MaxTransactItems = 100
+
+// TransactWriteItems applies up to MaxTransactItems puts, deletes and
+// condition checks, on distinct items, all or none of them: when a
+// condition fails, the request fails with a types.TransactionCanceledException
+// holding the reason of the failure of each action.
+func (c *Client) TransactWriteItems(_ context.Context, params *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	err := c.begin("TransactWriteItems")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > MaxTransactItems {
+		return nil, validationError("Member must have length less than or equal to %d: %d actions", MaxTransactItems, len(params.TransactItems))
+	}
+
+	type action struct {
+		table *table
+		key   string
+		item  Item // the item to put, nil to delete
+		write bool
+		check func(current Item) error
+	}
+
+	actions := make([]action, len(params.TransactItems))
+	seen := make(map[string]bool, len(params.TransactItems))
+
+	// Every action is validated before any condition is evaluated.
+	for i, transactItem := range params.TransactItems {
+		var (
+			a     action
+			table *string
+		)
+
+		switch {
+		case transactItem.Put != nil:
+			put := transactItem.Put
+			table, a.item, a.write = put.TableName, put.Item, true
+			a.check = checker(put.ConditionExpression, put.ExpressionAttributeNames, put.ExpressionAttributeValues,
+				put.ReturnValuesOnConditionCheckFailure)
+		case transactItem.Delete != nil:
+			del := transactItem.Delete
+			table, a.write = del.TableName, true
+			a.check = checker(del.ConditionExpression, del.ExpressionAttributeNames, del.ExpressionAttributeValues,
+				del.ReturnValuesOnConditionCheckFailure)
+		case transactItem.ConditionCheck != nil:
+			cc := transactItem.ConditionCheck
+			if cc.ConditionExpression == nil {
+				return nil, validationError("The ConditionExpression of a ConditionCheck must be specified")
+			}
+
+			table = cc.TableName
+			a.check = checker(cc.ConditionExpression, cc.ExpressionAttributeNames, cc.ExpressionAttributeValues,
+				cc.ReturnValuesOnConditionCheckFailure)
+		case transactItem.Update != nil:
+			return nil, validationError("ddbtest: Update actions are not supported")
+		default:
+			return nil, validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+		}
+
+		if a.table, err = c.table(table); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case transactItem.Put != nil:
+			a.key, err = a.table.validate(a.item)
+		case transactItem.Delete != nil:
+			a.key, _, err = a.table.lookup(transactItem.Delete.Key)
+		default:
+			a.key, _, err = a.table.lookup(transactItem.ConditionCheck.Key)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[a.table.Name+"\x00"+a.key] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		}
+
+		seen[a.table.Name+"\x00"+a.key] = true
+		actions[i] = a
+	}
+
+	reasons := make([]types.CancellationReason, len(actions))
+	canceled := false
+
+	for i, a := range actions {
+		reasons[i] = types.CancellationReason{Code: aws.String("None")}
+
+		err := a.check(a.table.items[a.key])
+
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			reasons[i] = types.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: conditionErr.Message,
+				Item:    conditionErr.Item,
+			}
+			canceled = true
+
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if canceled {
+		codes := make([]string, len(reasons))
+		for i, reason := range reasons {
+			codes[i] = aws.ToString(reason.Code)
+		}
+
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for _, a := range actions {
+		switch {
+		case !a.write:
+		case a.item == nil:
+			delete(a.table.items, a.key)
+		default:
+			a.table.items[a.key] = cloneItem(a.item)
+		}
+	}
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// checker returns the function evaluating the condition of an action of a
+// transaction against the current item.
+func checker(condition *string, names map[string]string, values Item,
+	returnValues types.ReturnValuesOnConditionCheckFailure,
+) func(current Item) error {
+	return func(current Item) error {
+		return check(condition, names, values, current, returnValues)
+	}
+}
+
+// TransactGetItems returns up to MaxTransactItems items, from a consistent
+// snapshot of the tables.
+func (c *Client) TransactGetItems(_ context.Context, params *dynamodb.TransactGetItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	err := c.begin("TransactGetItems")
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > MaxTransactItems {
+		return nil, validationError("Member must have length less than or equal to %d: %d actions", MaxTransactItems, len(params.TransactItems))
+	}
+
+	responses := make([]types.ItemResponse, len(params.TransactItems))
+
+	for i, transactItem := range params.TransactItems {
+		get := transactItem.Get
+		if get == nil {
+			return nil, validationError("TransactItems can only contain Get actions")
+		}
+
+		t, err := c.table(get.TableName)
+		if err != nil {
+			return nil, err
+		}
+
+		paths, err := projector(get.ProjectionExpression, get.ExpressionAttributeNames)
+		if err != nil {
+			return nil, err
+		}
+
+		_, item, err := t.lookup(get.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		if item != nil {
+			responses[i].Item = project(item, paths)
+		}
+	}
+
+	return &dynamodb.TransactGetItemsOutput{Responses: responses}, nil
+}
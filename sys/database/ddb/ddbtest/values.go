@@ -0,0 +1,331 @@
+package ddbtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// encodeKey returns a key attribute value as a string that is equal for
+// equal values, false when the value cannot be a key.
+func encodeKey(value types.AttributeValue) (string, bool) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return "S" + v.Value, true
+	case *types.AttributeValueMemberN:
+		n, ok := parseNumber(v.Value)
+		if !ok {
+			return "", false
+		}
+
+		return "N" + n.Text('g', -1), true
+	case *types.AttributeValueMemberB:
+		return "B" + base64.StdEncoding.EncodeToString(v.Value), true
+	default:
+		return "", false
+	}
+}
+
+// parseNumber parses the value of a number attribute.
+func parseNumber(s string) (*big.Float, bool) {
+	n, _, err := big.ParseFloat(s, 10, 128, big.ToNearestEven)
+
+	return n, err == nil
+}
+
+// compareValues compares two strings, numbers or binaries of the same type,
+// false when they cannot be compared.
+func compareValues(a, b types.AttributeValue) (int, bool) {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS:
+		if b, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(a.Value, b.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if b, ok := b.(*types.AttributeValueMemberN); ok {
+			x, okA := parseNumber(a.Value)
+			y, okB := parseNumber(b.Value)
+			if !okA || !okB {
+				return 0, false
+			}
+
+			return x.Cmp(y), true
+		}
+	case *types.AttributeValueMemberB:
+		if b, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(a.Value, b.Value), true
+		}
+	}
+
+	return 0, false
+}
+
+// equalValues reports whether two values are equal, numbers are compared
+// by value.
+func equalValues(a, b types.AttributeValue) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// beginsWith reports whether a string or a binary begins with prefix.
+func beginsWith(value, prefix types.AttributeValue) bool {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		p, ok := prefix.(*types.AttributeValueMemberS)
+
+		return ok && strings.HasPrefix(v.Value, p.Value)
+	case *types.AttributeValueMemberB:
+		p, ok := prefix.(*types.AttributeValueMemberB)
+
+		return ok && bytes.HasPrefix(v.Value, p.Value)
+	default:
+		return false
+	}
+}
+
+// cloneItem returns a deep copy of item, so that the items stored by the
+// Client are not shared with the callers.
+func cloneItem(item Item) Item {
+	clone := make(Item, len(item))
+	for name, value := range item {
+		clone[name] = cloneValue(value)
+	}
+
+	return clone
+}
+
+// cloneValue returns a deep copy of value.
+func cloneValue(value types.AttributeValue) types.AttributeValue {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: bytes.Clone(v.Value)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberBS:
+		values := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			values[i] = bytes.Clone(b)
+		}
+
+		return &types.AttributeValueMemberBS{Value: values}
+	case *types.AttributeValueMemberL:
+		values := make([]types.AttributeValue, len(v.Value))
+		for i, element := range v.Value {
+			values[i] = cloneValue(element)
+		}
+
+		return &types.AttributeValueMemberL{Value: values}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: cloneItem(v.Value)}
+	default:
+		return value
+	}
+}
+
+// typeOf returns the DynamoDB data type of value, e.g. S or SS.
+func typeOf(value types.AttributeValue) string {
+	switch value.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	default:
+		return ""
+	}
+}
+
+// contains reports whether a string contains a substring, a set contains
+// an element or a list contains a value.
+func contains(value, operand types.AttributeValue) bool {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		o, ok := operand.(*types.AttributeValueMemberS)
+
+		return ok && strings.Contains(v.Value, o.Value)
+	case *types.AttributeValueMemberB:
+		o, ok := operand.(*types.AttributeValueMemberB)
+
+		return ok && bytes.Contains(v.Value, o.Value)
+	case *types.AttributeValueMemberSS:
+		for _, element := range v.Value {
+			if equalValues(&types.AttributeValueMemberS{Value: element}, operand) {
+				return true
+			}
+		}
+	case *types.AttributeValueMemberNS:
+		for _, element := range v.Value {
+			if equalValues(&types.AttributeValueMemberN{Value: element}, operand) {
+				return true
+			}
+		}
+	case *types.AttributeValueMemberBS:
+		for _, element := range v.Value {
+			if equalValues(&types.AttributeValueMemberB{Value: element}, operand) {
+				return true
+			}
+		}
+	case *types.AttributeValueMemberL:
+		for _, element := range v.Value {
+			if equalValues(element, operand) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// MaxItemSize is the maximum size of an item, in bytes.
+const MaxItemSize = 400 * 1024
+
+// validateValue checks that the numbers of value are valid and that its
+// sets are neither empty nor hold duplicates.
+func validateValue(value types.AttributeValue) error {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberN:
+		if _, ok := parseNumber(v.Value); !ok {
+			return validationError("The parameter cannot be converted to a numeric value: %s", v.Value)
+		}
+	case *types.AttributeValueMemberSS:
+		return validateSet("SS", len(v.Value), func(i int) types.AttributeValue {
+			return &types.AttributeValueMemberS{Value: v.Value[i]}
+		})
+	case *types.AttributeValueMemberNS:
+		return validateSet("NS", len(v.Value), func(i int) types.AttributeValue {
+			return &types.AttributeValueMemberN{Value: v.Value[i]}
+		})
+	case *types.AttributeValueMemberBS:
+		return validateSet("BS", len(v.Value), func(i int) types.AttributeValue {
+			return &types.AttributeValueMemberB{Value: v.Value[i]}
+		})
+	case *types.AttributeValueMemberL:
+		for _, element := range v.Value {
+			if err := validateValue(element); err != nil {
+				return err
+			}
+		}
+	case *types.AttributeValueMemberM:
+		for _, element := range v.Value {
+			if err := validateValue(element); err != nil {
+				return err
+			}
+		}
+	case nil:
+		return validationError("Supplied AttributeValue is empty, must contain exactly one of the supported datatypes")
+	}
+
+	return nil
+}
+
+// validateSet checks that a set of n elements is neither empty nor holds
+// duplicates.
+func validateSet(name string, n int, element func(i int) types.AttributeValue) error {
+	if n == 0 {
+		return validationError("One or more parameter values were invalid: An %s may not be empty", name)
+	}
+
+	seen := make(map[string]bool, n)
+
+	for i := range n {
+		if err := validateValue(element(i)); err != nil {
+			return err
+		}
+
+		key, _ := encodeKey(element(i))
+		if seen[key] {
+			return validationError("One or more parameter values were invalid: Input collection contains duplicates")
+		}
+
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// itemSize returns the size of an item as DynamoDB accounts it: the
+// length of the attribute names plus the size of their values.
+func itemSize(item Item) int {
+	n := 0
+	for name, value := range item {
+		n += len(name) + valueSize(value)
+	}
+
+	return n
+}
+
+// valueSize returns the approximate size of value, in bytes.
+func valueSize(value types.AttributeValue) int {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return (len(v.Value)+1)/2 + 1
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberSS:
+		n := 0
+		for _, element := range v.Value {
+			n += len(element)
+		}
+
+		return n
+	case *types.AttributeValueMemberNS:
+		n := 0
+		for _, element := range v.Value {
+			n += (len(element)+1)/2 + 1
+		}
+
+		return n
+	case *types.AttributeValueMemberBS:
+		n := 0
+		for _, element := range v.Value {
+			n += len(element)
+		}
+
+		return n
+	case *types.AttributeValueMemberL:
+		n := 3
+		for _, element := range v.Value {
+			n += 1 + valueSize(element)
+		}
+
+		return n
+	case *types.AttributeValueMemberM:
+		return 3 + len(v.Value) + itemSize(v.Value)
+	default: // BOOL and NULL
+		return 1
+	}
+}
@@ -0,0 +1,157 @@
+package ddb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// logModes are the values accepted in the DB_LOG list.
+var logModes = map[string]aws.ClientLogMode{
+	"signing":       aws.LogSigning,
+	"retries":       aws.LogRetries,
+	"request":       aws.LogRequest,
+	"request_body":  aws.LogRequestWithBody,
+	"response":      aws.LogResponse,
+	"response_body": aws.LogResponseWithBody,
+}
+
+// OptionsFromEnv returns the Store Options set by the environment variables
+// shared by the functions and the command-line tools, read with lookup (e.g.
+// os.LookupEnv):
+//
+//	DB_ENDPOINT         URL of a DynamoDB stand-in, e.g. http://localhost:8000
+//	DB_REGION           region of the client
+//	DB_ACCESS_KEY_ID    static credentials of the client, together with
+//	DB_SECRET_ACCESS_KEY
+//	DB_LOG              true|false, or a comma-separated list of signing,
+//	                    retries, request, request_body, response and
+//	                    response_body (default: false)
+//	DB_RETRY_MODE       standard|adaptive (default: standard)
+//	DB_MAX_ATTEMPTS     attempts of a request, the first one included
+//	DB_MAX_BACKOFF      maximum delay between two attempts, e.g. 1s
+//	DB_TIMEOUT          maximum duration of an operation, retries included
+//	DB_DEADLINE_MARGIN  time left to the handler before the deadline of the
+//	                    invocation, e.g. 250ms
+//	DB_LEGACY_TABLE     table looked up for the books not found, see
+//	                    WithLegacyTable
+//	DB_WRITE_BACK       true|false, write back the books upgraded on read,
+//	                    see WithWriteBack (default: false)
+//
+// Unset variables leave the defaults of the AWS SDK.
+func OptionsFromEnv(lookup func(key string) (string, bool)) ([]Option, error) {
+	var opts []Option
+
+	// NOTE: DB_CONNECTION=localstack used to select a hard-coded LocalStack
+	// endpoint, fail loudly rather than silently connecting to AWS.
+	if conn, _ := lookup("DB_CONNECTION"); conn != "" && conn != "aws" {
+		return nil, fmt.Errorf("ddb.optionsfromenv: DB_CONNECTION %q is no longer supported, set DB_ENDPOINT", conn)
+	}
+
+	if value, ok := lookup("DB_ENDPOINT"); ok && value != "" {
+		opts = append(opts, WithEndpoint(value))
+	}
+
+	if value, ok := lookup("DB_REGION"); ok && value != "" {
+		opts = append(opts, WithRegion(value))
+	}
+
+	accessKeyID, _ := lookup("DB_ACCESS_KEY_ID")
+	secretAccessKey, _ := lookup("DB_SECRET_ACCESS_KEY")
+
+	if accessKeyID != "" || secretAccessKey != "" {
+		opts = append(opts, WithStaticCredentials(accessKeyID, secretAccessKey, ""))
+	}
+
+	if value, ok := lookup("DB_LOG"); ok && value != "" {
+		mode, err := parseLogMode(value)
+		if err != nil {
+			return nil, fmt.Errorf("ddb.optionsfromenv: invalid DB_LOG: %w", err)
+		}
+
+		if mode != 0 {
+			opts = append(opts, WithLogMode(mode))
+		}
+	}
+
+	switch mode, _ := lookup("DB_RETRY_MODE"); mode {
+	case "", "standard":
+	case "adaptive":
+		opts = append(opts, WithAdaptiveRetry())
+	default:
+		return nil, fmt.Errorf("ddb.optionsfromenv: invalid DB_RETRY_MODE %q", mode)
+	}
+
+	if value, ok := lookup("DB_MAX_ATTEMPTS"); ok && value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("ddb.optionsfromenv: invalid DB_MAX_ATTEMPTS: %w", err)
+		}
+
+		opts = append(opts, WithMaxAttempts(n))
+	}
+
+	if value, ok := lookup("DB_LEGACY_TABLE"); ok && value != "" {
+		opts = append(opts, WithLegacyTable(value))
+	}
+
+	switch value, _ := lookup("DB_WRITE_BACK"); value {
+	case "", "false":
+	case "true":
+		opts = append(opts, WithWriteBack())
+	default:
+		return nil, fmt.Errorf("ddb.optionsfromenv: invalid DB_WRITE_BACK %q", value)
+	}
+
+	durations := []struct {
+		key    string
+		option func(time.Duration) Option
+	}{
+		{"DB_MAX_BACKOFF", WithMaxBackoff},
+		{"DB_TIMEOUT", WithOperationTimeout},
+		{"DB_DEADLINE_MARGIN", WithDeadlineMargin},
+	}
+
+	for _, d := range durations {
+		value, ok := lookup(d.key)
+		if !ok || value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("ddb.optionsfromenv: invalid %s: %w", d.key, err)
+		}
+
+		opts = append(opts, d.option(duration))
+	}
+
+	return opts, nil
+}
+
+// parseLogMode parses the value of DB_LOG, true stands for the requests and
+// responses with their bodies.
+func parseLogMode(value string) (aws.ClientLogMode, error) {
+	switch value {
+	case "true":
+		return aws.LogRequestWithBody | aws.LogResponseWithBody, nil
+	case "false":
+		return 0, nil
+	}
+
+	var mode aws.ClientLogMode
+
+	for _, name := range strings.Split(value, ",") {
+		m, ok := logModes[strings.TrimSpace(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown log mode %q", name)
+		}
+
+		mode |= m
+	}
+
+	return mode, nil
+}
@@ -0,0 +1,78 @@
+package ddb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rotiroti/alessandrina/sys/database/ddb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) (string, bool) {
+		return func(key string) (string, bool) {
+			value, ok := vars[key]
+			return value, ok
+		}
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		opts, err := ddb.OptionsFromEnv(env(nil))
+
+		require.NoError(t, err)
+		require.Empty(t, opts)
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		opts, err := ddb.OptionsFromEnv(env(map[string]string{
+			"DB_ENDPOINT":          "http://localhost:8000",
+			"DB_REGION":            "eu-south-1",
+			"DB_ACCESS_KEY_ID":     "local",
+			"DB_SECRET_ACCESS_KEY": "local",
+			"DB_LOG":               "retries, request",
+			"DB_RETRY_MODE":        "adaptive",
+			"DB_MAX_ATTEMPTS":      "5",
+			"DB_MAX_BACKOFF":       "1s",
+			"DB_TIMEOUT":           "2s",
+			"DB_DEADLINE_MARGIN":   "250ms",
+			"DB_LEGACY_TABLE":      "legacy-table",
+			"DB_WRITE_BACK":        "true",
+		}))
+		require.NoError(t, err)
+		require.Len(t, opts, 11)
+
+		store, err := ddb.NewStore(context.Background(), "test-table", opts...)
+		require.NoError(t, err)
+		require.NotNil(t, store)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for key, value := range map[string]string{
+			"DB_CONNECTION":      "localstack",
+			"DB_LOG":             "everything",
+			"DB_RETRY_MODE":      "eager",
+			"DB_WRITE_BACK":      "yes",
+			"DB_MAX_ATTEMPTS":    "many",
+			"DB_MAX_BACKOFF":     "1",
+			"DB_TIMEOUT":         "soon",
+			"DB_DEADLINE_MARGIN": "-",
+		} {
+			_, err := ddb.OptionsFromEnv(env(map[string]string{key: value}))
+			require.Error(t, err, key)
+		}
+	})
+
+	t.Run("InvalidOption", func(t *testing.T) {
+		for key, value := range map[string]string{
+			"DB_ENDPOINT":      "localhost:8000",
+			"DB_ACCESS_KEY_ID": "local",
+			"DB_MAX_ATTEMPTS":  "0",
+		} {
+			opts, err := ddb.OptionsFromEnv(env(map[string]string{key: value}))
+			require.NoError(t, err, key)
+
+			_, err = ddb.NewStore(context.Background(), "test-table", opts...)
+			require.Error(t, err, key)
+		}
+	})
+}
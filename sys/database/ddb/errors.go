@@ -0,0 +1,69 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// ErrMalformedItem is used when an item of the table cannot be decoded into a
+// book, e.g. because it was edited by hand.
+var ErrMalformedItem = errors.New("malformed item")
+
+// ErrNewerSchema is used when an item was written at a schema version newer
+// than SchemaVersion, e.g. by a newer deploy while this one is still serving
+// requests. The item is valid: reads skip it, but it is never reported as
+// malformed, quarantined or repaired.
+var ErrNewerSchema = errors.New("item of a newer schema version")
+
+// translateError maps the errors returned by the AWS SDK to the domain error
+// set, keeping the original error in the chain. conditionErr is the domain
+// error reported when the condition expression of a write is not satisfied.
+//
+// Throttling is reported as domain.ErrThrottled whether DynamoDB rejected the
+// last attempt or the client gave up retrying on its own, and an operation
+// that ran out of time as domain.ErrUnavailable.
+func translateError(err error, conditionErr error) error {
+	var (
+		conditionFailed *types.ConditionalCheckFailedException
+		throughput      *types.ProvisionedThroughputExceededException
+		requestLimit    *types.RequestLimitExceeded
+		resource        *types.ResourceNotFoundException
+		internal        *types.InternalServerError
+		quota           ratelimit.QuotaExceededError
+	)
+
+	switch {
+	case errors.As(err, &conditionFailed):
+		if conditionErr == nil {
+			conditionErr = domain.ErrPreconditionFailed
+		}
+
+		return fmt.Errorf("%w: %w", conditionErr, err)
+	case errors.As(err, &throughput), errors.As(err, &requestLimit), errors.As(err, &quota), isThrottleError(err):
+		return fmt.Errorf("%w: %w", domain.ErrThrottled, err)
+	case errors.As(err, &resource), errors.As(err, &internal), errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, err)
+	default:
+		return err
+	}
+}
+
+// isThrottleError reports whether err is an API error with one of the codes
+// the AWS SDK retries as throttling, e.g. ThrottlingException.
+func isThrottleError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	_, ok := retry.DefaultThrottleErrorCodes[apiErr.ErrorCode()]
+
+	return ok
+}
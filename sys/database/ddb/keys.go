@@ -0,0 +1,189 @@
+package ddb
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// The table follows a single-table design: every entity is stored in the
+// same table, under a composite primary key made of the pk and sk
+// attributes, and is listed in the secondary indexes below.
+//
+//	entity  pk           sk           gsi1pk       gsi1sk
+//	book    BOOK#<id>    BOOK#<id>    ISBN#<isbn>  BOOK#<id>
+//
+// Books are the only entity stored so far. Another one adds its EntityType,
+// a struct implementing Entity (see DynamodbBook) and, when it is looked up
+// by another entity, an IndexQuery selecting it in GSI1.
+const (
+	// PartitionKey is the name of the partition key attribute.
+	PartitionKey = "pk"
+
+	// SortKey is the name of the sort key attribute.
+	SortKey = "sk"
+
+	// GSI1 is the global secondary index serving the lookups of an entity by
+	// another one, e.g. books by ISBN.
+	GSI1 = "gsi1"
+
+	// GSI1PartitionKey is the name of the partition key attribute of GSI1.
+	GSI1PartitionKey = "gsi1pk"
+
+	// GSI1SortKey is the name of the sort key attribute of GSI1.
+	GSI1SortKey = "gsi1sk"
+)
+
+// EntityType is the type of an entity stored in the table, it prefixes the
+// keys of its items.
+type EntityType string
+
+// The entity types stored in the table.
+const (
+	BookEntity EntityType = "BOOK"
+)
+
+// Prefix returns the prefix of the keys of the entities of type t.
+func (t EntityType) Prefix() string {
+	return string(t) + "#"
+}
+
+// Key returns the primary key of the entity of type t with the given ID.
+func (t EntityType) Key(id uuid.UUID) Key {
+	return t.key(id.String())
+}
+
+func (t EntityType) key(id string) Key {
+	return Key{PK: t.Prefix() + id, SK: t.Prefix() + id}
+}
+
+// Key is the primary key of an item, or its key in GSI1.
+type Key struct {
+	PK string
+	SK string
+}
+
+// IsZero reports whether k is the zero Key.
+func (k Key) IsZero() bool {
+	return k == Key{}
+}
+
+// AttributeValues returns k as the primary key of a request.
+func (k Key) AttributeValues() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		PartitionKey: &types.AttributeValueMemberS{Value: k.PK},
+		SortKey:      &types.AttributeValueMemberS{Value: k.SK},
+	}
+}
+
+// itemKey returns the primary key of an item.
+func itemKey(item map[string]types.AttributeValue) Key {
+	var key Key
+
+	if attr, ok := item[PartitionKey].(*types.AttributeValueMemberS); ok {
+		key.PK = attr.Value
+	}
+
+	if attr, ok := item[SortKey].(*types.AttributeValueMemberS); ok {
+		key.SK = attr.Value
+	}
+
+	return key
+}
+
+// IndexQuery selects the items of an entity type in a partition of GSI1.
+type IndexQuery struct {
+	PK   string
+	Type EntityType
+}
+
+// BooksByISBN selects the books with the given ISBN, in either form.
+func BooksByISBN(isbn string) IndexQuery {
+	isbn13 := domain.ISBN13(isbn)
+	if isbn13 == "" {
+		isbn13 = domain.NormalizeISBN(isbn)
+	}
+
+	return IndexQuery{PK: "ISBN#" + isbn13, Type: BookEntity}
+}
+
+// Key returns the GSI1 key of the entity with the given ID selected by q.
+func (q IndexQuery) Key(id uuid.UUID) Key {
+	return q.key(id.String())
+}
+
+func (q IndexQuery) key(id string) Key {
+	return Key{PK: q.PK, SK: q.Type.Prefix() + id}
+}
+
+// Input returns the request querying GSI1 of table for the items selected
+// by q.
+func (q IndexQuery) Input(table string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		IndexName:              aws.String(GSI1),
+		KeyConditionExpression: aws.String("#pk = :pk AND begins_with(#sk, :prefix)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": GSI1PartitionKey,
+			"#sk": GSI1SortKey,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     &types.AttributeValueMemberS{Value: q.PK},
+			":prefix": &types.AttributeValueMemberS{Value: q.Type.Prefix()},
+		},
+	}
+}
+
+// Entity is implemented by the structs stored as items of the table.
+type Entity interface {
+	// PrimaryKey returns the primary key of the item.
+	PrimaryKey() Key
+
+	// IndexKey returns the GSI1 key of the item, the zero Key when the item
+	// is not listed in GSI1.
+	IndexKey() Key
+}
+
+// MarshalItem encodes an entity as an item of the table, together with its
+// keys.
+func MarshalItem(entity Entity) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(entity)
+	if err != nil {
+		return nil, fmt.Errorf("ddb.marshalitem: %w", err)
+	}
+
+	setKeys(item, entity.PrimaryKey(), entity.IndexKey())
+
+	return item, nil
+}
+
+// UnmarshalItem decodes an item of the table into an entity, the keys are
+// derived from the other attributes and are not decoded.
+func UnmarshalItem(item map[string]types.AttributeValue, entity Entity) error {
+	if err := attributevalue.UnmarshalMap(item, entity); err != nil {
+		return fmt.Errorf("ddb.unmarshalitem: %w: %w", ErrMalformedItem, err)
+	}
+
+	return nil
+}
+
+// setKeys sets, or removes when zero, the key attributes of an item.
+func setKeys(item map[string]types.AttributeValue, primary, index Key) {
+	item[PartitionKey] = &types.AttributeValueMemberS{Value: primary.PK}
+	item[SortKey] = &types.AttributeValueMemberS{Value: primary.SK}
+
+	if index.IsZero() {
+		delete(item, GSI1PartitionKey)
+		delete(item, GSI1SortKey)
+
+		return
+	}
+
+	item[GSI1PartitionKey] = &types.AttributeValueMemberS{Value: index.PK}
+	item[GSI1SortKey] = &types.AttributeValueMemberS{Value: index.SK}
+}
@@ -0,0 +1,69 @@
+package ddb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/database/ddb"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeys(t *testing.T) {
+	bookID := uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812")
+
+	t.Run("EntityKey", func(t *testing.T) {
+		require.Equal(t, ddb.Key{PK: "BOOK#" + bookID.String(), SK: "BOOK#" + bookID.String()}, ddb.BookEntity.Key(bookID))
+		require.Equal(t, "BOOK#", ddb.BookEntity.Prefix())
+	})
+
+	t.Run("IndexQuery", func(t *testing.T) {
+		// Both forms of an ISBN select the same partition.
+		require.Equal(t, ddb.BooksByISBN("0-13-419044-0"), ddb.BooksByISBN("978-0134190440"))
+		require.Equal(t, ddb.Key{PK: "ISBN#9780134190440", SK: "BOOK#" + bookID.String()}, ddb.BooksByISBN("0134190440").Key(bookID))
+
+		input := ddb.BooksByISBN("978-0134190440").Input("test-table")
+		require.Equal(t, ddb.GSI1, *input.IndexName)
+		require.Equal(t, &types.AttributeValueMemberS{Value: "ISBN#9780134190440"}, input.ExpressionAttributeValues[":pk"])
+		require.Equal(t, &types.AttributeValueMemberS{Value: "BOOK#"}, input.ExpressionAttributeValues[":prefix"])
+	})
+
+	t.Run("MarshalItem", func(t *testing.T) {
+		item, err := ddb.MarshalItem(ddb.ToDynamodbBook(domain.Book{ID: bookID, ISBN: "978-0134190440"}))
+		require.NoError(t, err)
+		require.Equal(t, &types.AttributeValueMemberS{Value: "BOOK#" + bookID.String()}, item[ddb.PartitionKey])
+		require.Equal(t, &types.AttributeValueMemberS{Value: "ISBN#9780134190440"}, item[ddb.GSI1PartitionKey])
+
+		var book ddb.DynamodbBook
+		require.NoError(t, ddb.UnmarshalItem(item, &book))
+		require.Equal(t, bookID.String(), book.ID)
+
+		// Books without an ISBN are not listed in GSI1.
+		item, err = ddb.MarshalItem(ddb.ToDynamodbBook(domain.Book{ID: bookID}))
+		require.NoError(t, err)
+		require.NotContains(t, item, ddb.GSI1PartitionKey)
+	})
+
+	t.Run("FindByISBN", func(t *testing.T) {
+		ctx := context.Background()
+		book := domain.Book{ID: bookID, Title: "The Go Programming Language", ISBN: "978-0134190440"}
+		item, err := ddb.MarshalItem(ddb.ToDynamodbBook(book))
+		require.NoError(t, err)
+
+		mockClient := ddb.NewMockDynamoDBClient(t)
+		mockClient.EXPECT().Query(ctx, mock.MatchedBy(func(in *dynamodb.QueryInput) bool {
+			return *in.IndexName == ddb.GSI1
+		})).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{item}}, nil).Once()
+
+		store, err := ddb.NewStore(ctx, "test-table", ddb.WithClient(mockClient))
+		require.NoError(t, err)
+		books, err := store.FindByISBN(ctx, "0134190440")
+
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{book}, books)
+	})
+}
@@ -0,0 +1,331 @@
+package ddb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// MetricsNamespace is the CloudWatch namespace of the metrics emitted by the
+// Store.
+const MetricsNamespace = "Alessandrina"
+
+// metricsOutput receives the metrics in CloudWatch embedded metric format,
+// AWS Lambda forwards the standard output to CloudWatch Logs.
+var metricsOutput io.Writer = os.Stdout
+
+// MalformedItem is an item of the table that cannot be decoded into a book.
+type MalformedItem struct {
+	// ID is the value of the id attribute.
+	ID string
+
+	// Item is the item as stored in the table.
+	Item map[string]types.AttributeValue
+
+	// Err describes why the item cannot be decoded.
+	Err error
+}
+
+// MalformedHandler is called for every malformed item skipped by a read, op
+// is the name of the Store method, e.g. "findall".
+type MalformedHandler func(ctx context.Context, op string, item MalformedItem)
+
+// WithMalformedHandler returns a Store Option that sets the function called
+// for the malformed items skipped by the reads, in place of the default one
+// logging them and emitting the MalformedItems metric.
+func WithMalformedHandler(handler MalformedHandler) Option {
+	return func(s *Store) error {
+		s.onMalformed = handler
+
+		return nil
+	}
+}
+
+// WithQuarantine returns a Store Option that moves the malformed items found
+// by the reads into the given table, where they can be inspected without
+// being read again.
+func WithQuarantine(table string) Option {
+	return func(s *Store) error {
+		s.quarantine = table
+
+		return nil
+	}
+}
+
+// decodeItem converts an item of the table, of any schema version, into a
+// book.
+func decodeItem(item map[string]types.AttributeValue) (domain.Book, error) {
+	upgraded, _, err := upgradeItem(item)
+	if err != nil {
+		return domain.Book{}, err
+	}
+
+	return unmarshalItem(upgraded)
+}
+
+// unmarshalItem converts an item of the current schema version into a book.
+func unmarshalItem(item map[string]types.AttributeValue) (domain.Book, error) {
+	var ddbBook DynamodbBook
+
+	if err := UnmarshalItem(item, &ddbBook); err != nil {
+		return domain.Book{}, err
+	}
+
+	return ToDomainBook(ddbBook)
+}
+
+// decodeItems converts the items read by op into books, the malformed ones
+// are reported and skipped.
+func (s *Store) decodeItems(ctx context.Context, op string, items []map[string]types.AttributeValue) []domain.Book {
+	books := make([]domain.Book, 0, len(items))
+
+	for _, item := range items {
+		book, err := s.readItem(ctx, op, item)
+		if err != nil {
+			s.reportMalformed(ctx, op, newMalformedItem(item, err))
+			continue
+		}
+
+		books = append(books, book)
+	}
+
+	return books
+}
+
+// isBookItem reports whether an item stores a book, items written before the
+// kind attribute was introduced are books.
+func isBookItem(item map[string]types.AttributeValue) bool {
+	kind, ok := item["kind"].(*types.AttributeValueMemberS)
+
+	return !ok || kind.Value == BookKind
+}
+
+func newMalformedItem(item map[string]types.AttributeValue, err error) MalformedItem {
+	var id string
+	if attr, ok := item["id"].(*types.AttributeValueMemberS); ok {
+		id = attr.Value
+	}
+
+	return MalformedItem{ID: id, Item: item, Err: err}
+}
+
+// reportMalformed calls the malformed item handler and, when a quarantine
+// table is set, moves the item there. A failed move is only logged: the read
+// that found the item goes on without it.
+//
+// Items of a newer schema version are not malformed, they are only logged.
+func (s *Store) reportMalformed(ctx context.Context, op string, item MalformedItem) {
+	if errors.Is(item.Err, ErrNewerSchema) {
+		log.Printf("ddb: %s: skipping item %q: %v", op, item.ID, item.Err)
+
+		return
+	}
+
+	if s.onMalformed != nil {
+		s.onMalformed(ctx, op, item)
+	}
+
+	if s.quarantine == "" {
+		return
+	}
+
+	if err := s.quarantineItem(ctx, item); err != nil {
+		log.Printf("ddb: %s: quarantine item %q: %v", op, item.ID, err)
+	}
+}
+
+// logMalformed is the default MalformedHandler.
+func logMalformed(_ context.Context, op string, item MalformedItem) {
+	log.Printf("ddb: %s: skipping malformed item %q: %v", op, item.ID, item.Err)
+
+	emitMetric("MalformedItems", op)
+}
+
+// emitMetric writes a count of one for the named metric, with the Store
+// method as Operation dimension.
+func emitMetric(name, op string) {
+	line, err := json.Marshal(map[string]any{
+		"_aws": map[string]any{
+			"Timestamp": time.Now().UnixMilli(),
+			"CloudWatchMetrics": []map[string]any{{
+				"Namespace":  MetricsNamespace,
+				"Dimensions": [][]string{{"Operation"}},
+				"Metrics":    []map[string]string{{"Name": name, "Unit": "Count"}},
+			}},
+		},
+		"Operation": op,
+		name:        1,
+	})
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintln(metricsOutput, string(line))
+}
+
+// quarantineItem copies the item into the quarantine table, together with
+// the reason and the time of the move, and deletes it from the table.
+func (s *Store) quarantineItem(ctx context.Context, item MalformedItem) error {
+	quarantined := maps.Clone(item.Item)
+	quarantined["quarantine_reason"] = &types.AttributeValueMemberS{Value: item.Err.Error()}
+	quarantined["quarantined_at"] = &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)}
+
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.quarantine),
+		Item:      quarantined,
+	})
+	if err != nil {
+		return fmt.Errorf("ddb.quarantine putitem: %w", translateError(err, nil))
+	}
+
+	_, err = s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key:       itemKey(item.Item).AttributeValues(),
+	})
+	if err != nil {
+		return fmt.Errorf("ddb.quarantine deleteitem: %w", translateError(err, nil))
+	}
+
+	return nil
+}
+
+// RepairAction tells what Repair did with a malformed item.
+type RepairAction string
+
+const (
+	// RepairFixed means the item was rewritten as a valid book.
+	RepairFixed RepairAction = "fixed"
+
+	// RepairQuarantined means the item was moved into the quarantine table.
+	RepairQuarantined RepairAction = "quarantined"
+
+	// RepairSkipped means the item cannot be fixed and no quarantine table
+	// is set, so it was left untouched.
+	RepairSkipped RepairAction = "skipped"
+)
+
+// FindMalformed scans the whole table and returns the items that cannot be
+// decoded into books.
+func (s *Store) FindMalformed(ctx context.Context) ([]MalformedItem, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(s.table),
+	}
+
+	var malformed []MalformedItem
+
+	for {
+		response, err := s.client.Scan(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("ddb.findmalformed scan: %w", translateError(err, nil))
+		}
+
+		for _, item := range response.Items {
+			if !isBookItem(item) {
+				continue
+			}
+
+			if _, err := decodeItem(item); err != nil && !errors.Is(err, ErrNewerSchema) {
+				malformed = append(malformed, newMalformedItem(item, err))
+			}
+		}
+
+		if len(response.LastEvaluatedKey) == 0 {
+			return malformed, nil
+		}
+
+		input.ExclusiveStartKey = response.LastEvaluatedKey
+	}
+}
+
+// Repair rewrites a malformed item as a valid book when possible: IDs that
+// can be parsed are rewritten in canonical form (moving the item under the
+// key of the book) and a merged_into that cannot be parsed is dropped, turning the
+// item back into a regular book.
+//
+// Items that cannot be fixed are moved into the quarantine table, if set.
+// Items of a newer schema version are always skipped.
+func (s *Store) Repair(ctx context.Context, item MalformedItem) (RepairAction, error) {
+	book, err := decodeItem(fixItem(item.Item))
+	if err != nil {
+		if s.quarantine == "" || errors.Is(err, ErrNewerSchema) {
+			return RepairSkipped, nil
+		}
+
+		if err := s.quarantineItem(ctx, item); err != nil {
+			return "", fmt.Errorf("ddb.repair: %w", err)
+		}
+
+		return RepairQuarantined, nil
+	}
+
+	key := itemKey(item.Item)
+
+	if key == BookEntity.Key(book.ID) {
+		if err := s.Update(ctx, book); err != nil {
+			return "", fmt.Errorf("ddb.repair: %w", err)
+		}
+
+		return RepairFixed, nil
+	}
+
+	if err := s.Save(ctx, book); err != nil {
+		return "", fmt.Errorf("ddb.repair: %w", err)
+	}
+
+	_, err = s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key:       key.AttributeValues(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("ddb.repair deleteitem: %w", translateError(err, nil))
+	}
+
+	return RepairFixed, nil
+}
+
+// fixItem returns a copy of item with its IDs in canonical form, an
+// unparsable merged_into is removed.
+func fixItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	fixed := maps.Clone(item)
+
+	if id, ok := canonicalID(fixed["id"]); ok {
+		fixed["id"] = &types.AttributeValueMemberS{Value: id}
+	}
+
+	if attr, ok := fixed["merged_into"]; ok {
+		if into, ok := canonicalID(attr); ok {
+			fixed["merged_into"] = &types.AttributeValueMemberS{Value: into}
+		} else {
+			delete(fixed, "merged_into")
+		}
+	}
+
+	return fixed
+}
+
+func canonicalID(attr types.AttributeValue) (string, bool) {
+	s, ok := attr.(*types.AttributeValueMemberS)
+	if !ok {
+		return "", false
+	}
+
+	id, err := uuid.Parse(strings.TrimSpace(s.Value))
+	if err != nil {
+		return "", false
+	}
+
+	return id.String(), true
+}
@@ -2,59 +2,116 @@ package ddb
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/rotiroti/alessandrina/domain"
 )
 
+// BookKind is the value of the kind attribute of the books, it is the
+// partition key of the ByIDIndex.
+const BookKind = "book"
+
 // DynamodbBook is the struct used to store books in DynamoDB.
 type DynamodbBook struct {
-	ID        string `dynamodbav:"id"`
-	Title     string `dynamodbav:"title"`
-	Authors   string `dynamodbav:"authors"`
-	Publisher string `dynamodbav:"publisher"`
-	Pages     int    `dynamodbav:"pages"`
-	ISBN      string `dynamodbav:"isbn"`
+	ID            string   `dynamodbav:"id"`
+	SchemaVersion int      `dynamodbav:"schema_version"`
+	Kind          string   `dynamodbav:"kind"`
+	Title         string   `dynamodbav:"title"`
+	Authors       string   `dynamodbav:"authors"`
+	Publisher     string   `dynamodbav:"publisher"`
+	Pages         int      `dynamodbav:"pages"`
+	ISBN          string   `dynamodbav:"isbn"`
+	Tags          []string `dynamodbav:"tags,omitempty"`
+	MergedInto    string   `dynamodbav:"merged_into,omitempty"`
 }
 
 // String returns a string representation of a DynamodbBook.
 func (b DynamodbBook) String() string {
-	const msg = "ID: %s\nTitle: %s\nAuthors: %s\nPublisher: %s\nPages: %d\nISBN: %s\n"
+	const msg = "ID: %s\nTitle: %s\nAuthors: %s\nPublisher: %s\nPages: %d\nISBN: %s\nTags: %s\n"
+
+	return fmt.Sprintf(msg, b.ID, b.Title, b.Authors, b.Publisher, b.Pages, b.ISBN, strings.Join(b.Tags, ", "))
+}
 
-	return fmt.Sprintf(msg, b.ID, b.Title, b.Authors, b.Publisher, b.Pages, b.ISBN)
+// PrimaryKey returns the primary key of the book.
+func (b DynamodbBook) PrimaryKey() Key {
+	return BookEntity.key(b.ID)
+}
+
+// IndexKey returns the GSI1 key of the book, listing it by ISBN. Books
+// without an ISBN are not listed.
+func (b DynamodbBook) IndexKey() Key {
+	if b.ISBN == "" {
+		return Key{}
+	}
+
+	return BooksByISBN(b.ISBN).key(b.ID)
 }
 
 // ToDynamodbBook converts a domain.Book to a DynamodbBook.
 func ToDynamodbBook(book domain.Book) DynamodbBook {
-	return DynamodbBook{
-		ID:        book.ID.String(),
-		Title:     book.Title,
-		Authors:   book.Authors,
-		Publisher: book.Publisher,
-		Pages:     book.Pages,
-		ISBN:      book.ISBN,
+	ddbBook := DynamodbBook{
+		ID:            book.ID.String(),
+		SchemaVersion: SchemaVersion,
+		Kind:          BookKind,
+		Title:         book.Title,
+		Authors:       book.Authors,
+		Publisher:     book.Publisher,
+		Pages:         book.Pages,
+		ISBN:          book.ISBN,
+		Tags:          book.Tags,
+	}
+
+	if book.MergedInto != uuid.Nil {
+		ddbBook.MergedInto = book.MergedInto.String()
 	}
+
+	return ddbBook
 }
 
 // ToDomainBook converts a DynamoDBBook to a domain.Book.
-func ToDomainBook(book DynamodbBook) domain.Book {
-	return domain.Book{
-		ID:        uuid.MustParse(book.ID),
+//
+// Items are written by ToDynamodbBook, but they can also be edited by hand:
+// an ID that is not a canonical UUID is reported as ErrMalformedItem.
+func ToDomainBook(book DynamodbBook) (domain.Book, error) {
+	id, err := parseID(book.ID)
+	if err != nil {
+		return domain.Book{}, fmt.Errorf("ddb.todomainbook id: %w", err)
+	}
+
+	domainBook := domain.Book{
+		ID:        id,
 		Title:     book.Title,
 		Authors:   book.Authors,
 		Publisher: book.Publisher,
 		Pages:     book.Pages,
 		ISBN:      book.ISBN,
+		Tags:      book.Tags,
 	}
+
+	if book.MergedInto != "" {
+		into, err := parseID(book.MergedInto)
+		if err != nil {
+			return domain.Book{}, fmt.Errorf("ddb.todomainbook merged_into: %w", err)
+		}
+
+		domainBook.MergedInto = into
+	}
+
+	return domainBook, nil
 }
 
-// ToDomainBooks converts a slice of DynamoDBBook to a slice of domain.Book.
-func ToDomainBooks(books []DynamodbBook) []domain.Book {
-	domainBooks := make([]domain.Book, len(books))
+// parseID parses an ID written by ToDynamodbBook. IDs in any other form are
+// rejected too: an item keyed by them cannot be found by its book ID.
+func parseID(value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %w", ErrMalformedItem, err)
+	}
 
-	for i, book := range books {
-		domainBooks[i] = ToDomainBook(book)
+	if id.String() != value {
+		return uuid.Nil, fmt.Errorf("%w: %q is not a canonical UUID", ErrMalformedItem, value)
 	}
 
-	return domainBooks
+	return id, nil
 }
@@ -0,0 +1,348 @@
+package ddb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// SchemaVersion is the version of the items written by the Store, stored in
+// their schema_version attribute. Items written before the attribute was
+// introduced are at version 0.
+const SchemaVersion = 2
+
+// Upgrade changes in place an item of a schema version into the shape of the
+// following version.
+type Upgrade func(item map[string]types.AttributeValue) error
+
+// upgrades holds, at index N, the Upgrade from version N to version N+1.
+//
+// NOTE: a model change that alters the stored items must bump SchemaVersion
+// and add its Upgrade here, so that older items keep being readable.
+var upgrades = [SchemaVersion]Upgrade{
+	// Version 1 adds the kind attribute, the partition key of ByIDIndex.
+	0: func(item map[string]types.AttributeValue) error {
+		if _, ok := item["kind"]; !ok {
+			item["kind"] = &types.AttributeValueMemberS{Value: BookKind}
+		}
+
+		return nil
+	},
+
+	// Version 2 moves the books into the single-table layout, adding the
+	// keys of the item and of GSI1 (see the documentation of PartitionKey).
+	1: func(item map[string]types.AttributeValue) error {
+		var book DynamodbBook
+
+		if err := attributevalue.UnmarshalMap(item, &book); err != nil {
+			return err
+		}
+
+		setKeys(item, book.PrimaryKey(), book.IndexKey())
+
+		return nil
+	},
+}
+
+// WithWriteBack returns a Store Option that writes back the items upgraded
+// on read, so that they are upgraded only once.
+func WithWriteBack() Option {
+	return func(s *Store) error {
+		s.writeBack = true
+
+		return nil
+	}
+}
+
+// WithLegacyTable returns a Store Option that makes FindOne look up the books
+// it does not find in the given table, one with the layout used before the
+// single-table design (keyed by id). It covers the books written there by
+// the previous deploy until they are copied, see Migrate.
+//
+// With WithWriteBack, a book found there is copied into the table of the
+// Store.
+func WithLegacyTable(table string) Option {
+	return func(s *Store) error {
+		if table == "" {
+			return errors.New("ddb.withlegacytable: empty table name")
+		}
+
+		s.legacy = table
+
+		return nil
+	}
+}
+
+// findLegacy reads a book from the legacy table, see WithLegacyTable.
+func (s *Store) findLegacy(ctx context.Context, bookID uuid.UUID) (domain.Book, error) {
+	response, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.legacy),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: bookID.String()},
+		},
+	})
+	if err != nil {
+		return domain.Book{}, fmt.Errorf("ddb.findone legacy getitem: %w", translateError(err, nil))
+	}
+
+	if len(response.Item) == 0 {
+		return domain.Book{}, fmt.Errorf("ddb.findone legacy getitem: %w", domain.ErrNotFound)
+	}
+
+	// The legacy table is not quarantined nor repaired, a malformed item is
+	// only reported to the caller.
+	upgraded, _, err := upgradeItem(response.Item)
+	if err != nil {
+		return domain.Book{}, fmt.Errorf("ddb.findone legacy: %w", err)
+	}
+
+	book, err := unmarshalItem(upgraded)
+	if err != nil {
+		return domain.Book{}, fmt.Errorf("ddb.findone legacy: %w", err)
+	}
+
+	if s.writeBack {
+		err := s.putCopied(ctx, upgraded)
+		if err != nil && !errors.Is(err, domain.ErrPreconditionFailed) {
+			log.Printf("ddb: findone: copy legacy item %q: %v", book.ID, err)
+		}
+	}
+
+	return book, nil
+}
+
+// itemVersion returns the schema version of an item.
+func itemVersion(item map[string]types.AttributeValue) (int, error) {
+	attr, ok := item["schema_version"]
+	if !ok {
+		return 0, nil
+	}
+
+	n, ok := attr.(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("%w: schema_version is not a number", ErrMalformedItem)
+	}
+
+	version, err := strconv.Atoi(n.Value)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("%w: invalid schema_version %q", ErrMalformedItem, n.Value)
+	}
+
+	return version, nil
+}
+
+// upgradeItem returns the item upgraded to SchemaVersion together with its
+// original version, the item is returned as is when already up to date.
+func upgradeItem(item map[string]types.AttributeValue) (map[string]types.AttributeValue, int, error) {
+	version, err := itemVersion(item)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ddb.upgradeitem: %w", err)
+	}
+
+	if version > SchemaVersion {
+		return nil, 0, fmt.Errorf("ddb.upgradeitem: %w: schema_version %d is newer than %d", ErrNewerSchema, version, SchemaVersion)
+	}
+
+	if version == SchemaVersion {
+		return item, version, nil
+	}
+
+	upgraded := maps.Clone(item)
+
+	for v := version; v < SchemaVersion; v++ {
+		if err := upgrades[v](upgraded); err != nil {
+			return nil, 0, fmt.Errorf("ddb.upgradeitem v%d: %w: %w", v, ErrMalformedItem, err)
+		}
+	}
+
+	upgraded["schema_version"] = &types.AttributeValueMemberN{Value: strconv.Itoa(SchemaVersion)}
+
+	return upgraded, version, nil
+}
+
+// readItem upgrades an item read by op and converts it into a book. The
+// upgraded item is written back when enabled, a failed write is only logged.
+func (s *Store) readItem(ctx context.Context, op string, item map[string]types.AttributeValue) (domain.Book, error) {
+	upgraded, version, err := upgradeItem(item)
+	if err != nil {
+		return domain.Book{}, err
+	}
+
+	book, err := unmarshalItem(upgraded)
+	if err != nil {
+		return domain.Book{}, err
+	}
+
+	if s.writeBack && version < SchemaVersion {
+		err := s.putUpgraded(ctx, upgraded, version)
+		if err != nil && !errors.Is(err, domain.ErrPreconditionFailed) {
+			log.Printf("ddb: %s: write back item %q: %v", op, book.ID, err)
+		}
+	}
+
+	return book, nil
+}
+
+// putUpgraded writes an upgraded item, provided the stored one still exists
+// and is still at version. ErrPreconditionFailed is returned otherwise, e.g.
+// when the book was saved or deleted in the meantime.
+func (s *Store) putUpgraded(ctx context.Context, item map[string]types.AttributeValue, version int) error {
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(pk) AND schema_version = :version"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.Itoa(version)},
+		},
+	}
+
+	if version == 0 {
+		input.ConditionExpression = aws.String("attribute_exists(pk) AND attribute_not_exists(schema_version)")
+		input.ExpressionAttributeValues = nil
+	}
+
+	if _, err := s.client.PutItem(ctx, input); err != nil {
+		return fmt.Errorf("ddb.putupgraded putitem: %w", translateError(err, nil))
+	}
+
+	return nil
+}
+
+// putCopied writes an item copied from another table, provided the table
+// does not hold it yet. ErrPreconditionFailed is returned otherwise.
+func (s *Store) putCopied(ctx context.Context, item map[string]types.AttributeValue) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	if err != nil {
+		return fmt.Errorf("ddb.putcopied putitem: %w", translateError(err, nil))
+	}
+
+	return nil
+}
+
+// MigrationProgress reports how far a migration went.
+type MigrationProgress struct {
+	// Scanned is the number of items read so far.
+	Scanned int
+
+	// Upgraded is the number of items written at SchemaVersion so far.
+	Upgraded int
+
+	// Failed is the number of items that could not be upgraded, they are
+	// malformed and can be handled with Repair.
+	Failed int
+
+	// LastKey is the key of the last item read, a migration started after it
+	// resumes from here.
+	LastKey map[string]string
+}
+
+// Migrate upgrades to SchemaVersion all the books of the table that are
+// older, scanning the table from the item following startAfter (from the
+// beginning when nil). The progress function, if not nil, is called after
+// every page of the scan; returning an error stops the migration.
+//
+// When source is set, the books are read from that table instead, e.g. a
+// table with the layout used before the single-table design, and copied into
+// the table of the Store.
+//
+// Books written concurrently by the application are left untouched, they
+// are already at SchemaVersion.
+func (s *Store) Migrate(ctx context.Context, source string, startAfter map[string]string, progress func(MigrationProgress) error) (MigrationProgress, error) {
+	if source == "" {
+		source = s.table
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(source),
+	}
+
+	if len(startAfter) > 0 {
+		input.ExclusiveStartKey = make(map[string]types.AttributeValue, len(startAfter))
+		for name, value := range startAfter {
+			input.ExclusiveStartKey[name] = &types.AttributeValueMemberS{Value: value}
+		}
+	}
+
+	state := MigrationProgress{LastKey: startAfter}
+
+	for {
+		response, err := s.client.Scan(ctx, input)
+		if err != nil {
+			return state, fmt.Errorf("ddb.migrate scan: %w", translateError(err, nil))
+		}
+
+		for _, item := range response.Items {
+			state.Scanned++
+
+			if !isBookItem(item) {
+				continue
+			}
+
+			upgraded, version, err := upgradeItem(item)
+			if errors.Is(err, ErrNewerSchema) {
+				// Written by a newer deploy, there is nothing to upgrade.
+				continue
+			}
+
+			if err == nil {
+				_, err = unmarshalItem(upgraded)
+			}
+
+			if err != nil {
+				state.Failed++
+				continue
+			}
+
+			switch {
+			case source != s.table:
+				err = s.putCopied(ctx, upgraded)
+			case version < SchemaVersion:
+				err = s.putUpgraded(ctx, upgraded, version)
+			default:
+				continue
+			}
+
+			switch {
+			case err == nil:
+				state.Upgraded++
+			case !errors.Is(err, domain.ErrPreconditionFailed):
+				return state, fmt.Errorf("ddb.migrate: %w", err)
+			}
+		}
+
+		if len(response.LastEvaluatedKey) > 0 {
+			state.LastKey = make(map[string]string, len(response.LastEvaluatedKey))
+			for name, attr := range response.LastEvaluatedKey {
+				if value, ok := attr.(*types.AttributeValueMemberS); ok {
+					state.LastKey[name] = value.Value
+				}
+			}
+		}
+
+		if progress != nil {
+			if err := progress(state); err != nil {
+				return state, fmt.Errorf("ddb.migrate: %w", err)
+			}
+		}
+
+		if len(response.LastEvaluatedKey) == 0 {
+			return state, nil
+		}
+
+		input.ExclusiveStartKey = response.LastEvaluatedKey
+	}
+}
@@ -1,9 +1,22 @@
+// Package memory implements domain.Storer in memory, for the tests, local
+// runs and demos.
+//
+// A Store returned by NewStore starts empty and is lost with the process. A
+// Store returned by Open can keep its books in a snapshot file, a JSON
+// document in the format of tests/performance/books.json, together with a
+// write-ahead log of the operations applied since the snapshot was written.
+// Every operation is appended to the log before it is applied, so a crash
+// loses at most the operation being written.
 package memory
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"os"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rotiroti/alessandrina/domain"
@@ -13,12 +26,23 @@ import (
 type Store struct {
 	container map[string]domain.Book
 	mu        sync.RWMutex
+
+	snapshotPath     string
+	snapshotInterval time.Duration
+	logPath          string
+	seedPath         string
+
+	log     *os.File
+	logSize int64
+	done    chan struct{}
+	wg      sync.WaitGroup
 }
 
 // Ensure Store implements the Storer interface.
 var _ domain.Storer = (*Store)(nil)
 
-// NewStore returns a new instance of Store.
+// NewStore returns a new instance of Store, empty and not persisted, see
+// Open.
 func NewStore() *Store {
 	return &Store{
 		container: make(map[string]domain.Book),
@@ -34,11 +58,54 @@ func (s *Store) Save(_ context.Context, book domain.Book) error {
 		return fmt.Errorf("memory.save: %w", domain.ErrAlreadyExists)
 	}
 
+	if err := s.appendLog(putEntry(book)); err != nil {
+		return fmt.Errorf("memory.save: %w", err)
+	}
+
 	s.container[book.ID.String()] = book
 
 	return nil
 }
 
+// SaveMany adds a batch of new books into the in-memory database.
+//
+// Books whose ID already exists are reported in a domain.BatchError, the
+// others are saved.
+func (s *Store) SaveMany(_ context.Context, books []domain.Book) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failed := make(map[uuid.UUID]error)
+	saved := make([]domain.Book, 0, len(books))
+	batch := make(map[uuid.UUID]bool, len(books))
+
+	for _, book := range books {
+		if _, exists := s.container[book.ID.String()]; exists || batch[book.ID] {
+			failed[book.ID] = fmt.Errorf("memory.savemany: %w", domain.ErrAlreadyExists)
+			continue
+		}
+
+		saved = append(saved, book)
+		batch[book.ID] = true
+	}
+
+	if len(saved) > 0 {
+		if err := s.appendLog(putEntry(saved...)); err != nil {
+			return fmt.Errorf("memory.savemany: %w", err)
+		}
+	}
+
+	for _, book := range saved {
+		s.container[book.ID.String()] = book
+	}
+
+	if len(failed) > 0 {
+		return &domain.BatchError{Failed: failed}
+	}
+
+	return nil
+}
+
 // FindAll returns all books from the in-memory database.
 func (s *Store) FindAll(_ context.Context) ([]domain.Book, error) {
 	s.mu.RLock()
@@ -52,6 +119,42 @@ func (s *Store) FindAll(_ context.Context) ([]domain.Book, error) {
 	return books, nil
 }
 
+// FindMany returns the books matching the given IDs from the in-memory database.
+// IDs without a matching book are ignored.
+func (s *Store) FindMany(_ context.Context, bookIDs []uuid.UUID) ([]domain.Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	books := make([]domain.Book, 0, len(bookIDs))
+	for _, bookID := range bookIDs {
+		if book, exists := s.container[bookID.String()]; exists {
+			books = append(books, book)
+		}
+	}
+
+	return books, nil
+}
+
+// FindPage returns up to limit books with an ID greater than after, ordered
+// by ID, from the in-memory database.
+func (s *Store) FindPage(_ context.Context, after uuid.UUID, limit int) ([]domain.Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	books := make([]domain.Book, 0, len(s.container))
+	for _, book := range s.container {
+		if bytes.Compare(book.ID[:], after[:]) > 0 {
+			books = append(books, book)
+		}
+	}
+
+	sort.Slice(books, func(i, j int) bool {
+		return bytes.Compare(books[i].ID[:], books[j].ID[:]) < 0
+	})
+
+	return books[:min(limit, len(books))], nil
+}
+
 // FindOne returns a book from the in-memory database.
 func (s *Store) FindOne(_ context.Context, bookID uuid.UUID) (domain.Book, error) {
 	s.mu.RLock()
@@ -65,11 +168,61 @@ func (s *Store) FindOne(_ context.Context, bookID uuid.UUID) (domain.Book, error
 	return book, nil
 }
 
+// Update replaces an existing book in the in-memory database.
+func (s *Store) Update(_ context.Context, book domain.Book) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.container[book.ID.String()]; !exists {
+		return fmt.Errorf("memory.update: %w", domain.ErrNotFound)
+	}
+
+	if err := s.appendLog(putEntry(book)); err != nil {
+		return fmt.Errorf("memory.update: %w", err)
+	}
+
+	s.container[book.ID.String()] = book
+
+	return nil
+}
+
+// CompareAndSwap replaces the previous books with books in the in-memory
+// database, provided none of them changed since it was read.
+func (s *Store) CompareAndSwap(_ context.Context, previous, books []domain.Book) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, book := range previous {
+		stored, exists := s.container[book.ID.String()]
+		if !exists || !stored.Equal(book) {
+			return fmt.Errorf("memory.compareandswap: %w", domain.ErrPreconditionFailed)
+		}
+	}
+
+	if err := s.appendLog(putEntry(books...)); err != nil {
+		return fmt.Errorf("memory.compareandswap: %w", err)
+	}
+
+	for _, book := range books {
+		s.container[book.ID.String()] = book
+	}
+
+	return nil
+}
+
 // Delete removes a book from the in-memory database.
 func (s *Store) Delete(_ context.Context, bookID uuid.UUID) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if _, exists := s.container[bookID.String()]; !exists {
+		return nil
+	}
+
+	if err := s.appendLog(deleteEntry(bookID)); err != nil {
+		return fmt.Errorf("memory.delete: %w", err)
+	}
+
 	delete(s.container, bookID.String())
 
 	return nil
@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/domain/storertest"
 	"github.com/rotiroti/alessandrina/sys/database/memory"
 	"github.com/stretchr/testify/require"
 )
@@ -37,6 +38,26 @@ func TestMemoryStore(t *testing.T) {
 		require.ErrorIs(t, err2, domain.ErrAlreadyExists)
 	})
 
+	t.Run("should save a batch of books", func(t *testing.T) {
+		t.Parallel()
+		store := memory.NewStore()
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+
+		newBook := book
+		newBook.ID = uuid.New()
+		err2 := store.SaveMany(context.Background(), []domain.Book{newBook, book})
+
+		var batchErr *domain.BatchError
+		require.ErrorAs(t, err2, &batchErr)
+		require.Len(t, batchErr.Failed, 1)
+		require.ErrorIs(t, batchErr.Failed[book.ID], domain.ErrAlreadyExists)
+
+		ret, err3 := store.FindOne(context.Background(), newBook.ID)
+		require.NoError(t, err3)
+		require.Equal(t, newBook, ret)
+	})
+
 	t.Run("should return a book by ID", func(t *testing.T) {
 		t.Parallel()
 		store := memory.NewStore()
@@ -47,6 +68,16 @@ func TestMemoryStore(t *testing.T) {
 		require.Equal(t, book, ret)
 	})
 
+	t.Run("should return the books matching the given IDs", func(t *testing.T) {
+		t.Parallel()
+		store := memory.NewStore()
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+		ret, err2 := store.FindMany(context.Background(), []uuid.UUID{uuid.New(), book.ID})
+		require.NoError(t, err2)
+		require.Equal(t, []domain.Book{book}, ret)
+	})
+
 	t.Run("should throw error for unfound book ID", func(t *testing.T) {
 		t.Parallel()
 		store := memory.NewStore()
@@ -54,6 +85,49 @@ func TestMemoryStore(t *testing.T) {
 		require.ErrorIs(t, err, domain.ErrNotFound)
 	})
 
+	t.Run("should update an existing book", func(t *testing.T) {
+		t.Parallel()
+		store := memory.NewStore()
+		err := store.Save(context.Background(), book)
+		require.NoError(t, err)
+
+		updated := book
+		updated.MergedInto = uuid.New()
+		err = store.Update(context.Background(), updated)
+		require.NoError(t, err)
+
+		ret, err := store.FindOne(context.Background(), book.ID)
+		require.NoError(t, err)
+		require.Equal(t, updated, ret)
+	})
+
+	t.Run("should not update a non existing book", func(t *testing.T) {
+		t.Parallel()
+		store := memory.NewStore()
+		err := store.Update(context.Background(), book)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("should return the books ordered by ID one page at a time", func(t *testing.T) {
+		t.Parallel()
+		store := memory.NewStore()
+		ids := make([]uuid.UUID, 5)
+
+		for i := range ids {
+			ids[i] = domain.TimeOrderedID(domain.NewBook{})
+			err := store.Save(context.Background(), domain.Book{ID: ids[i]})
+			require.NoError(t, err)
+		}
+
+		first, err := store.FindPage(context.Background(), uuid.Nil, 3)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{{ID: ids[0]}, {ID: ids[1]}, {ID: ids[2]}}, first)
+
+		second, err := store.FindPage(context.Background(), ids[2], 3)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{{ID: ids[3]}, {ID: ids[4]}}, second)
+	})
+
 	t.Run("should delete an existing book", func(t *testing.T) {
 		t.Parallel()
 		store := memory.NewStore()
@@ -91,3 +165,9 @@ func TestMemoryStore(t *testing.T) {
 		require.Len(t, ret, 10)
 	})
 }
+
+func TestConformance(t *testing.T) {
+	storertest.Run(t, func(*testing.T) domain.Storer {
+		return memory.NewStore()
+	})
+}
@@ -0,0 +1,577 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// ErrMissingSnapshot is returned when an Option needs the snapshot file and
+// WithSnapshot is not given.
+var ErrMissingSnapshot = errors.New("missing snapshot path")
+
+// Operations of the write-ahead log.
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// Option is a function that configures a Store.
+type Option func(*Store) error
+
+// WithSnapshot returns a Store Option that loads the books from the snapshot
+// file at path, when it exists, and writes them back to it on Checkpoint and
+// Close.
+func WithSnapshot(path string) Option {
+	return func(s *Store) error {
+		if path == "" {
+			return fmt.Errorf("memory.withsnapshot: %w", ErrMissingSnapshot)
+		}
+
+		s.snapshotPath = path
+
+		return nil
+	}
+}
+
+// WithAutoSnapshot returns a Store Option that writes the snapshot file every
+// interval, see Checkpoint. A failed snapshot is tried again at the next
+// interval, the log still holding the operations. It requires WithSnapshot.
+func WithAutoSnapshot(interval time.Duration) Option {
+	return func(s *Store) error {
+		if interval <= 0 {
+			return fmt.Errorf("memory.withautosnapshot: invalid interval %s", interval)
+		}
+
+		s.snapshotInterval = interval
+
+		return nil
+	}
+}
+
+// WithLog returns a Store Option that appends every write to the log file at
+// path, created when missing, before applying it. Open replays the log on
+// top of the snapshot and Checkpoint empties it. It requires WithSnapshot.
+func WithLog(path string) Option {
+	return func(s *Store) error {
+		if path == "" {
+			return errors.New("memory.withlog: missing log path")
+		}
+
+		s.logPath = path
+
+		return nil
+	}
+}
+
+// WithSeed returns a Store Option that loads the books of the fixture file at
+// path, e.g. tests/performance/books.json, when the Store is empty once
+// opened. The books without an ID get the one derived from their ISBN (see
+// domain.ISBNID), so that seeding again gives the same IDs.
+func WithSeed(path string) Option {
+	return func(s *Store) error {
+		if path == "" {
+			return errors.New("memory.withseed: missing seed path")
+		}
+
+		s.seedPath = path
+
+		return nil
+	}
+}
+
+// Open returns a Store configured by the options: its books are loaded from
+// the snapshot and the log, or from the seed when there are none. The Store
+// must be closed to write the final snapshot and release the log.
+func Open(opts ...Option) (*Store, error) {
+	store := NewStore()
+
+	for _, opt := range opts {
+		if err := opt(store); err != nil {
+			return nil, fmt.Errorf("memory.open option: %w", err)
+		}
+	}
+
+	if store.snapshotPath == "" && (store.logPath != "" || store.snapshotInterval > 0) {
+		return nil, fmt.Errorf("memory.open: %w", ErrMissingSnapshot)
+	}
+
+	if store.snapshotPath != "" {
+		if err := store.loadFile(store.snapshotPath, false); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("memory.open snapshot: %w", err)
+		}
+	}
+
+	if store.logPath != "" {
+		if err := store.openLog(); err != nil {
+			return nil, fmt.Errorf("memory.open log: %w", err)
+		}
+	}
+
+	if store.seedPath != "" && len(store.container) == 0 {
+		if err := store.seed(); err != nil {
+			store.closeLog()
+
+			return nil, fmt.Errorf("memory.open seed: %w", err)
+		}
+	}
+
+	if store.snapshotInterval > 0 {
+		store.done = make(chan struct{})
+		store.wg.Add(1)
+
+		go store.autoSnapshot()
+	}
+
+	return store, nil
+}
+
+// Close stops the periodic snapshots, writes the snapshot file and closes the
+// log, the Store must not be used afterwards. Closing a Store without a
+// snapshot file does nothing.
+func (s *Store) Close() error {
+	if s.done != nil {
+		close(s.done)
+		s.wg.Wait()
+		s.done = nil
+	}
+
+	if s.snapshotPath == "" {
+		return nil
+	}
+
+	if err := s.Checkpoint(); err != nil {
+		return fmt.Errorf("memory.close: %w", err)
+	}
+
+	if err := s.closeLog(); err != nil {
+		return fmt.Errorf("memory.close: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) autoSnapshot() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.Checkpoint() //nolint:errcheck
+		}
+	}
+}
+
+// record is the document of a book in the snapshots, the log and the seed
+// fixtures.
+type record struct {
+	ID         string   `json:"id,omitempty"`
+	Title      string   `json:"title"`
+	Authors    string   `json:"authors,omitempty"`
+	Publisher  string   `json:"publisher,omitempty"`
+	Pages      int      `json:"pages,omitempty"`
+	ISBN       string   `json:"isbn,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	MergedInto string   `json:"merged_into,omitempty"`
+}
+
+// snapshot is the document of a snapshot, and of a seed fixture.
+type snapshot struct {
+	Books []record `json:"books"`
+}
+
+// entry is a line of the write-ahead log.
+type entry struct {
+	Op    string   `json:"op"`
+	Books []record `json:"books,omitempty"`
+	ID    string   `json:"id,omitempty"`
+}
+
+func putEntry(books ...domain.Book) entry {
+	records := make([]record, len(books))
+	for i, book := range books {
+		records[i] = encodeBook(book)
+	}
+
+	return entry{Op: opPut, Books: records}
+}
+
+func deleteEntry(bookID uuid.UUID) entry {
+	return entry{Op: opDelete, ID: bookID.String()}
+}
+
+func encodeBook(book domain.Book) record {
+	r := record{
+		ID:        book.ID.String(),
+		Title:     book.Title,
+		Authors:   book.Authors,
+		Publisher: book.Publisher,
+		Pages:     book.Pages,
+		ISBN:      book.ISBN,
+		Tags:      book.Tags,
+	}
+
+	if book.MergedInto != uuid.Nil {
+		r.MergedInto = book.MergedInto.String()
+	}
+
+	return r
+}
+
+// decodeBook returns the book of a record. A record without an ID gets the
+// one derived from its ISBN when generateID is set, otherwise it is invalid.
+func decodeBook(r record, generateID bool) (domain.Book, error) {
+	book := domain.Book{
+		Title:     r.Title,
+		Authors:   r.Authors,
+		Publisher: r.Publisher,
+		Pages:     r.Pages,
+		ISBN:      r.ISBN,
+		Tags:      r.Tags,
+	}
+
+	var err error
+
+	switch {
+	case r.ID != "":
+		if book.ID, err = uuid.Parse(r.ID); err != nil {
+			return domain.Book{}, fmt.Errorf("invalid id %q: %w", r.ID, err)
+		}
+	case generateID:
+		book.ID = domain.ISBNID(domain.NewBook{Title: r.Title, ISBN: r.ISBN})
+	default:
+		return domain.Book{}, fmt.Errorf("missing id of %q", r.Title)
+	}
+
+	if r.MergedInto != "" {
+		if book.MergedInto, err = uuid.Parse(r.MergedInto); err != nil {
+			return domain.Book{}, fmt.Errorf("invalid merged_into of %s: %w", book.ID, err)
+		}
+	}
+
+	return book, nil
+}
+
+// readBooks decodes the books of a snapshot or of a seed fixture, rejecting
+// the duplicated IDs.
+func readBooks(r io.Reader, generateID bool) ([]domain.Book, error) {
+	var doc snapshot
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	books := make([]domain.Book, len(doc.Books))
+	seen := make(map[uuid.UUID]bool, len(doc.Books))
+
+	for i, r := range doc.Books {
+		book, err := decodeBook(r, generateID)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[book.ID] {
+			return nil, fmt.Errorf("duplicate book %s", book.ID)
+		}
+
+		seen[book.ID] = true
+		books[i] = book
+	}
+
+	return books, nil
+}
+
+// Snapshot writes all the books, ordered by ID, to w as a JSON document that
+// Load and WithSnapshot read back.
+func (s *Store) Snapshot(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.writeSnapshot(w); err != nil {
+		return fmt.Errorf("memory.snapshot: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) writeSnapshot(w io.Writer) error {
+	doc := snapshot{Books: make([]record, 0, len(s.container))}
+	for _, book := range s.container {
+		doc.Books = append(doc.Books, encodeBook(book))
+	}
+
+	sort.Slice(doc.Books, func(i, j int) bool {
+		return doc.Books[i].ID < doc.Books[j].ID
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(doc)
+}
+
+// Load replaces all the books with the ones of a snapshot written by
+// Snapshot. With a snapshot file, the snapshot file is written at once, see
+// Checkpoint.
+func (s *Store) Load(r io.Reader) error {
+	books, err := readBooks(r, false)
+	if err != nil {
+		return fmt.Errorf("memory.load: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.container = make(map[string]domain.Book, len(books))
+	for _, book := range books {
+		s.container[book.ID.String()] = book
+	}
+
+	if s.snapshotPath == "" {
+		return nil
+	}
+
+	if err := s.checkpoint(); err != nil {
+		return fmt.Errorf("memory.load: %w", err)
+	}
+
+	return nil
+}
+
+// Checkpoint writes all the books to the snapshot file given to WithSnapshot
+// and empties the log, whose operations the snapshot now holds. The snapshot
+// is written next to the file and renamed, so the file never holds a partial
+// snapshot.
+func (s *Store) Checkpoint() error {
+	if s.snapshotPath == "" {
+		return fmt.Errorf("memory.checkpoint: %w", ErrMissingSnapshot)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkpoint(); err != nil {
+		return fmt.Errorf("memory.checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) checkpoint() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.snapshotPath), filepath.Base(s.snapshotPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := s.writeSnapshot(tmp); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), s.snapshotPath); err != nil {
+		return err
+	}
+
+	// A crash before the log is emptied replays operations already in the
+	// snapshot, which gives the same books.
+	if s.log == nil {
+		return nil
+	}
+
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+
+	s.logSize = 0
+
+	return nil
+}
+
+// loadFile adds the books of the snapshot, or seed fixture, at path.
+func (s *Store) loadFile(path string, generateID bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	books, err := readBooks(bufio.NewReader(f), generateID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, book := range books {
+		s.container[book.ID.String()] = book
+	}
+
+	return nil
+}
+
+// seed saves the books of the seed fixture, through the log.
+func (s *Store) seed() error {
+	f, err := os.Open(s.seedPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	books, err := readBooks(bufio.NewReader(f), true)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.seedPath, err)
+	}
+
+	if len(books) == 0 {
+		return nil
+	}
+
+	if err := s.appendLog(putEntry(books...)); err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		s.container[book.ID.String()] = book
+	}
+
+	return nil
+}
+
+// openLog replays the log on top of the snapshot and opens it for appending.
+// A last line without its newline is an operation whose write was
+// interrupted, it is dropped.
+func (s *Store) openLog() error {
+	f, err := os.OpenFile(s.logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+
+	r := bufio.NewReader(f)
+
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			f.Close()
+
+			return err
+		}
+
+		if err := s.replay(data); err != nil {
+			f.Close()
+
+			return fmt.Errorf("%s:%d: %w", s.logPath, line, err)
+		}
+
+		size += int64(len(data))
+	}
+
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+
+		return err
+	}
+
+	s.log, s.logSize = f, size
+
+	return nil
+}
+
+// replay applies an operation of the log. Operations are idempotent: a put
+// replaces the book, a delete of a missing book does nothing.
+func (s *Store) replay(data []byte) error {
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+
+	switch e.Op {
+	case opPut:
+		for _, r := range e.Books {
+			book, err := decodeBook(r, false)
+			if err != nil {
+				return err
+			}
+
+			s.container[book.ID.String()] = book
+		}
+	case opDelete:
+		bookID, err := uuid.Parse(e.ID)
+		if err != nil {
+			return fmt.Errorf("invalid id %q: %w", e.ID, err)
+		}
+
+		delete(s.container, bookID.String())
+	default:
+		return fmt.Errorf("unknown operation %q", e.Op)
+	}
+
+	return nil
+}
+
+// appendLog writes an operation to the log, if any, and waits for it to
+// reach the disk. A failed write is cut off the log, so that the next one
+// starts on a new line.
+func (s *Store) appendLog(e entry) error {
+	if s.log == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	if _, err := s.log.Write(data); err != nil {
+		return errors.Join(err, s.log.Truncate(s.logSize))
+	}
+
+	if err := s.log.Sync(); err != nil {
+		return errors.Join(err, s.log.Truncate(s.logSize))
+	}
+
+	s.logSize += int64(len(data))
+
+	return nil
+}
+
+func (s *Store) closeLog() error {
+	if s.log == nil {
+		return nil
+	}
+
+	err := s.log.Close()
+	s.log = nil
+
+	return err
+}
@@ -0,0 +1,246 @@
+package memory_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/domain/storertest"
+	"github.com/rotiroti/alessandrina/sys/database/memory"
+	"github.com/stretchr/testify/require"
+)
+
+const fixturesPath = "../../../tests/performance/books.json"
+
+// persistentOptions returns the options of a Store persisted in a temporary
+// directory, with the paths of its snapshot and log.
+func persistentOptions(t *testing.T) ([]memory.Option, string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "catalog.json")
+	logPath := filepath.Join(dir, "catalog.log")
+
+	return []memory.Option{memory.WithSnapshot(snapshotPath), memory.WithLog(logPath)}, snapshotPath, logPath
+}
+
+func newBook(title string) domain.Book {
+	return domain.Book{
+		ID:        uuid.New(),
+		Title:     title,
+		Authors:   "Jon Bodner",
+		Publisher: "O'Reilly Media",
+		Pages:     375,
+		ISBN:      "9781492077213",
+		Tags:      []string{"go"},
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("should restore the books of the snapshot written on close", func(t *testing.T) {
+		t.Parallel()
+		opts, snapshotPath, _ := persistentOptions(t)
+
+		store, err := memory.Open(opts...)
+		require.NoError(t, err)
+
+		kept, updated, deleted := newBook("Learning Go"), newBook("Go in Action"), newBook("Go Web Programming")
+		updated.MergedInto = kept.ID
+		require.NoError(t, store.SaveMany(ctx, []domain.Book{kept, updated, deleted}))
+		updated.Title = "Go in Action, Second Edition"
+		require.NoError(t, store.Update(ctx, updated))
+		require.NoError(t, store.Delete(ctx, deleted.ID))
+		require.NoError(t, store.Close())
+
+		store, err = memory.Open(memory.WithSnapshot(snapshotPath))
+		require.NoError(t, err)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []domain.Book{kept, updated}, books)
+	})
+
+	t.Run("should replay the log of a store that was not closed", func(t *testing.T) {
+		t.Parallel()
+		opts, _, logPath := persistentOptions(t)
+
+		crashed, err := memory.Open(opts...)
+		require.NoError(t, err)
+
+		book := newBook("Learning Go")
+		require.NoError(t, crashed.Save(ctx, book))
+		require.NoError(t, crashed.Checkpoint())
+
+		other := newBook("Go in Action")
+		require.NoError(t, crashed.Save(ctx, other))
+		require.NoError(t, crashed.Delete(ctx, book.ID))
+
+		data, err := os.ReadFile(logPath)
+		require.NoError(t, err)
+		require.Equal(t, 2, bytes.Count(data, []byte("\n")), "the log holds the operations after the checkpoint")
+
+		store, err := memory.Open(opts...)
+		require.NoError(t, err)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{other}, books)
+	})
+
+	t.Run("should drop an interrupted operation at the end of the log", func(t *testing.T) {
+		t.Parallel()
+		opts, _, logPath := persistentOptions(t)
+
+		crashed, err := memory.Open(opts...)
+		require.NoError(t, err)
+
+		book := newBook("Learning Go")
+		require.NoError(t, crashed.Save(ctx, book))
+
+		f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"op":"put","books":[{"id":"`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		store, err := memory.Open(opts...)
+		require.NoError(t, err)
+
+		// The next operation is appended after the last complete one.
+		other := newBook("Go in Action")
+		require.NoError(t, store.Save(ctx, other))
+
+		store, err = memory.Open(opts...)
+		require.NoError(t, err)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []domain.Book{book, other}, books)
+	})
+
+	t.Run("should fail on a corrupt operation in the log", func(t *testing.T) {
+		t.Parallel()
+		opts, _, logPath := persistentOptions(t)
+
+		require.NoError(t, os.WriteFile(logPath, []byte("{\"op\":\"move\"}\n"), 0o600))
+
+		_, err := memory.Open(opts...)
+		require.ErrorContains(t, err, `catalog.log:1: unknown operation "move"`)
+	})
+
+	t.Run("should write the snapshot periodically", func(t *testing.T) {
+		t.Parallel()
+		opts, snapshotPath, _ := persistentOptions(t)
+
+		store, err := memory.Open(append(opts, memory.WithAutoSnapshot(10*time.Millisecond))...)
+		require.NoError(t, err)
+
+		book := newBook("Learning Go")
+		require.NoError(t, store.Save(ctx, book))
+
+		require.Eventually(t, func() bool {
+			data, err := os.ReadFile(snapshotPath)
+
+			return err == nil && bytes.Contains(data, []byte(book.ID.String()))
+		}, time.Second, 10*time.Millisecond)
+		require.NoError(t, store.Close())
+	})
+
+	t.Run("should load the books of a snapshot", func(t *testing.T) {
+		t.Parallel()
+		store := memory.NewStore()
+		books := []domain.Book{newBook("Learning Go"), newBook("Go in Action")}
+		require.NoError(t, store.SaveMany(ctx, books))
+
+		var buf bytes.Buffer
+		require.NoError(t, store.Snapshot(&buf))
+
+		opts, snapshotPath, _ := persistentOptions(t)
+		other, err := memory.Open(opts...)
+		require.NoError(t, err)
+		require.NoError(t, other.Save(ctx, newBook("Replaced")))
+		require.NoError(t, other.Load(bytes.NewReader(buf.Bytes())))
+
+		ret, err := other.FindAll(ctx)
+		require.NoError(t, err)
+		require.ElementsMatch(t, books, ret)
+
+		data, err := os.ReadFile(snapshotPath)
+		require.NoError(t, err)
+		require.Equal(t, buf.String(), string(data), "Load writes the snapshot file")
+
+		err = other.Load(bytes.NewReader([]byte(`{"books":[{"title":"Without ID"}]}`)))
+		require.ErrorContains(t, err, `missing id of "Without ID"`)
+	})
+
+	t.Run("should seed an empty store from the fixtures", func(t *testing.T) {
+		t.Parallel()
+		opts, snapshotPath, _ := persistentOptions(t)
+
+		data, err := os.ReadFile(fixturesPath)
+		require.NoError(t, err)
+
+		var fixtures struct {
+			Books []struct {
+				Title string `json:"title"`
+				ISBN  string `json:"isbn"`
+			} `json:"books"`
+		}
+		require.NoError(t, json.Unmarshal(data, &fixtures))
+
+		store, err := memory.Open(append(opts, memory.WithSeed(fixturesPath))...)
+		require.NoError(t, err)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, books, len(fixtures.Books))
+
+		first := fixtures.Books[0]
+		bookID := domain.ISBNID(domain.NewBook{Title: first.Title, ISBN: first.ISBN})
+		ret, err := store.FindOne(ctx, bookID)
+		require.NoError(t, err)
+		require.Equal(t, first.Title, ret.Title)
+
+		// The books of the snapshot are kept, the seed is not loaded again.
+		require.NoError(t, store.Delete(ctx, bookID))
+		require.NoError(t, store.Close())
+
+		store, err = memory.Open(memory.WithSnapshot(snapshotPath), memory.WithSeed(fixturesPath))
+		require.NoError(t, err)
+
+		books, err = store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, books, len(fixtures.Books)-1)
+	})
+
+	t.Run("should require a snapshot file for the log", func(t *testing.T) {
+		t.Parallel()
+		_, err := memory.Open(memory.WithLog(filepath.Join(t.TempDir(), "catalog.log")))
+		require.ErrorIs(t, err, memory.ErrMissingSnapshot)
+
+		_, err = memory.Open(memory.WithAutoSnapshot(0))
+		require.ErrorContains(t, err, "invalid interval")
+	})
+}
+
+func TestPersistentConformance(t *testing.T) {
+	storertest.Run(t, func(t *testing.T) domain.Storer {
+		opts, _, _ := persistentOptions(t)
+
+		store, err := memory.Open(opts...)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+		return store
+	})
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// translateError maps the errors returned by pgx to the domain error set,
+// keeping the original error in the chain.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", domain.ErrNotFound, err)
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch {
+	case pgErr.Code == "23505": // unique_violation
+		return fmt.Errorf("%w: %w", domain.ErrAlreadyExists, err)
+	case pgErr.Code == "40001", // serialization_failure
+		pgErr.Code == "40P01", // deadlock_detected
+		pgErr.Code == "53300", // too_many_connections
+		pgErr.Code == "55P03": // lock_not_available
+		return fmt.Errorf("%w: %w", domain.ErrThrottled, err)
+	case strings.HasPrefix(pgErr.Code, "08"), // connection exceptions
+		strings.HasPrefix(pgErr.Code, "57P"), // operator intervention, e.g. shutdown
+		pgErr.Code == "53100":                // disk_full
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrations holds the schema migrations, applied in the order of the number
+// prefixing their name (e.g. 0001_create_books.sql).
+//
+// NOTE: a released migration must never change, a schema change needs a new
+// file.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the key of the advisory lock held while migrating, so
+// that the instances of a deployment starting together migrate one at a
+// time.
+const migrationLock = 0x616c6573 // "ales"
+
+// migration is a schema migration read from the migrations folder.
+type migration struct {
+	version int
+	name    string
+	script  string
+}
+
+// loadMigrations returns the embedded migrations ordered by version.
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]migration, 0, len(entries))
+
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration name %q", entry.Name())
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration name %q", entry.Name())
+		}
+
+		script, err := fs.ReadFile(migrations, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, migration{version: version, name: entry.Name(), script: string(script)})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].version < list[j].version
+	})
+
+	for i, m := range list {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("missing migration %d", i+1)
+		}
+	}
+
+	return list, nil
+}
+
+// Version returns the schema version of the database, the number of the
+// last migration applied.
+func (s *Store) Version(ctx context.Context) (int, error) {
+	var version int
+
+	err := s.pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("postgres.version: %w", translateError(err))
+	}
+
+	return version, nil
+}
+
+// Migrate applies the embedded migrations not applied yet, each one in its
+// own transaction, holding an advisory lock for the whole run. NewStore
+// calls it unless WithoutMigrations is given.
+func (s *Store) Migrate(ctx context.Context) error {
+	list, err := loadMigrations()
+	if err != nil {
+		return fmt.Errorf("postgres.migrate: %w", err)
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres.migrate acquire: %w", translateError(err))
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+		return fmt.Errorf("postgres.migrate lock: %w", translateError(err))
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLock) //nolint:errcheck
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("postgres.migrate: %w", translateError(err))
+	}
+
+	var current int
+	if err := conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("postgres.migrate: %w", translateError(err))
+	}
+
+	if current > len(list) {
+		return fmt.Errorf("postgres.migrate: schema version %d is newer than %d", current, len(list))
+	}
+
+	for _, m := range list[current:] {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("postgres.migrate %s: %w", m.name, translateError(err))
+		}
+
+		_, err = tx.Exec(ctx, m.script)
+		if err == nil {
+			_, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name)
+		}
+
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+
+		if err != nil {
+			tx.Rollback(ctx) //nolint:errcheck
+
+			return fmt.Errorf("postgres.migrate %s: %w", m.name, translateError(err))
+		}
+	}
+
+	return nil
+}
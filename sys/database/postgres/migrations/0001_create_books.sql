@@ -0,0 +1,27 @@
+-- Books, ordered by id as in the other stores: uuid values compare byte by
+-- byte, like their canonical text form.
+CREATE TABLE books (
+    id          uuid PRIMARY KEY,
+    title       text NOT NULL,
+    authors     text NOT NULL DEFAULT '',
+    publisher   text NOT NULL DEFAULT '',
+    pages       integer NOT NULL DEFAULT 0,
+    isbn        text NOT NULL DEFAULT '',
+    isbn13      text NOT NULL DEFAULT '',
+    tags        text[] NOT NULL DEFAULT '{}',
+    merged_into uuid
+);
+
+-- Books by ISBN, in the ISBN-13 form.
+CREATE INDEX books_isbn13 ON books (isbn13) WHERE isbn13 <> '';
+
+-- Full-text search over title, authors and publisher, weighted in this
+-- order. The simple configuration does not stem, the catalog holds books in
+-- many languages.
+ALTER TABLE books ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', authors), 'B') ||
+    setweight(to_tsvector('simple', publisher), 'C')
+) STORED;
+
+CREATE INDEX books_search ON books USING gin (search);
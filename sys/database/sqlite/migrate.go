@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrations holds the schema migrations, applied in the order of the number
+// prefixing their name (e.g. 0001_create_books.sql).
+//
+// NOTE: a released migration must never change, a schema change needs a new
+// file.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migration is a schema migration read from the migrations folder.
+type migration struct {
+	version int
+	name    string
+	script  string
+}
+
+// loadMigrations returns the embedded migrations ordered by version.
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]migration, 0, len(entries))
+
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration name %q", entry.Name())
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration name %q", entry.Name())
+		}
+
+		script, err := fs.ReadFile(migrations, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, migration{version: version, name: entry.Name(), script: string(script)})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].version < list[j].version
+	})
+
+	for i, m := range list {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("missing migration %d", i+1)
+		}
+	}
+
+	return list, nil
+}
+
+// Version returns the schema version of the database, the number of the
+// last migration applied.
+func (s *Store) Version(ctx context.Context) (int, error) {
+	var version int
+
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite.version: %w", translateError(err))
+	}
+
+	return version, nil
+}
+
+// Migrate applies the embedded migrations not applied yet, each one in its
+// own transaction. NewStore calls it unless WithoutMigrations is given.
+func (s *Store) Migrate(ctx context.Context) error {
+	list, err := loadMigrations()
+	if err != nil {
+		return fmt.Errorf("sqlite.migrate: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY NOT NULL,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("sqlite.migrate: %w", translateError(err))
+	}
+
+	current, err := s.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("sqlite.migrate: %w", err)
+	}
+
+	if current > len(list) {
+		return fmt.Errorf("sqlite.migrate: schema version %d is newer than %d", current, len(list))
+	}
+
+	for _, m := range list[current:] {
+		if err := s.apply(ctx, m); err != nil {
+			return fmt.Errorf("sqlite.migrate %s: %w", m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// apply runs a migration and records it, rolling back both on failure.
+func (s *Store) apply(ctx context.Context, m migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, m.script); err != nil {
+		return translateError(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return translateError(err)
+	}
+
+	return translateError(tx.Commit())
+}
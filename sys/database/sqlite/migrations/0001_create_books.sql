@@ -0,0 +1,16 @@
+-- Books, keyed by the canonical form of their UUID so that ordering by id
+-- matches the ordering of the other stores.
+CREATE TABLE books (
+    id          TEXT PRIMARY KEY NOT NULL,
+    title       TEXT NOT NULL,
+    authors     TEXT NOT NULL DEFAULT '',
+    publisher   TEXT NOT NULL DEFAULT '',
+    pages       INTEGER NOT NULL DEFAULT 0,
+    isbn        TEXT NOT NULL DEFAULT '',
+    isbn13      TEXT NOT NULL DEFAULT '',
+    tags        TEXT NOT NULL DEFAULT '[]',
+    merged_into TEXT
+) WITHOUT ROWID;
+
+-- Books by ISBN, in the ISBN-13 form.
+CREATE INDEX books_isbn13 ON books (isbn13) WHERE isbn13 <> '';
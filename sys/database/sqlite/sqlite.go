@@ -0,0 +1,469 @@
+// Package sqlite implements domain.Storer on an SQLite database, for the
+// deployments running without AWS. The driver is written in pure Go, so the
+// binaries keep being built with CGO_ENABLED=0.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// ErrMissingPath is returned when the path of the database is empty.
+var ErrMissingPath = errors.New("missing database path")
+
+// MaxBatchBooks is the maximum number of books read by a single query of
+// FindMany, below the default limit of SQLite on the bound parameters.
+const MaxBatchBooks = 500
+
+// Memory is the path of a database kept in memory, lost when the Store is
+// closed.
+const Memory = ":memory:"
+
+// pragmas are set on every connection: foreign keys are enforced, the write
+// ahead log lets readers run alongside a writer, which waits for a lock up
+// to five seconds before failing with domain.ErrThrottled.
+var pragmas = []string{
+	"foreign_keys(1)",
+	"journal_mode(WAL)",
+	"busy_timeout(5000)",
+}
+
+// Option is a function that configures a Store.
+type Option func(*Store) error
+
+// WithDB returns a Store Option that sets the database, in place of the one
+// opened by NewStore. The Store does not close it.
+func WithDB(db *sql.DB) Option {
+	return func(s *Store) error {
+		s.db = db
+
+		return nil
+	}
+}
+
+// WithoutMigrations returns a Store Option that skips the schema migrations
+// run by NewStore, e.g. when they are applied by Migrate on deploy.
+func WithoutMigrations() Option {
+	return func(s *Store) error {
+		s.skipMigrations = true
+
+		return nil
+	}
+}
+
+// Store manages the set of APIs for book access on SQLite.
+type Store struct {
+	db             *sql.DB
+	owned          bool
+	skipMigrations bool
+}
+
+// Ensure Store implements the Storer interface.
+var _ domain.Storer = (*Store)(nil)
+
+// NewStore returns a Store on the database at path, created when missing, and
+// migrates its schema to the current version.
+func NewStore(ctx context.Context, path string, opts ...Option) (*Store, error) {
+	store := &Store{}
+
+	for _, opt := range opts {
+		if err := opt(store); err != nil {
+			return nil, fmt.Errorf("sqlite.newstore option: %w", err)
+		}
+	}
+
+	if store.db == nil {
+		db, err := open(path)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite.newstore: %w", err)
+		}
+
+		store.db, store.owned = db, true
+	}
+
+	if !store.skipMigrations {
+		if err := store.Migrate(ctx); err != nil {
+			store.Close()
+
+			return nil, fmt.Errorf("sqlite.newstore: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// open opens the database at path with the pragmas set.
+func open(path string) (*sql.DB, error) {
+	if path == "" {
+		return nil, ErrMissingPath
+	}
+
+	dsn := "file:" + path + "?_pragma=" + strings.Join(pragmas, "&_pragma=")
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	// Every connection to an in-memory database opens a new, empty one.
+	if path == Memory {
+		db.SetMaxOpenConns(1)
+	}
+
+	return db, nil
+}
+
+// Close closes the database, unless it was set by WithDB.
+func (s *Store) Close() error {
+	if !s.owned {
+		return nil
+	}
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("sqlite.close: %w", err)
+	}
+
+	return nil
+}
+
+// row holds the columns of the books table.
+type row struct {
+	ID         string
+	Title      string
+	Authors    string
+	Publisher  string
+	Pages      int
+	ISBN       string
+	ISBN13     string
+	Tags       string
+	MergedInto sql.NullString
+}
+
+// columns lists the columns of the books table in the order of row.
+const columns = `id, title, authors, publisher, pages, isbn, isbn13, tags, merged_into`
+
+// toRow converts a book into a row of the books table.
+func toRow(book domain.Book) (row, error) {
+	tags := book.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return row{}, err
+	}
+
+	r := row{
+		ID:        book.ID.String(),
+		Title:     book.Title,
+		Authors:   book.Authors,
+		Publisher: book.Publisher,
+		Pages:     book.Pages,
+		ISBN:      book.ISBN,
+		ISBN13:    domain.ISBN13(book.ISBN),
+		Tags:      string(encoded),
+	}
+
+	if book.MergedInto != uuid.Nil {
+		r.MergedInto = sql.NullString{String: book.MergedInto.String(), Valid: true}
+	}
+
+	return r, nil
+}
+
+func (r row) args() []any {
+	return []any{r.ID, r.Title, r.Authors, r.Publisher, r.Pages, r.ISBN, r.ISBN13, r.Tags, r.MergedInto}
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanBook reads a row of the books table into a book.
+func scanBook(sc scanner) (domain.Book, error) {
+	var r row
+
+	err := sc.Scan(&r.ID, &r.Title, &r.Authors, &r.Publisher, &r.Pages, &r.ISBN, &r.ISBN13, &r.Tags, &r.MergedInto)
+	if err != nil {
+		return domain.Book{}, translateError(err)
+	}
+
+	bookID, err := uuid.Parse(r.ID)
+	if err != nil {
+		return domain.Book{}, fmt.Errorf("invalid id %q: %w", r.ID, err)
+	}
+
+	book := domain.Book{
+		ID:        bookID,
+		Title:     r.Title,
+		Authors:   r.Authors,
+		Publisher: r.Publisher,
+		Pages:     r.Pages,
+		ISBN:      r.ISBN,
+	}
+
+	if err := json.Unmarshal([]byte(r.Tags), &book.Tags); err != nil {
+		return domain.Book{}, fmt.Errorf("invalid tags of %q: %w", r.ID, err)
+	}
+
+	if len(book.Tags) == 0 {
+		book.Tags = nil
+	}
+
+	if r.MergedInto.Valid {
+		if book.MergedInto, err = uuid.Parse(r.MergedInto.String); err != nil {
+			return domain.Book{}, fmt.Errorf("invalid merged_into of %q: %w", r.ID, err)
+		}
+	}
+
+	return book, nil
+}
+
+// queryBooks runs a query selecting the columns of the books table.
+func (s *Store) queryBooks(ctx context.Context, query string, args ...any) ([]domain.Book, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	books := []domain.Book{}
+
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		books = append(books, book)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return books, nil
+}
+
+const insertBook = `INSERT INTO books (` + columns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// Save adds a new book into the SQLite database.
+func (s *Store) Save(ctx context.Context, book domain.Book) error {
+	r, err := toRow(book)
+	if err != nil {
+		return fmt.Errorf("sqlite.save: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, insertBook, r.args()...); err != nil {
+		return fmt.Errorf("sqlite.save: %w", translateError(err))
+	}
+
+	return nil
+}
+
+// SaveMany adds a batch of new books into the SQLite database, in a single
+// transaction.
+//
+// Books whose ID already exists are reported in a domain.BatchError, the
+// others are saved.
+func (s *Store) SaveMany(ctx context.Context, books []domain.Book) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite.savemany: %w", translateError(err))
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	stmt, err := tx.PrepareContext(ctx, insertBook+` ON CONFLICT (id) DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("sqlite.savemany: %w", translateError(err))
+	}
+	defer stmt.Close()
+
+	failed := make(map[uuid.UUID]error)
+
+	for _, book := range books {
+		r, err := toRow(book)
+		if err != nil {
+			failed[book.ID] = fmt.Errorf("sqlite.savemany: %w", err)
+			continue
+		}
+
+		result, err := stmt.ExecContext(ctx, r.args()...)
+		if err != nil {
+			return fmt.Errorf("sqlite.savemany: %w", translateError(err))
+		}
+
+		if n, _ := result.RowsAffected(); n == 0 {
+			failed[book.ID] = fmt.Errorf("sqlite.savemany: %w", domain.ErrAlreadyExists)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlite.savemany: %w", translateError(err))
+	}
+
+	if len(failed) > 0 {
+		return &domain.BatchError{Failed: failed}
+	}
+
+	return nil
+}
+
+// FindAll returns all books from the SQLite database, ordered by ID.
+func (s *Store) FindAll(ctx context.Context) ([]domain.Book, error) {
+	books, err := s.queryBooks(ctx, `SELECT `+columns+` FROM books ORDER BY id`)
+	if err != nil {
+		return []domain.Book{}, fmt.Errorf("sqlite.findall: %w", err)
+	}
+
+	return books, nil
+}
+
+// FindPage returns up to limit books with an ID greater than after, ordered
+// by ID, from the SQLite database.
+func (s *Store) FindPage(ctx context.Context, after uuid.UUID, limit int) ([]domain.Book, error) {
+	books, err := s.queryBooks(ctx, `SELECT `+columns+` FROM books WHERE id > ? ORDER BY id LIMIT ?`,
+		after.String(), limit)
+	if err != nil {
+		return []domain.Book{}, fmt.Errorf("sqlite.findpage: %w", err)
+	}
+
+	return books, nil
+}
+
+// FindMany returns the books matching the given IDs from the SQLite database.
+// IDs without a matching book are ignored.
+//
+// IDs are looked up in chunks of MaxBatchBooks.
+func (s *Store) FindMany(ctx context.Context, bookIDs []uuid.UUID) ([]domain.Book, error) {
+	books := make([]domain.Book, 0, len(bookIDs))
+
+	for start := 0; start < len(bookIDs); start += MaxBatchBooks {
+		chunk := bookIDs[start:min(start+MaxBatchBooks, len(bookIDs))]
+		args := make([]any, len(chunk))
+
+		for i, bookID := range chunk {
+			args[i] = bookID.String()
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", ")
+
+		found, err := s.queryBooks(ctx, `SELECT `+columns+` FROM books WHERE id IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return []domain.Book{}, fmt.Errorf("sqlite.findmany: %w", err)
+		}
+
+		books = append(books, found...)
+	}
+
+	return books, nil
+}
+
+// FindOne returns a book from the SQLite database.
+func (s *Store) FindOne(ctx context.Context, bookID uuid.UUID) (domain.Book, error) {
+	book, err := scanBook(s.db.QueryRowContext(ctx, `SELECT `+columns+` FROM books WHERE id = ?`, bookID.String()))
+	if err != nil {
+		return domain.Book{}, fmt.Errorf("sqlite.findone: %w", err)
+	}
+
+	return book, nil
+}
+
+// FindByISBN returns the books with the given ISBN, in either the ISBN-10 or
+// the ISBN-13 form.
+func (s *Store) FindByISBN(ctx context.Context, isbn string) ([]domain.Book, error) {
+	isbn13 := domain.ISBN13(isbn)
+	if isbn13 == "" {
+		isbn13 = domain.NormalizeISBN(isbn)
+	}
+
+	books, err := s.queryBooks(ctx, `SELECT `+columns+` FROM books WHERE isbn13 = ? ORDER BY id`, isbn13)
+	if err != nil {
+		return []domain.Book{}, fmt.Errorf("sqlite.findbyisbn: %w", err)
+	}
+
+	return books, nil
+}
+
+// Update replaces an existing book in the SQLite database.
+func (s *Store) Update(ctx context.Context, book domain.Book) error {
+	r, err := toRow(book)
+	if err != nil {
+		return fmt.Errorf("sqlite.update: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `UPDATE books SET
+		title = ?, authors = ?, publisher = ?, pages = ?, isbn = ?, isbn13 = ?, tags = ?, merged_into = ?
+		WHERE id = ?`, append(r.args()[1:], r.ID)...)
+	if err != nil {
+		return fmt.Errorf("sqlite.update: %w", translateError(err))
+	}
+
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("sqlite.update: %w", domain.ErrNotFound)
+	}
+
+	return nil
+}
+
+// CompareAndSwap replaces the previous books with books in the SQLite
+// database, in a single transaction, provided none of them changed since it
+// was read.
+func (s *Store) CompareAndSwap(ctx context.Context, previous, books []domain.Book) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlite.compareandswap: %w", translateError(err))
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for i, book := range books {
+		r, err := toRow(book)
+		if err != nil {
+			return fmt.Errorf("sqlite.compareandswap: %w", err)
+		}
+
+		p, err := toRow(previous[i])
+		if err != nil {
+			return fmt.Errorf("sqlite.compareandswap: %w", err)
+		}
+
+		result, err := tx.ExecContext(ctx, `UPDATE books SET
+			title = ?, authors = ?, publisher = ?, pages = ?, isbn = ?, isbn13 = ?, tags = ?, merged_into = ?
+			WHERE id = ? AND title = ? AND authors = ? AND publisher = ? AND pages = ? AND isbn = ? AND tags = ?
+			AND merged_into IS ?`,
+			append(r.args()[1:], p.ID, p.Title, p.Authors, p.Publisher, p.Pages, p.ISBN, p.Tags, p.MergedInto)...)
+		if err != nil {
+			return fmt.Errorf("sqlite.compareandswap: %w", translateError(err))
+		}
+
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return fmt.Errorf("sqlite.compareandswap: %w", domain.ErrPreconditionFailed)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlite.compareandswap: %w", translateError(err))
+	}
+
+	return nil
+}
+
+// Delete removes a book from the SQLite database, deleting a missing book is
+// not an error.
+func (s *Store) Delete(ctx context.Context, bookID uuid.UUID) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM books WHERE id = ?`, bookID.String()); err != nil {
+		return fmt.Errorf("sqlite.delete: %w", translateError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/domain/storertest"
+	"github.com/rotiroti/alessandrina/sys/database/sqlite"
+	"github.com/stretchr/testify/require"
+)
+
+func newStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+
+	store, err := sqlite.NewStore(context.Background(), filepath.Join(t.TempDir(), "catalog.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func TestSQLiteStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	book := domain.Book{
+		ID:         uuid.New(),
+		Title:      "The Go Programming Language",
+		Authors:    "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher:  "Addison-Wesley Professional",
+		Pages:      400,
+		ISBN:       "978-0134190440",
+		Tags:       []string{"go", "programming"},
+		MergedInto: uuid.New(),
+	}
+
+	t.Run("should save a new book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		require.NoError(t, store.Save(ctx, book))
+		ret, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+		require.Equal(t, book, ret)
+	})
+
+	t.Run("should not save an existing book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		require.NoError(t, store.Save(ctx, book))
+		require.ErrorIs(t, store.Save(ctx, book), domain.ErrAlreadyExists)
+	})
+
+	t.Run("should save a batch of books", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		require.NoError(t, store.Save(ctx, book))
+
+		newBook := domain.Book{ID: uuid.New(), Title: "Learning Go"}
+		err := store.SaveMany(ctx, []domain.Book{newBook, book})
+
+		var batchErr *domain.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		require.Len(t, batchErr.Failed, 1)
+		require.ErrorIs(t, batchErr.Failed[book.ID], domain.ErrAlreadyExists)
+
+		ret, err := store.FindOne(ctx, newBook.ID)
+		require.NoError(t, err)
+		require.Equal(t, newBook, ret)
+	})
+
+	t.Run("should return the books matching the given IDs", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		require.NoError(t, store.Save(ctx, book))
+		ret, err := store.FindMany(ctx, []uuid.UUID{uuid.New(), book.ID})
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{book}, ret)
+	})
+
+	t.Run("should throw error for unfound book ID", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		_, err := store.FindOne(ctx, uuid.New())
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("should update an existing book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		require.NoError(t, store.Save(ctx, book))
+
+		updated := book
+		updated.Title = "The Go Programming Language, 2nd edition"
+		updated.Tags = nil
+		updated.MergedInto = uuid.Nil
+		require.NoError(t, store.Update(ctx, updated))
+
+		ret, err := store.FindOne(ctx, book.ID)
+		require.NoError(t, err)
+		require.Equal(t, updated, ret)
+	})
+
+	t.Run("should not update a non existing book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		require.ErrorIs(t, store.Update(ctx, book), domain.ErrNotFound)
+	})
+
+	t.Run("should return the books ordered by ID one page at a time", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		ids := []uuid.UUID{
+			uuid.MustParse("a0000000-0000-4000-8000-000000000000"),
+			uuid.MustParse("0a000000-0000-4000-8000-000000000000"),
+			uuid.MustParse("f0000000-0000-4000-8000-000000000000"),
+		}
+		for _, id := range ids {
+			require.NoError(t, store.Save(ctx, domain.Book{ID: id, Title: id.String()}))
+		}
+
+		page, err := store.FindPage(ctx, uuid.Nil, 2)
+		require.NoError(t, err)
+		require.Equal(t, []uuid.UUID{ids[1], ids[0]}, bookIDs(page))
+
+		page, err = store.FindPage(ctx, ids[0], 2)
+		require.NoError(t, err)
+		require.Equal(t, []uuid.UUID{ids[2]}, bookIDs(page))
+
+		all, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []uuid.UUID{ids[1], ids[0], ids[2]}, bookIDs(all))
+	})
+
+	t.Run("should return the books by ISBN in either form", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		require.NoError(t, store.Save(ctx, book))
+		require.NoError(t, store.Save(ctx, domain.Book{ID: uuid.New(), Title: "Untitled"}))
+
+		ret, err := store.FindByISBN(ctx, "0-13-419044-0")
+		require.NoError(t, err)
+		require.Equal(t, []domain.Book{book}, ret)
+	})
+
+	t.Run("should delete an existing book", func(t *testing.T) {
+		t.Parallel()
+		store := newStore(t)
+		require.NoError(t, store.Save(ctx, book))
+		require.NoError(t, store.Delete(ctx, book.ID))
+		require.NoError(t, store.Delete(ctx, book.ID))
+		_, err := store.FindOne(ctx, book.ID)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "catalog.db")
+
+	t.Run("Idempotent", func(t *testing.T) {
+		store, err := sqlite.NewStore(ctx, path)
+		require.NoError(t, err)
+		require.NoError(t, store.Migrate(ctx))
+
+		version, err := store.Version(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, version)
+		require.NoError(t, store.Close())
+	})
+
+	t.Run("NewerVersion", func(t *testing.T) {
+		db, err := sql.Open("sqlite", path)
+		require.NoError(t, err)
+		defer db.Close()
+		_, err = db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (99, 'future', '')`)
+		require.NoError(t, err)
+
+		_, err = sqlite.NewStore(ctx, "", sqlite.WithDB(db))
+		require.Error(t, err)
+	})
+
+	t.Run("MissingPath", func(t *testing.T) {
+		_, err := sqlite.NewStore(ctx, "")
+		require.ErrorIs(t, err, sqlite.ErrMissingPath)
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		store, err := sqlite.NewStore(ctx, sqlite.Memory)
+		require.NoError(t, err)
+		defer store.Close()
+		require.NoError(t, store.Save(ctx, domain.Book{ID: uuid.New(), Title: "Learning Go"}))
+
+		all, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+	})
+}
+
+func bookIDs(books []domain.Book) []uuid.UUID {
+	ids := make([]uuid.UUID, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+	}
+
+	return ids
+}
+
+func TestConformance(t *testing.T) {
+	storertest.Run(t, func(t *testing.T) domain.Storer {
+		return newStore(t)
+	})
+}
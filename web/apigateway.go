@@ -4,17 +4,37 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/catalog"
 	"github.com/rotiroti/alessandrina/domain"
 	"github.com/rotiroti/alessandrina/sys/validation"
 )
 
+const (
+	// MaxBatchSize is the maximum number of books accepted by a batch request.
+	MaxBatchSize = 1000
+
+	// MaxLookupIDs is the maximum number of IDs accepted by a lookup request.
+	MaxLookupIDs = 100
+
+	// DefaultPageSize is the number of books of a page when no limit is given.
+	DefaultPageSize = 25
+
+	// MaxPageSize is the maximum number of books of a page.
+	MaxPageSize = 100
+)
+
 // APIGatewayV2Handler is the handler for the API Gateway v2.
 type APIGatewayV2Handler struct {
 	book      *domain.BookCore
+	importer  *catalog.Importer
 	validator validation.Validator
 }
 
@@ -22,6 +42,7 @@ type APIGatewayV2Handler struct {
 func NewAPIGatewayV2Handler(book *domain.BookCore) *APIGatewayV2Handler {
 	return &APIGatewayV2Handler{
 		book:      book,
+		importer:  catalog.NewImporter(book),
 		validator: validation.New(),
 	}
 }
@@ -31,46 +52,229 @@ func (h *APIGatewayV2Handler) CreateBook(ctx context.Context, req events.APIGate
 	var appNewBook AppNewBook
 
 	if err := json.Unmarshal([]byte(req.Body), &appNewBook); err != nil {
-		return errorResponse(http.StatusBadRequest, err.Error()), nil
+		return badRequestResponse("invalid request body", err), nil
 	}
 
 	if err := h.validator.Check(appNewBook); err != nil {
-		return errorResponse(http.StatusBadRequest, err.Error()), nil
+		return badRequestResponse("invalid request body", err), nil
 	}
 
 	domainNewBook := ToDomainNewBook(appNewBook)
 	ret, err := h.book.Save(ctx, domainNewBook)
 	if err != nil {
-		return errorResponse(http.StatusInternalServerError, err.Error()), nil
+		return domainErrorResponse(err), nil
 	}
 
 	return jsonResponse(http.StatusCreated, ToAppBook(ret)), nil
 }
 
-// GetBooks handles requests for getting all books.
+// CreateBooks handles requests for creating a batch of books.
+//
+// Each entry is validated and saved independently: the response reports,
+// for every entry, either the created book or the reason it was rejected.
+func (h *APIGatewayV2Handler) CreateBooks(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	var appNewBooks AppNewBooks
+
+	if err := json.Unmarshal([]byte(req.Body), &appNewBooks); err != nil {
+		return badRequestResponse("invalid request body", err), nil
+	}
+
+	if len(appNewBooks.Books) == 0 || len(appNewBooks.Books) > MaxBatchSize {
+		msg := fmt.Sprintf("a batch must contain between 1 and %d books", MaxBatchSize)
+
+		return badRequestResponse(msg, nil), nil
+	}
+
+	results := make([]AppBatchResult, len(appNewBooks.Books))
+	domainNewBooks := make([]domain.NewBook, 0, len(appNewBooks.Books))
+	positions := make([]int, 0, len(appNewBooks.Books))
+
+	for i, appNewBook := range appNewBooks.Books {
+		results[i].Index = i
+
+		if err := h.validator.Check(appNewBook); err != nil {
+			appErr := badRequestError("invalid book", err)
+			results[i].Error = &appErr
+
+			continue
+		}
+
+		domainNewBooks = append(domainNewBooks, ToDomainNewBook(appNewBook))
+		positions = append(positions, i)
+	}
+
+	for j, ret := range h.book.SaveMany(ctx, domainNewBooks) {
+		i := positions[j]
+
+		if ret.Err != nil {
+			appErr := toAppError(ret.Err)
+			results[i].Error = &appErr
+
+			continue
+		}
+
+		appBook := ToAppBook(ret.Book)
+		results[i].Book = &appBook
+	}
+
+	appResults := ToAppBatchResults(results)
+	if appResults.Failed > 0 {
+		return jsonResponse(http.StatusMultiStatus, appResults), nil
+	}
+
+	return jsonResponse(http.StatusCreated, appResults), nil
+}
+
+// GetBooks handles requests for getting the books one page at a time.
+//
+// When the "ids" query string parameter is set (comma separated UUIDs), only
+// the matching books are returned, together with the IDs that were not found.
+// Otherwise a page of "limit" books (DefaultPageSize when not set) ordered by
+// ID is returned, starting after the "after" one, and "next" is the value of
+// "after" for the following page.
+// Like GetBook, the books can be requested in any format supported by the
+// catalog, e.g. as BibTeX entries with ?format=bibtex.
 func (h *APIGatewayV2Handler) GetBooks(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
-	ret, err := h.book.FindAll(ctx)
+	mediaType, ok := negotiate(req, bookMediaTypes())
+	if !ok {
+		return errorResponse(notAcceptableError()), nil
+	}
+
+	if ids, ok := req.QueryStringParameters["ids"]; ok {
+		return h.getBooksByIDs(ctx, ids, mediaType), nil
+	}
+
+	// Without a limit the first DefaultPageSize books are listed, a single
+	// response cannot hold the whole catalog.
+	return h.getBooksPage(ctx, req.QueryStringParameters, mediaType), nil
+}
+
+func (h *APIGatewayV2Handler) getBooksPage(ctx context.Context, query map[string]string, mediaType string) events.APIGatewayV2HTTPResponse {
+	limit := DefaultPageSize
+
+	if value, ok := query["limit"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxPageSize {
+			return badRequestResponse(fmt.Sprintf("invalid limit parameter, it must be between 1 and %d", MaxPageSize), nil)
+		}
+
+		limit = n
+	}
+
+	var after uuid.UUID
+
+	if value := query["after"]; value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return badRequestResponse("invalid after parameter", err)
+		}
+
+		after = id
+	}
+
+	page, err := h.book.FindPage(ctx, after, limit)
+	if err != nil {
+		return domainErrorResponse(err)
+	}
+
+	if mediaType != mediaTypeJSON {
+		return formatResponse(mediaType, page.Books, catalog.Options{})
+	}
+
+	return jsonResponse(http.StatusOK, ToAppPage(page))
+}
+
+func (h *APIGatewayV2Handler) getBooksByIDs(ctx context.Context, ids, mediaType string) events.APIGatewayV2HTTPResponse {
+	parts := strings.Split(ids, ",")
+	if len(parts) > MaxLookupIDs {
+		return badRequestResponse(fmt.Sprintf("at most %d ids can be requested", MaxLookupIDs), nil)
+	}
+
+	bookIDs := make([]uuid.UUID, len(parts))
+
+	for i, part := range parts {
+		id, err := uuid.Parse(strings.TrimSpace(part))
+		if err != nil {
+			return badRequestResponse("invalid book id", err)
+		}
+
+		bookIDs[i] = id
+	}
+
+	books, missing, err := h.book.FindMany(ctx, bookIDs)
 	if err != nil {
-		return errorResponse(http.StatusInternalServerError, err.Error()), nil
+		return domainErrorResponse(err)
 	}
 
-	return jsonResponse(http.StatusOK, ToAppListBooks(ret)), nil
+	if mediaType != mediaTypeJSON {
+		return formatResponse(mediaType, books, catalog.Options{})
+	}
+
+	return jsonResponse(http.StatusOK, ToAppFoundBooks(books, missing))
 }
 
 // GetBook handles requests for getting a book by a given ID (UUID).
+//
+// Looking up a book merged into another one redirects to the surviving book.
+//
+// The book is returned as JSON unless another format supported by the
+// catalog (e.g. application/marc) is requested through the Accept header or
+// the "format" query string parameter.
 func (h *APIGatewayV2Handler) GetBook(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	id, err := uuid.Parse(req.PathParameters["id"])
 	if err != nil {
-		return errorResponse(http.StatusBadRequest, err.Error()), nil
+		return badRequestResponse("invalid book id", err), nil
+	}
+
+	mediaType, ok := negotiate(req, bookMediaTypes())
+	if !ok {
+		return errorResponse(notAcceptableError()), nil
 	}
 
 	ret, err := h.book.FindOne(ctx, id)
+
+	var merr *domain.MergedError
+	if errors.As(err, &merr) {
+		return movedResponse(merr.Into), nil
+	}
+
 	if err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			return errorResponse(http.StatusNotFound, err.Error()), nil
-		}
+		return domainErrorResponse(err), nil
+	}
 
-		return errorResponse(http.StatusInternalServerError, err.Error()), nil
+	if mediaType != mediaTypeJSON {
+		return formatResponse(mediaType, []domain.Book{ret}, catalog.Options{}), nil
+	}
+
+	return jsonResponse(http.StatusOK, ToAppBook(ret)), nil
+}
+
+// PutBook handles requests for creating a book with a given ID (UUID) or
+// replacing the existing one: 201 is returned when the book is created and
+// 200 when it is replaced.
+func (h *APIGatewayV2Handler) PutBook(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	id, err := uuid.Parse(req.PathParameters["id"])
+	if err != nil {
+		return badRequestResponse("invalid book id", err), nil
+	}
+
+	var appNewBook AppNewBook
+
+	if err := json.Unmarshal([]byte(req.Body), &appNewBook); err != nil {
+		return badRequestResponse("invalid request body", err), nil
+	}
+
+	if err := h.validator.Check(appNewBook); err != nil {
+		return badRequestResponse("invalid request body", err), nil
+	}
+
+	ret, created, err := h.book.Put(ctx, id, ToDomainNewBook(appNewBook))
+	if err != nil {
+		return domainErrorResponse(err), nil
+	}
+
+	if created {
+		return jsonResponse(http.StatusCreated, ToAppBook(ret)), nil
 	}
 
 	return jsonResponse(http.StatusOK, ToAppBook(ret)), nil
@@ -80,48 +284,35 @@ func (h *APIGatewayV2Handler) GetBook(ctx context.Context, req events.APIGateway
 func (h *APIGatewayV2Handler) DeleteBook(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	id, err := uuid.Parse(req.PathParameters["id"])
 	if err != nil {
-		return errorResponse(http.StatusBadRequest, err.Error()), nil
+		return badRequestResponse("invalid book id", err), nil
 	}
 
 	if err := h.book.Delete(ctx, id); err != nil {
-		return errorResponse(http.StatusInternalServerError, err.Error()), nil
+		return domainErrorResponse(err), nil
 	}
 
 	return jsonResponse(http.StatusNoContent, nil), nil
 }
 
-func jsonResponse(code int, obj any) events.APIGatewayV2HTTPResponse {
-	body, err := json.Marshal(obj)
-	if err != nil {
-		return errorResponse(http.StatusInternalServerError, err.Error())
-	}
-
+// movedResponse redirects the client to the /books/{id} path of the book
+// identified by bookID.
+func movedResponse(bookID uuid.UUID) events.APIGatewayV2HTTPResponse {
 	return events.APIGatewayV2HTTPResponse{
-		StatusCode: code,
+		StatusCode: http.StatusMovedPermanently,
 		Headers: map[string]string{
-			"Content-Type": "application/json",
+			"Location": "/books/" + bookID.String(),
 		},
-		Body:            string(body),
-		IsBase64Encoded: false,
 	}
 }
 
-func errorResponse(code int, err string) events.APIGatewayV2HTTPResponse {
-	type data struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	}
+func jsonResponse(code int, obj any) events.APIGatewayV2HTTPResponse {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		log.Printf("web: marshal response: %v", err)
 
-	errorMessage := map[string]data{
-		"error": {
-			Code:    code,
-			Message: err,
-		},
+		return errorResponse(internalError())
 	}
 
-	// NOTE: ignoring error as if Marshal fails even here, we have bigger problems.
-	body, _ := json.Marshal(errorMessage)
-
 	return events.APIGatewayV2HTTPResponse{
 		StatusCode: code,
 		Headers: map[string]string{
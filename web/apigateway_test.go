@@ -2,6 +2,8 @@ package web_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
 
@@ -25,6 +27,16 @@ func (m *MockStorer) FindAll(ctx context.Context) ([]domain.Book, error) {
 	return args.Get(0).([]domain.Book), args.Error(1)
 }
 
+func (m *MockStorer) FindMany(ctx context.Context, bookIDs []uuid.UUID) ([]domain.Book, error) {
+	args := m.Called(ctx, bookIDs)
+	return args.Get(0).([]domain.Book), args.Error(1)
+}
+
+func (m *MockStorer) FindPage(ctx context.Context, after uuid.UUID, limit int) ([]domain.Book, error) {
+	args := m.Called(ctx, after, limit)
+	return args.Get(0).([]domain.Book), args.Error(1)
+}
+
 func (m *MockStorer) FindOne(ctx context.Context, bookID uuid.UUID) (domain.Book, error) {
 	args := m.Called(ctx, bookID)
 	return args.Get(0).(domain.Book), args.Error(1)
@@ -35,6 +47,21 @@ func (m *MockStorer) Save(ctx context.Context, book domain.Book) error {
 	return args.Error(0)
 }
 
+func (m *MockStorer) SaveMany(ctx context.Context, books []domain.Book) error {
+	args := m.Called(ctx, books)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Update(ctx context.Context, book domain.Book) error {
+	args := m.Called(ctx, book)
+	return args.Error(0)
+}
+
+func (m *MockStorer) CompareAndSwap(ctx context.Context, previous, books []domain.Book) error {
+	args := m.Called(ctx, previous, books)
+	return args.Error(0)
+}
+
 func (m *MockStorer) Delete(ctx context.Context, bookID uuid.UUID) error {
 	args := m.Called(ctx, bookID)
 	return args.Error(0)
@@ -59,6 +86,7 @@ func TestBadRequest(t *testing.T) {
 	ctx := context.Background()
 	testCases := []testCase{
 		{name: "CreateBook", handle: handler.CreateBook},
+		{name: "CreateBooks", handle: handler.CreateBooks},
 		{name: "GetBook", handle: handler.GetBook},
 		{name: "DeleteBook", handle: handler.DeleteBook},
 	}
@@ -84,6 +112,77 @@ func TestCreateBookInvalidPayload(t *testing.T) {
 	require.Equal(t, http.StatusBadRequest, ret.StatusCode)
 }
 
+func TestCreateBookUnprocessableEntity(t *testing.T) {
+	ctx := context.Background()
+	bookCore := domain.NewBookCore(memory.NewStore())
+	handler := web.NewAPIGatewayV2Handler(bookCore)
+	ret, err := handler.CreateBook(ctx, events.APIGatewayV2HTTPRequest{
+		Body: `{
+			"title": "The Go Programming Language",
+			"authors": "Alan A. A. Donovan, Brian W. Kernighan",
+			"publisher": "Addison-Wesley Professional",
+			"pages": 0,
+			"isbn": "978-0134190441"
+		}`,
+	})
+	expectedJSONError := `{
+		"error": {
+			"code": 422,
+			"type": "invalid",
+			"message": "book is invalid",
+			"fields": [
+				{"field": "pages", "error": "pages must be 1 or greater"},
+				{"field": "isbn", "error": "isbn must be a valid ISBN-10 or ISBN-13"}
+			]
+		}
+	}`
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnprocessableEntity, ret.StatusCode)
+	require.JSONEq(t, expectedJSONError, ret.Body)
+}
+
+func TestDomainErrorMapping(t *testing.T) {
+	ctx := context.Background()
+	bookID := uuid.MustParse("ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c812")
+	testCases := []struct {
+		name         string
+		err          error
+		expectedCode int
+		expectedType string
+		expectedMsg  string
+		retryAfter   string
+	}{
+		{"NotFound", domain.ErrNotFound, http.StatusNotFound, "not_found", "book not found", ""},
+		{"Conflict", domain.ErrAlreadyExists, http.StatusConflict, "conflict", "book already exists", ""},
+		{"PreconditionFailed", domain.ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition_failed", "book precondition failed", ""},
+		{"Throttled", domain.ErrThrottled, http.StatusServiceUnavailable, "throttled", "storage is throttling requests", "1"},
+		{"Unavailable", domain.ErrUnavailable, http.StatusServiceUnavailable, "unavailable", "storage is unavailable", "5"},
+		{"Internal", assert.AnError, http.StatusInternalServerError, "internal", "Internal Server Error", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(MockStorer)
+			storageErr := fmt.Errorf("ddb.findone getitem: %w: secret table details", tc.err)
+			store.On("FindOne", ctx, bookID).Return(domain.Book{}, storageErr).Once()
+			handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+			ret, err := handler.GetBook(ctx, events.APIGatewayV2HTTPRequest{
+				PathParameters: map[string]string{
+					"id": bookID.String(),
+				},
+			})
+			expectedJSONError := fmt.Sprintf(`{"error": {"code": %d, "type": %q, "message": %q}}`,
+				tc.expectedCode, tc.expectedType, tc.expectedMsg)
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedCode, ret.StatusCode)
+			require.JSONEq(t, expectedJSONError, ret.Body)
+			require.Equal(t, tc.retryAfter, ret.Headers["Retry-After"])
+		})
+	}
+}
+
 func TestHandler(t *testing.T) {
 	ctx := context.Background()
 	expectedID, generator := setup(t)
@@ -124,7 +223,7 @@ func TestHandler(t *testing.T) {
 		})
 
 		require.NoError(t, err)
-		require.Equal(t, http.StatusInternalServerError, ret.StatusCode)
+		require.Equal(t, http.StatusConflict, ret.StatusCode)
 	})
 
 	t.Run("CreateBook", func(t *testing.T) {
@@ -140,6 +239,42 @@ func TestHandler(t *testing.T) {
 		require.JSONEq(t, expectedJSONBook, ret.Body)
 	})
 
+	t.Run("CreateBooks", func(t *testing.T) {
+		store := memory.NewStore()
+		bookCore := domain.NewBookCore(store)
+		handler := web.NewAPIGatewayV2Handler(bookCore)
+		ret, err := handler.CreateBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Body: fmt.Sprintf(`{"books": [%s, {"title": "Missing fields"}, %s]}`, jsonNewBook, jsonNewBook),
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusMultiStatus, ret.StatusCode)
+
+		var results web.AppBatchResults
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &results))
+		require.Equal(t, 2, results.Created)
+		require.Equal(t, 1, results.Failed)
+		require.NotNil(t, results.Results[0].Book)
+		require.Equal(t, http.StatusBadRequest, results.Results[1].Error.Code)
+		require.NotNil(t, results.Results[2].Book)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, books, 2)
+	})
+
+	t.Run("CreateBooksAllCreated", func(t *testing.T) {
+		store := memory.NewStore()
+		bookCore := domain.NewBookCore(store)
+		handler := web.NewAPIGatewayV2Handler(bookCore)
+		ret, err := handler.CreateBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Body: fmt.Sprintf(`{"books": [%s]}`, jsonNewBook),
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, ret.StatusCode)
+	})
+
 	t.Run("GetBookNotFound", func(t *testing.T) {
 		store := memory.NewStore()
 		bookCore := domain.NewBookCore(store)
@@ -222,9 +357,41 @@ func TestHandler(t *testing.T) {
 		require.Equal(t, http.StatusNoContent, ret.StatusCode)
 	})
 
+	t.Run("GetBooksByIDs", func(t *testing.T) {
+		store := memory.NewStore()
+		err := store.Save(ctx, existingBook)
+
+		require.NoError(t, err)
+
+		bookCore := domain.NewBookCore(store)
+		handler := web.NewAPIGatewayV2Handler(bookCore)
+		missingID := "ad8b59c2-5fe6-4267-b0cf-6d2f9eb1c813"
+		ret, err := handler.GetBooks(ctx, events.APIGatewayV2HTTPRequest{
+			QueryStringParameters: map[string]string{
+				"ids": expectedID.String() + "," + missingID,
+			},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, ret.StatusCode)
+		require.JSONEq(t, fmt.Sprintf(`{"books": [%s], "missing": [%q]}`, expectedJSONBook, missingID), ret.Body)
+	})
+
+	t.Run("GetBooksByInvalidIDs", func(t *testing.T) {
+		handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(memory.NewStore()))
+		ret, err := handler.GetBooks(ctx, events.APIGatewayV2HTTPRequest{
+			QueryStringParameters: map[string]string{
+				"ids": expectedID.String() + ",1234",
+			},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, ret.StatusCode)
+	})
+
 	t.Run("GetBooksFail", func(t *testing.T) {
 		store := new(MockStorer)
-		store.On("FindAll", ctx).Return([]domain.Book{}, assert.AnError).Once()
+		store.On("FindPage", ctx, uuid.Nil, web.DefaultPageSize).Return([]domain.Book{}, assert.AnError).Once()
 		bookCore := domain.NewBookCore(store)
 		handler := web.NewAPIGatewayV2Handler(bookCore)
 		ret, err := handler.GetBooks(ctx, events.APIGatewayV2HTTPRequest{})
@@ -234,7 +401,8 @@ func TestHandler(t *testing.T) {
 	})
 
 	t.Run("GetBooks", func(t *testing.T) {
-		const expectedBookCount = 5
+		// More books than a page holds: only the first page is returned.
+		const expectedBookCount = web.DefaultPageSize + 5
 
 		store := memory.NewStore()
 
@@ -257,5 +425,121 @@ func TestHandler(t *testing.T) {
 
 		require.NoError(t, err)
 		require.Equal(t, http.StatusOK, ret.StatusCode)
+
+		var books web.AppListBooks
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &books))
+		require.Len(t, books.Books, web.DefaultPageSize)
+		require.Equal(t, books.Books[web.DefaultPageSize-1].ID, books.Next)
+	})
+}
+
+func TestGetBooksPage(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	handler := web.NewAPIGatewayV2Handler(domain.NewBookCoreWithIDGenerator(store, domain.TimeOrderedID))
+	ids := make([]string, 3)
+
+	for i := range ids {
+		ret, err := handler.CreateBook(ctx, events.APIGatewayV2HTTPRequest{
+			Body: fmt.Sprintf(`{"title":"Book %d","authors":"Someone","publisher":"Someone Else","pages":100,"isbn":"978-0134190440"}`, i),
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, ret.StatusCode)
+
+		var book web.AppBook
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &book))
+		ids[i] = book.ID
+	}
+
+	t.Run("InvalidLimit", func(t *testing.T) {
+		ret, err := handler.GetBooks(ctx, events.APIGatewayV2HTTPRequest{
+			QueryStringParameters: map[string]string{"limit": "1000"},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, ret.StatusCode)
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		var got []string
+
+		query := map[string]string{"limit": "2"}
+
+		for pages := 0; pages < 3; pages++ {
+			ret, err := handler.GetBooks(ctx, events.APIGatewayV2HTTPRequest{QueryStringParameters: query})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, ret.StatusCode)
+
+			var page web.AppListBooks
+			require.NoError(t, json.Unmarshal([]byte(ret.Body), &page))
+
+			for _, book := range page.Books {
+				got = append(got, book.ID)
+			}
+
+			if page.Next == "" {
+				break
+			}
+
+			query = map[string]string{"limit": "2", "after": page.Next}
+		}
+
+		require.Equal(t, ids, got)
+	})
+}
+
+func TestPutBook(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+	bookID := uuid.New()
+	body := `{"title":"%s","authors":"Someone","publisher":"Someone Else","pages":100,"isbn":"978-0134190440"}`
+
+	t.Run("InvalidID", func(t *testing.T) {
+		ret, err := handler.PutBook(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters: map[string]string{"id": "1234"},
+			Body:           fmt.Sprintf(body, "Book"),
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, ret.StatusCode)
+	})
+
+	t.Run("InvalidBody", func(t *testing.T) {
+		ret, err := handler.PutBook(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters: map[string]string{"id": bookID.String()},
+			Body:           `{"title":"Book"}`,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, ret.StatusCode)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		ret, err := handler.PutBook(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters: map[string]string{"id": bookID.String()},
+			Body:           fmt.Sprintf(body, "First Edition"),
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, ret.StatusCode)
+
+		var book web.AppBook
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &book))
+		require.Equal(t, bookID.String(), book.ID)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		ret, err := handler.PutBook(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters: map[string]string{"id": bookID.String()},
+			Body:           fmt.Sprintf(body, "Second Edition"),
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, ret.StatusCode)
+
+		book, err := store.FindOne(ctx, bookID)
+		require.NoError(t, err)
+		require.Equal(t, "Second Edition", book.Title)
 	})
 }
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rotiroti/alessandrina/catalog"
+)
+
+// ImportBooks handles requests for importing books from a document in one of
+// the formats supported by the catalog, selected by the Content-Type header
+// (e.g. text/csv, application/marc or application/marcxml+xml) or by the
+// "format" query string parameter for the formats sharing a media type (e.g.
+// format=goodreads for a Goodreads CSV export).
+//
+// The "columns" query string parameter customizes the CSV headers (see
+// catalog.ParseMapping) and "dryRun=true" only validates the document.
+func (h *APIGatewayV2Handler) ImportBooks(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	mediaType, _, _ := mime.ParseMediaType(header(req.Headers, "Content-Type"))
+
+	format, ok := catalog.FormatByMediaType(mediaType)
+	if name := req.QueryStringParameters["format"]; name != "" {
+		if format, ok = catalog.FormatByName(name); !ok || format.Read == nil {
+			return badRequestResponse(fmt.Sprintf("format %q cannot be imported", name), nil), nil
+		}
+	}
+
+	if !ok || format.Read == nil {
+		return errorResponse(unsupportedMediaTypeError(mediaType)), nil
+	}
+
+	dryRun, err := queryBool(req.QueryStringParameters, "dryRun")
+	if err != nil {
+		return badRequestResponse("invalid dryRun parameter", err), nil
+	}
+
+	mapping, err := catalog.ParseMapping(req.QueryStringParameters["columns"])
+	if err != nil {
+		return badRequestResponse("invalid columns parameter", err), nil
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return badRequestResponse("invalid request body", err), nil
+	}
+
+	records, err := format.Read(bytes.NewReader(body), catalog.Options{Mapping: mapping})
+	if err != nil {
+		return badRequestResponse("invalid "+format.Name+" document", err), nil
+	}
+
+	report := h.importer.Import(ctx, records, dryRun)
+	appReport := ToAppImportReport(report)
+
+	switch {
+	case report.DryRun:
+		return jsonResponse(http.StatusOK, appReport), nil
+	case len(report.Failed) > 0:
+		return jsonResponse(http.StatusMultiStatus, appReport), nil
+	default:
+		return jsonResponse(http.StatusCreated, appReport), nil
+	}
+}
+
+// ExportBooks handles requests for exporting the whole catalog in one of the
+// formats supported by the catalog, selected by the Accept header or by the
+// "format" query string parameter (CSV by default).
+//
+// The "columns" query string parameter customizes the CSV headers (see
+// catalog.ParseMapping).
+func (h *APIGatewayV2Handler) ExportBooks(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	mediaType, ok := negotiate(req, exportMediaTypes())
+	if !ok {
+		return errorResponse(notAcceptableError()), nil
+	}
+
+	mapping, err := catalog.ParseMapping(req.QueryStringParameters["columns"])
+	if err != nil {
+		return badRequestResponse("invalid columns parameter", err), nil
+	}
+
+	books, err := h.book.FindEvery(ctx)
+	if err != nil {
+		return domainErrorResponse(err), nil
+	}
+
+	return formatResponse(mediaType, books, catalog.Options{Mapping: mapping}), nil
+}
+
+func textResponse(code int, contentType string, body string) events.APIGatewayV2HTTPResponse {
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: code,
+		Headers: map[string]string{
+			"Content-Type": contentType,
+		},
+		Body:            body,
+		IsBase64Encoded: false,
+	}
+}
+
+// header returns the value of a request header, API Gateway may forward
+// header names lowercased.
+func header(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// queryBool returns the boolean value of a query string parameter, false when
+// the parameter is missing.
+func queryBool(params map[string]string, name string) (bool, error) {
+	value, ok := params[name]
+	if !ok || value == "" {
+		return false, nil
+	}
+
+	ret, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("web.querybool: %w", err)
+	}
+
+	return ret, nil
+}
+
+// requestBody returns the request body, decoding it when API Gateway
+// forwarded it base64 encoded.
+func requestBody(req events.APIGatewayV2HTTPRequest) ([]byte, error) {
+	if !req.IsBase64Encoded {
+		return []byte(req.Body), nil
+	}
+
+	body, err := base64.StdEncoding.DecodeString(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("web.requestbody: %w", err)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,367 @@
+package web_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/database/ddb"
+	"github.com/rotiroti/alessandrina/sys/database/ddb/ddbtest"
+	"github.com/rotiroti/alessandrina/sys/database/memory"
+	"github.com/rotiroti/alessandrina/web"
+	"github.com/stretchr/testify/require"
+)
+
+const csvDocument = "Book Title,authors,publisher,pages,isbn\n" +
+	"The Go Programming Language,\"Alan A. A. Donovan, Brian W. Kernighan\",Addison-Wesley,400,978-0134190440\n" +
+	"Invalid Book,Someone,Someone Else,0,978-0134190440\n"
+
+func TestImportBooks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("UnsupportedMediaType", func(t *testing.T) {
+		handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(memory.NewStore()))
+		ret, err := handler.ImportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers: map[string]string{"content-type": "application/json"},
+			Body:    "{}",
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnsupportedMediaType, ret.StatusCode)
+	})
+
+	t.Run("InvalidColumns", func(t *testing.T) {
+		handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(memory.NewStore()))
+		ret, err := handler.ImportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers:               map[string]string{"content-type": "text/csv"},
+			QueryStringParameters: map[string]string{"columns": "color=Color"},
+			Body:                  csvDocument,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, ret.StatusCode)
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		store := memory.NewStore()
+		handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+		ret, err := handler.ImportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers: map[string]string{"content-type": "text/csv; charset=utf-8"},
+			QueryStringParameters: map[string]string{
+				"columns": "title=Book Title",
+				"dryRun":  "true",
+			},
+			Body: csvDocument,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, ret.StatusCode)
+
+		var report web.AppImportReport
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &report))
+		require.True(t, report.DryRun)
+		require.Equal(t, 2, report.Total)
+		require.Len(t, report.Imported, 1)
+		require.Len(t, report.Failed, 1)
+		require.Equal(t, 3, report.Failed[0].Position)
+		require.Equal(t, http.StatusUnprocessableEntity, report.Failed[0].Error.Code)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Empty(t, books)
+	})
+
+	t.Run("DryRunMARCXML", func(t *testing.T) {
+		doc, err := os.ReadFile("../catalog/testdata/books.xml")
+		require.NoError(t, err)
+
+		handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(memory.NewStore()))
+		ret, err := handler.ImportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers:               map[string]string{"content-type": "application/marcxml+xml"},
+			QueryStringParameters: map[string]string{"dryRun": "true"},
+			Body:                  string(doc),
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, ret.StatusCode)
+
+		var report web.AppImportReport
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &report))
+		require.Len(t, report.Imported, 1)
+		require.Equal(t, "The Go programming language", report.Imported[0].Title)
+		require.Len(t, report.Failed, 1)
+		require.Equal(t, http.StatusBadRequest, report.Failed[0].Error.Code)
+	})
+
+	t.Run("Goodreads", func(t *testing.T) {
+		doc, err := os.ReadFile("../catalog/testdata/goodreads_library_export.csv")
+		require.NoError(t, err)
+
+		store := memory.NewStore()
+		handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+		ret, err := handler.ImportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers:               map[string]string{"content-type": "text/csv"},
+			QueryStringParameters: map[string]string{"format": "goodreads"},
+			Body:                  string(doc),
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusMultiStatus, ret.StatusCode)
+
+		var report web.AppImportReport
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &report))
+		require.Len(t, report.Imported, 2)
+		require.Equal(t, []string{"programming", "go", "read"}, report.Imported[0].Tags)
+		require.Len(t, report.Failed, 1)
+		require.Equal(t, 4, report.Failed[0].Position)
+	})
+
+	t.Run("ONIX", func(t *testing.T) {
+		doc, err := os.ReadFile("../catalog/testdata/onix.xml")
+		require.NoError(t, err)
+
+		store := memory.NewStore()
+		handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+		ret, err := handler.ImportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers: map[string]string{"content-type": "application/onix+xml"},
+			Body:    string(doc),
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusMultiStatus, ret.StatusCode)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, books, 1)
+		require.Equal(t, "The Go Programming Language", books[0].Title)
+	})
+
+	t.Run("UnknownFormat", func(t *testing.T) {
+		handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(memory.NewStore()))
+		ret, err := handler.ImportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers:               map[string]string{"content-type": "text/csv"},
+			QueryStringParameters: map[string]string{"format": "bibtex"},
+			Body:                  csvDocument,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, ret.StatusCode)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		store := memory.NewStore()
+		handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+		ret, err := handler.ImportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers:               map[string]string{"Content-Type": "text/csv"},
+			QueryStringParameters: map[string]string{"columns": "title=Book Title"},
+			Body:                  base64.StdEncoding.EncodeToString([]byte(csvDocument)),
+			IsBase64Encoded:       true,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusMultiStatus, ret.StatusCode)
+
+		books, err := store.FindAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, books, 1)
+	})
+}
+
+func TestExportBooks(t *testing.T) {
+	ctx := context.Background()
+	expectedID, _ := setup(t)
+	store := memory.NewStore()
+	err := store.Save(ctx, domain.Book{
+		ID:        expectedID,
+		Title:     "The Go Programming Language",
+		Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher: "Addison-Wesley",
+		Pages:     400,
+		ISBN:      "978-0134190440",
+	})
+	require.NoError(t, err)
+
+	handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+	ret, err := handler.ExportBooks(ctx, events.APIGatewayV2HTTPRequest{
+		QueryStringParameters: map[string]string{"columns": "title=Book Title"},
+	})
+	expected := "id,Book Title,authors,publisher,pages,isbn\n" +
+		expectedID.String() + ",The Go Programming Language,\"Alan A. A. Donovan, Brian W. Kernighan\",Addison-Wesley,400,978-0134190440\n"
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, ret.StatusCode)
+	require.Equal(t, "text/csv; charset=utf-8", ret.Headers["Content-Type"])
+	require.Equal(t, expected, ret.Body)
+}
+
+func TestExportBooksPages(t *testing.T) {
+	ctx := context.Background()
+
+	// The DynamoDB store caps FindAll, the export reads the catalog one page
+	// at a time instead.
+	store, err := ddb.NewStore(ctx, "catalog", ddb.WithClient(ddbtest.NewClient(ddbtest.CatalogTable("catalog"))))
+	require.NoError(t, err)
+
+	books := make([]domain.Book, 2*domain.FindEveryPageSize+1)
+	for i := range books {
+		books[i] = domain.Book{ID: uuid.New(), Title: "Book " + strconv.Itoa(i), Authors: "Author", Publisher: "Publisher", Pages: 100}
+	}
+
+	require.NoError(t, store.SaveMany(ctx, books))
+
+	handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+	ret, err := handler.ExportBooks(ctx, events.APIGatewayV2HTTPRequest{})
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, ret.StatusCode)
+	require.Equal(t, len(books)+1, strings.Count(ret.Body, "\n"), "the header and every book")
+}
+
+func TestExportBooksFormat(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+
+	t.Run("MARC", func(t *testing.T) {
+		ret, err := handler.ExportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			QueryStringParameters: map[string]string{"format": "marc"},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, ret.StatusCode)
+		require.Equal(t, "application/marc", ret.Headers["Content-Type"])
+		require.True(t, ret.IsBase64Encoded)
+	})
+
+	t.Run("Accept", func(t *testing.T) {
+		ret, err := handler.ExportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers: map[string]string{"accept": "application/json;q=1, application/marcxml+xml;q=0.5"},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, ret.StatusCode)
+		require.Equal(t, "application/marcxml+xml; charset=utf-8", ret.Headers["Content-Type"])
+		require.Contains(t, ret.Body, "<collection")
+	})
+
+	t.Run("NotAcceptable", func(t *testing.T) {
+		ret, err := handler.ExportBooks(ctx, events.APIGatewayV2HTTPRequest{
+			Headers: map[string]string{"Accept": "application/pdf"},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotAcceptable, ret.StatusCode)
+	})
+}
+
+func TestGetBookFormat(t *testing.T) {
+	ctx := context.Background()
+	expectedID, _ := setup(t)
+	store := memory.NewStore()
+	err := store.Save(ctx, domain.Book{
+		ID:        expectedID,
+		Title:     "The Go Programming Language",
+		Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher: "Addison-Wesley",
+		Pages:     400,
+		ISBN:      "978-0134190440",
+	})
+	require.NoError(t, err)
+
+	handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+	ret, err := handler.GetBook(ctx, events.APIGatewayV2HTTPRequest{
+		PathParameters: map[string]string{"id": expectedID.String()},
+		Headers:        map[string]string{"Accept": "application/marcxml+xml"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, ret.StatusCode)
+
+	records, err := catalog.ReadMARCXML(strings.NewReader(ret.Body))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.NoError(t, records[0].Err)
+	require.Equal(t, "The Go Programming Language", records[0].Book.Title)
+
+	ret, err = handler.GetBook(ctx, events.APIGatewayV2HTTPRequest{
+		PathParameters:        map[string]string{"id": expectedID.String()},
+		QueryStringParameters: map[string]string{"format": "oai_dc"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, ret.StatusCode)
+	require.Equal(t, "application/oai_dc+xml; charset=utf-8", ret.Headers["Content-Type"])
+	require.Contains(t, ret.Body, "<dc:title>The Go Programming Language</dc:title>")
+
+	ret, err = handler.GetBook(ctx, events.APIGatewayV2HTTPRequest{
+		PathParameters: map[string]string{"id": expectedID.String()},
+		Headers:        map[string]string{"Accept": "text/html, */*;q=0.1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, ret.StatusCode)
+	require.Equal(t, "application/json", ret.Headers["Content-Type"])
+}
+
+func TestGetBooksCitation(t *testing.T) {
+	ctx := context.Background()
+	expectedID, _ := setup(t)
+	store := memory.NewStore()
+	err := store.Save(ctx, domain.Book{
+		ID:        expectedID,
+		Title:     "The Go Programming Language",
+		Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher: "Addison-Wesley",
+		Pages:     400,
+		ISBN:      "978-0134190440",
+	})
+	require.NoError(t, err)
+
+	handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+
+	tests := []struct {
+		name        string
+		req         events.APIGatewayV2HTTPRequest
+		contentType string
+		contains    string
+	}{
+		{
+			name:        "BibTeX",
+			req:         events.APIGatewayV2HTTPRequest{QueryStringParameters: map[string]string{"format": "bibtex"}},
+			contentType: "application/x-bibtex; charset=utf-8",
+			contains:    "@book{" + expectedID.String() + ",",
+		},
+		{
+			name:        "RIS",
+			req:         events.APIGatewayV2HTTPRequest{Headers: map[string]string{"Accept": "application/x-research-info-systems"}},
+			contentType: "application/x-research-info-systems; charset=utf-8",
+			contains:    "TY  - BOOK\r\n",
+		},
+		{
+			name: "CSLJSONByIDs",
+			req: events.APIGatewayV2HTTPRequest{
+				Headers:               map[string]string{"Accept": "application/vnd.citationstyles.csl+json"},
+				QueryStringParameters: map[string]string{"ids": expectedID.String()},
+			},
+			contentType: "application/vnd.citationstyles.csl+json; charset=utf-8",
+			contains:    `"id": "` + expectedID.String() + `"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ret, err := handler.GetBooks(ctx, tt.req)
+
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, ret.StatusCode)
+			require.Equal(t, tt.contentType, ret.Headers["Content-Type"])
+			require.Contains(t, ret.Body, tt.contains)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// FindDuplicates handles requests for listing the books that may duplicate
+// the one with the given ID (UUID).
+//
+// The "threshold" query string parameter (between 0 and 1) is the minimum
+// similarity score of the candidates, domain.DefaultDuplicateThreshold by
+// default.
+func (h *APIGatewayV2Handler) FindDuplicates(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	id, err := uuid.Parse(req.PathParameters["id"])
+	if err != nil {
+		return badRequestResponse("invalid book id", err), nil
+	}
+
+	threshold := domain.DefaultDuplicateThreshold
+
+	if value, ok := req.QueryStringParameters["threshold"]; ok {
+		threshold, err = strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			return badRequestResponse(fmt.Sprintf("invalid threshold parameter %q, it must be between 0 and 1", value), nil), nil
+		}
+	}
+
+	ret, err := h.book.FindDuplicates(ctx, id, threshold)
+	if err != nil {
+		return domainErrorResponse(err), nil
+	}
+
+	return jsonResponse(http.StatusOK, ToAppDuplicates(ret)), nil
+}
+
+// MergeBook handles requests for merging the book identified in the request
+// body into the book with the given ID (UUID), which survives the merge.
+//
+// Looking up the merged book afterwards redirects to the surviving one.
+func (h *APIGatewayV2Handler) MergeBook(ctx context.Context, req events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	id, err := uuid.Parse(req.PathParameters["id"])
+	if err != nil {
+		return badRequestResponse("invalid book id", err), nil
+	}
+
+	var appMergeBook AppMergeBook
+
+	if err := json.Unmarshal([]byte(req.Body), &appMergeBook); err != nil {
+		return badRequestResponse("invalid request body", err), nil
+	}
+
+	if err := h.validator.Check(appMergeBook); err != nil {
+		return badRequestResponse("invalid request body", err), nil
+	}
+
+	duplicateID, err := uuid.Parse(appMergeBook.DuplicateID)
+	if err != nil {
+		return badRequestResponse("invalid duplicate id", err), nil
+	}
+
+	ret, err := h.book.Merge(ctx, id, duplicateID)
+	if err != nil {
+		return domainErrorResponse(err), nil
+	}
+
+	return jsonResponse(http.StatusOK, ToAppBook(ret)), nil
+}
@@ -0,0 +1,131 @@
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/database/memory"
+	"github.com/rotiroti/alessandrina/web"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicatesAndMerge(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	handler := web.NewAPIGatewayV2Handler(domain.NewBookCore(store))
+
+	survivor := domain.Book{
+		ID:        uuid.New(),
+		Title:     "The Go Programming Language",
+		Authors:   "Alan A. A. Donovan, Brian W. Kernighan",
+		Publisher: "Addison-Wesley",
+		Pages:     380,
+		ISBN:      "978-0134190440",
+	}
+	duplicate := domain.Book{
+		ID:        uuid.New(),
+		Title:     "Go Programing Language",
+		Authors:   "Brian Kernighan, Alan Donovan",
+		Publisher: "Addison Wesley",
+		Pages:     380,
+		Tags:      []string{"go"},
+	}
+
+	require.NoError(t, store.Save(ctx, survivor))
+	require.NoError(t, store.Save(ctx, duplicate))
+
+	t.Run("InvalidThreshold", func(t *testing.T) {
+		ret, err := handler.FindDuplicates(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters:        map[string]string{"id": survivor.ID.String()},
+			QueryStringParameters: map[string]string{"threshold": "2"},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, ret.StatusCode)
+	})
+
+	t.Run("FindDuplicates", func(t *testing.T) {
+		ret, err := handler.FindDuplicates(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters:        map[string]string{"id": survivor.ID.String()},
+			QueryStringParameters: map[string]string{"threshold": "0.7"},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, ret.StatusCode)
+
+		var duplicates web.AppDuplicates
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &duplicates))
+		require.Len(t, duplicates.Duplicates, 1)
+		require.Equal(t, duplicate.ID.String(), duplicates.Duplicates[0].Book.ID)
+	})
+
+	t.Run("MergeInvalidBody", func(t *testing.T) {
+		ret, err := handler.MergeBook(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters: map[string]string{"id": survivor.ID.String()},
+			Body:           `{"duplicateId": "1234"}`,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, ret.StatusCode)
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		ret, err := handler.MergeBook(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters: map[string]string{"id": survivor.ID.String()},
+			Body:           `{"duplicateId": "` + duplicate.ID.String() + `"}`,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, ret.StatusCode)
+
+		var book web.AppBook
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &book))
+		require.Equal(t, survivor.ID.String(), book.ID)
+		require.Equal(t, []string{"go"}, book.Tags)
+	})
+
+	t.Run("GetMergedRedirects", func(t *testing.T) {
+		ret, err := handler.GetBook(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters: map[string]string{"id": duplicate.ID.String()},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusMovedPermanently, ret.StatusCode)
+		require.Equal(t, "/books/"+survivor.ID.String(), ret.Headers["Location"])
+	})
+
+	t.Run("MergeAgain", func(t *testing.T) {
+		ret, err := handler.MergeBook(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters: map[string]string{"id": survivor.ID.String()},
+			Body:           `{"duplicateId": "` + duplicate.ID.String() + `"}`,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, ret.StatusCode)
+	})
+
+	t.Run("PutMergedConflict", func(t *testing.T) {
+		ret, err := handler.PutBook(ctx, events.APIGatewayV2HTTPRequest{
+			PathParameters: map[string]string{"id": duplicate.ID.String()},
+			Body:           `{"title":"Book","authors":"Someone","publisher":"Someone Else","pages":100,"isbn":"978-0134190440"}`,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, http.StatusConflict, ret.StatusCode)
+	})
+
+	t.Run("MergedNotListed", func(t *testing.T) {
+		ret, err := handler.GetBooks(ctx, events.APIGatewayV2HTTPRequest{})
+
+		require.NoError(t, err)
+
+		var books web.AppListBooks
+		require.NoError(t, json.Unmarshal([]byte(ret.Body), &books))
+		require.Len(t, books.Books, 1)
+	})
+}
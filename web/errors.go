@@ -0,0 +1,160 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rotiroti/alessandrina/domain"
+	"github.com/rotiroti/alessandrina/sys/validation"
+)
+
+// Error codes returned in the "type" field of an error response. Clients can
+// rely on them, so they must never change once released.
+const (
+	codeBadRequest         = "bad_request"
+	codeUnsupportedMedia   = "unsupported_media_type"
+	codeNotAcceptable      = "not_acceptable"
+	codeNotFound           = "not_found"
+	codeConflict           = "conflict"
+	codeMerged             = "merged"
+	codeInvalid            = "invalid"
+	codePreconditionFailed = "precondition_failed"
+	codeThrottled          = "throttled"
+	codeUnavailable        = "unavailable"
+	codeInternal           = "internal"
+)
+
+// errorMapping associates a domain error with its HTTP representation.
+// retryAfter, when set, is the number of seconds sent to the client in the
+// Retry-After header.
+type errorMapping struct {
+	target     error
+	status     int
+	code       string
+	retryAfter int
+}
+
+var errorMappings = []errorMapping{
+	{target: domain.ErrNotFound, status: http.StatusNotFound, code: codeNotFound},
+	{target: domain.ErrAlreadyExists, status: http.StatusConflict, code: codeConflict},
+	{target: domain.ErrMerged, status: http.StatusConflict, code: codeMerged},
+	{target: domain.ErrInvalid, status: http.StatusUnprocessableEntity, code: codeInvalid},
+	{target: domain.ErrPreconditionFailed, status: http.StatusPreconditionFailed, code: codePreconditionFailed},
+	{target: domain.ErrThrottled, status: http.StatusServiceUnavailable, code: codeThrottled, retryAfter: 1},
+	{target: domain.ErrUnavailable, status: http.StatusServiceUnavailable, code: codeUnavailable, retryAfter: 5},
+}
+
+// AppError is the error model used by the API.
+type AppError struct {
+	Code    int                 `json:"code"`
+	Type    string              `json:"type"`
+	Message string              `json:"message"`
+	Fields  []domain.FieldError `json:"fields,omitempty"`
+
+	// RetryAfter is the number of seconds the client should wait before
+	// retrying the request, sent in the Retry-After header when not zero.
+	RetryAfter int `json:"-"`
+}
+
+// toAppError converts an error returned by the domain into an AppError.
+//
+// The message sent to the client is the one of the matching domain error, so
+// storage details never leak; unknown errors are logged and reported as
+// internal errors.
+func toAppError(err error) AppError {
+	for _, m := range errorMappings {
+		if !errors.Is(err, m.target) {
+			continue
+		}
+
+		appErr := AppError{Code: m.status, Type: m.code, Message: m.target.Error(), RetryAfter: m.retryAfter}
+
+		var verr *domain.ValidationError
+		if errors.As(err, &verr) {
+			appErr.Fields = verr.Fields
+		}
+
+		return appErr
+	}
+
+	log.Printf("web: internal error: %v", err)
+
+	return internalError()
+}
+
+// badRequestError returns the AppError of a request that could not be
+// decoded or does not have the expected shape.
+func badRequestError(message string, err error) AppError {
+	appErr := AppError{Code: http.StatusBadRequest, Type: codeBadRequest, Message: message}
+
+	var ferrs validation.FieldErrors
+	if errors.As(err, &ferrs) {
+		appErr.Fields = make([]domain.FieldError, len(ferrs))
+		for i, ferr := range ferrs {
+			appErr.Fields[i] = domain.FieldError{Field: ferr.Field, Err: ferr.Err}
+		}
+	}
+
+	return appErr
+}
+
+func unsupportedMediaTypeError(mediaType string) AppError {
+	return AppError{
+		Code:    http.StatusUnsupportedMediaType,
+		Type:    codeUnsupportedMedia,
+		Message: fmt.Sprintf("unsupported media type %q", mediaType),
+	}
+}
+
+func notAcceptableError() AppError {
+	return AppError{
+		Code:    http.StatusNotAcceptable,
+		Type:    codeNotAcceptable,
+		Message: "none of the requested representations is available",
+	}
+}
+
+func internalError() AppError {
+	return AppError{
+		Code:    http.StatusInternalServerError,
+		Type:    codeInternal,
+		Message: http.StatusText(http.StatusInternalServerError),
+	}
+}
+
+func domainErrorResponse(err error) events.APIGatewayV2HTTPResponse {
+	return errorResponse(toAppError(err))
+}
+
+func badRequestResponse(message string, err error) events.APIGatewayV2HTTPResponse {
+	return errorResponse(badRequestError(message, err))
+}
+
+func errorResponse(appErr AppError) events.APIGatewayV2HTTPResponse {
+	errorMessage := map[string]AppError{
+		"error": appErr,
+	}
+
+	// NOTE: ignoring error as if Marshal fails even here, we have bigger problems.
+	body, _ := json.Marshal(errorMessage)
+
+	response := events.APIGatewayV2HTTPResponse{
+		StatusCode: appErr.Code,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body:            string(body),
+		IsBase64Encoded: false,
+	}
+
+	if appErr.RetryAfter > 0 {
+		response.Headers["Retry-After"] = strconv.Itoa(appErr.RetryAfter)
+	}
+
+	return response
+}
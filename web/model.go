@@ -1,15 +1,24 @@
 package web
 
-import "github.com/rotiroti/alessandrina/domain"
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+)
 
 // AppBook is the book model used by the API.
 type AppBook struct {
-	ID        string `json:"id"`
-	Title     string `json:"title"`
-	Authors   string `json:"authors"`
-	Publisher string `json:"publisher"`
-	Pages     int    `json:"pages"`
-	ISBN      string `json:"isbn"`
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Authors   string   `json:"authors"`
+	Publisher string   `json:"publisher"`
+	Pages     int      `json:"pages"`
+	ISBN      string   `json:"isbn"`
+	Tags      []string `json:"tags,omitempty"`
 }
 
 // ToAppBook converts a domain.Book to an AppBook.
@@ -21,16 +30,21 @@ func ToAppBook(book domain.Book) AppBook {
 		Publisher: book.Publisher,
 		Pages:     book.Pages,
 		ISBN:      book.ISBN,
+		Tags:      book.Tags,
 	}
 }
 
 // AppNewBook is the new book model used by the API.
+//
+// NOTE: validation tags only check the shape of the payload, business
+// invariants (e.g. page count, ISBN checksum) are enforced by the domain.
 type AppNewBook struct {
-	Title     string `json:"title" validate:"required"`
-	Authors   string `json:"authors" validate:"required"`
-	Publisher string `json:"publisher" validate:"required"`
-	Pages     int    `json:"pages" validate:"required,min=1"`
-	ISBN      string `json:"isbn" validate:"required,isbn"`
+	Title     string   `json:"title" validate:"required"`
+	Authors   string   `json:"authors" validate:"required"`
+	Publisher string   `json:"publisher" validate:"required"`
+	Pages     int      `json:"pages"`
+	ISBN      string   `json:"isbn" validate:"required"`
+	Tags      []string `json:"tags,omitempty"`
 }
 
 // ToDomainNewBook converts an AppNewBook to a domain.NewBook.
@@ -41,12 +55,14 @@ func ToDomainNewBook(book AppNewBook) domain.NewBook {
 		Publisher: book.Publisher,
 		Pages:     book.Pages,
 		ISBN:      book.ISBN,
+		Tags:      book.Tags,
 	}
 }
 
 // AppListBooks is the list of books model used by the API.
 type AppListBooks struct {
 	Books []AppBook `json:"books"`
+	Next  string    `json:"next,omitempty"`
 }
 
 // ToAppListBooks converts a []domain.Book to an AppListBooks.
@@ -60,3 +76,145 @@ func ToAppListBooks(books []domain.Book) AppListBooks {
 		Books: appBooks,
 	}
 }
+
+// ToAppPage converts a domain.Page to an AppListBooks.
+func ToAppPage(page domain.Page) AppListBooks {
+	appPage := ToAppListBooks(page.Books)
+	if page.Next != uuid.Nil {
+		appPage.Next = page.Next.String()
+	}
+
+	return appPage
+}
+
+// AppFoundBooks is the model used by the API for a lookup of books by IDs.
+type AppFoundBooks struct {
+	Books   []AppBook `json:"books"`
+	Missing []string  `json:"missing"`
+}
+
+// ToAppFoundBooks converts the result of a lookup by IDs to an AppFoundBooks.
+func ToAppFoundBooks(books []domain.Book, missing []uuid.UUID) AppFoundBooks {
+	missingIDs := make([]string, len(missing))
+	for i, bookID := range missing {
+		missingIDs[i] = bookID.String()
+	}
+
+	return AppFoundBooks{
+		Books:   ToAppListBooks(books).Books,
+		Missing: missingIDs,
+	}
+}
+
+// AppNewBooks is the batch of new books model used by the API.
+type AppNewBooks struct {
+	Books []AppNewBook `json:"books"`
+}
+
+// AppBatchResult is the outcome of a single entry of a batch request.
+type AppBatchResult struct {
+	Index int       `json:"index"`
+	Book  *AppBook  `json:"book,omitempty"`
+	Error *AppError `json:"error,omitempty"`
+}
+
+// AppBatchResults is the batch results model used by the API.
+type AppBatchResults struct {
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Results []AppBatchResult `json:"results"`
+}
+
+// ToAppBatchResults summarizes a []AppBatchResult into an AppBatchResults.
+func ToAppBatchResults(results []AppBatchResult) AppBatchResults {
+	appResults := AppBatchResults{Results: results}
+
+	for _, result := range results {
+		if result.Error != nil {
+			appResults.Failed++
+		} else {
+			appResults.Created++
+		}
+	}
+
+	return appResults
+}
+
+// AppRecordError is the model used by the API for a rejected import entry.
+type AppRecordError struct {
+	Position int      `json:"position"`
+	Error    AppError `json:"error"`
+}
+
+// AppImportReport is the import report model used by the API.
+type AppImportReport struct {
+	DryRun   bool             `json:"dryRun"`
+	Total    int              `json:"total"`
+	Imported []AppBook        `json:"imported"`
+	Failed   []AppRecordError `json:"failed"`
+}
+
+// ToAppImportReport converts a catalog.Report to an AppImportReport.
+func ToAppImportReport(report catalog.Report) AppImportReport {
+	failed := make([]AppRecordError, len(report.Failed))
+	for i, rerr := range report.Failed {
+		var appErr AppError
+
+		switch fields := rerr.Fields(); {
+		case fields != nil:
+			appErr = AppError{Code: http.StatusUnprocessableEntity, Type: codeInvalid, Message: domain.ErrInvalid.Error(), Fields: fields}
+		case errors.Is(rerr.Err, catalog.ErrMalformed):
+			appErr = badRequestError(rerr.Err.Error(), rerr.Err)
+		default:
+			appErr = toAppError(rerr.Err)
+		}
+
+		failed[i] = AppRecordError{Position: rerr.Position, Error: appErr}
+	}
+
+	imported := ToAppListBooks(report.Imported).Books
+	if report.DryRun {
+		// Books are not saved during a dry run, so they do not have an ID yet.
+		for i := range imported {
+			imported[i].ID = ""
+		}
+	}
+
+	return AppImportReport{
+		DryRun:   report.DryRun,
+		Total:    report.Total,
+		Imported: imported,
+		Failed:   failed,
+	}
+}
+
+// AppDuplicate is a duplicate candidate model used by the API.
+type AppDuplicate struct {
+	Book  AppBook `json:"book"`
+	Score float64 `json:"score"`
+}
+
+// AppDuplicates is the list of duplicate candidates model used by the API.
+type AppDuplicates struct {
+	Duplicates []AppDuplicate `json:"duplicates"`
+}
+
+// ToAppDuplicates converts a []domain.Duplicate to an AppDuplicates.
+func ToAppDuplicates(duplicates []domain.Duplicate) AppDuplicates {
+	appDuplicates := make([]AppDuplicate, len(duplicates))
+	for i, duplicate := range duplicates {
+		appDuplicates[i] = AppDuplicate{
+			Book:  ToAppBook(duplicate.Book),
+			Score: math.Round(duplicate.Score*1000) / 1000,
+		}
+	}
+
+	return AppDuplicates{
+		Duplicates: appDuplicates,
+	}
+}
+
+// AppMergeBook is the merge request model used by the API.
+type AppMergeBook struct {
+	DuplicateID string `json:"duplicateId" validate:"required,uuid"`
+}
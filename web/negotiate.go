@@ -0,0 +1,152 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rotiroti/alessandrina/catalog"
+	"github.com/rotiroti/alessandrina/domain"
+)
+
+// mediaTypeJSON is the media type of the default API representation.
+const mediaTypeJSON = "application/json"
+
+// exportMediaTypes returns the media types of the catalog formats that can be
+// exported, in the order they are registered.
+func exportMediaTypes() []string {
+	var mediaTypes []string
+
+	for _, f := range catalog.Formats() {
+		if f.Write != nil {
+			mediaTypes = append(mediaTypes, f.MediaType)
+		}
+	}
+
+	return mediaTypes
+}
+
+// bookMediaTypes returns the media types books can be returned as by the
+// read endpoints, JSON being the default one.
+func bookMediaTypes() []string {
+	return append([]string{mediaTypeJSON}, exportMediaTypes()...)
+}
+
+// negotiate returns the media type requested by the client among the offered
+// ones, the first offer is used when the client accepts anything.
+//
+// The "format" query string parameter (a catalog format name or "json") takes
+// precedence over the Accept header. ok is false when none of the offers is
+// acceptable.
+func negotiate(req events.APIGatewayV2HTTPRequest, offers []string) (string, bool) {
+	if name := req.QueryStringParameters["format"]; name != "" {
+		mediaType := mediaTypeJSON
+
+		if !strings.EqualFold(name, "json") {
+			format, ok := catalog.FormatByName(name)
+			if !ok {
+				return "", false
+			}
+
+			mediaType = format.MediaType
+		}
+
+		return mediaType, slices.Contains(offers, mediaType)
+	}
+
+	accept := header(req.Headers, "Accept")
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+
+	var (
+		best    string
+		bestQ   float64
+		matched bool
+	)
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		if q <= 0 || matched && q <= bestQ {
+			continue
+		}
+
+		if offer := matchMediaRange(mediaType, offers); offer != "" {
+			best, bestQ, matched = offer, q, true
+		}
+	}
+
+	return best, matched
+}
+
+// matchMediaRange returns the first offer matching a media range such as
+// "text/csv", "text/*" or "*/*".
+func matchMediaRange(mediaRange string, offers []string) string {
+	if mediaRange == "*/*" {
+		return offers[0]
+	}
+
+	if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok {
+		for _, offer := range offers {
+			if strings.HasPrefix(offer, prefix+"/") {
+				return offer
+			}
+		}
+
+		return ""
+	}
+
+	for _, offer := range offers {
+		if strings.EqualFold(offer, mediaRange) {
+			return offer
+		}
+	}
+
+	return ""
+}
+
+// formatResponse encodes books with the catalog format of the given media type.
+func formatResponse(mediaType string, books []domain.Book, opts catalog.Options) events.APIGatewayV2HTTPResponse {
+	format, ok := catalog.FormatByMediaType(mediaType)
+	if !ok || format.Write == nil {
+		return errorResponse(notAcceptableError())
+	}
+
+	var buf bytes.Buffer
+	if err := format.Write(&buf, books, opts); err != nil {
+		log.Printf("web: write %s: %v", format.Name, err)
+
+		return errorResponse(internalError())
+	}
+
+	if format.Binary {
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode: http.StatusOK,
+			Headers: map[string]string{
+				"Content-Type": format.MediaType,
+			},
+			Body:            base64.StdEncoding.EncodeToString(buf.Bytes()),
+			IsBase64Encoded: true,
+		}
+	}
+
+	return textResponse(http.StatusOK, fmt.Sprintf("%s; charset=utf-8", format.MediaType), buf.String())
+}